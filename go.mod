@@ -1,7 +1,8 @@
 module github.com/shnellpavel/json-stream
 
+go 1.24
+
 require (
-	github.com/Jeffail/gabs v1.1.1
 	github.com/alecthomas/template v0.0.0-20160405071501-a0175ee3bccc // indirect
 	github.com/alecthomas/units v0.0.0-20151022065526-2efee857e7cf // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
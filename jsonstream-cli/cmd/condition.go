@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Syntaxes of condition passed by --condition flag
+const (
+	syntaxNative = "native"
+	syntaxLucene = "lucene"
+)
+
+// conditionFlags are flags of command describing condition in one of supported syntaxes
+type conditionFlags struct {
+	condition string
+	syntax    string
+	queryJSON string
+	jsonPath  string
+	vars      map[string]string
+}
+
+func newConditionFlags() *conditionFlags {
+	return &conditionFlags{
+		vars: map[string]string{},
+	}
+}
+
+// init registers flags of condition in command
+func (f *conditionFlags) init(cmd *kingpin.CmdClause) {
+	cmd.Flag("condition", "expression with condition").
+		StringVar(&f.condition)
+
+	cmd.Flag("query-json", `condition as MongoDB-style query document, e.g. {"age": {"$gt": 5}}`).
+		PlaceHolder("DOCUMENT").
+		StringVar(&f.queryJSON)
+
+	cmd.Flag("jsonpath", "condition as JSONPath query (RFC 9535) selecting non-empty nodelist, e.g. '$.children[?@.age > 5]'").
+		PlaceHolder("QUERY").
+		StringVar(&f.jsonPath)
+
+	f.initSyntax(cmd)
+}
+
+// initSyntax registers flags of syntax and variables, they are used by commands parsing many expressions
+func (f *conditionFlags) initSyntax(cmd *kingpin.CmdClause) {
+	cmd.Flag("syntax", "syntax of condition: native or lucene (Kibana-style query, e.g. status:500 AND path:/api/*)").
+		Default(syntaxNative).
+		EnumVar(&f.syntax, syntaxNative, syntaxLucene)
+
+	cmd.Flag("var", `value of variable used in condition as $name, e.g. --var threshold=250 (true, false and numbers in canonical form are typed, "..." forces string)`).
+		PlaceHolder("NAME=VALUE").
+		StringMapVar(&f.vars)
+}
+
+// build parses condition passed by flags
+func (f *conditionFlags) build() (*filter.Condition, error) {
+	sources := 0
+	for _, source := range []string{f.condition, f.queryJSON, f.jsonPath} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, errors.New("exactly one of --condition, --query-json and --jsonpath is required")
+	}
+
+	if f.queryJSON != "" {
+		cond, err := filter.NewConditionFromMongo([]byte(f.queryJSON))
+		return cond, errors.Wrap(err, "parse query document error")
+	}
+
+	if f.jsonPath != "" {
+		cond, err := filter.NewConditionFromJSONPath(f.jsonPath)
+		if err != nil {
+			printParseError(err)
+			return nil, errors.Wrap(err, "parse filter error")
+		}
+		return cond, nil
+	}
+
+	return f.parse(f.condition)
+}
+
+// parse parses expression in syntax and with variables passed by flags
+func (f *conditionFlags) parse(expr string) (*filter.Condition, error) {
+	var (
+		cond *filter.Condition
+		err  error
+	)
+	if f.syntax == syntaxLucene {
+		if len(f.vars) > 0 {
+			return nil, errors.New("variables are supported only by native syntax")
+		}
+		cond, err = filter.NewConditionFromLucene(expr)
+	} else {
+		vars := make(map[string]interface{}, len(f.vars))
+		for name, val := range f.vars {
+			vars[name] = varValue(val)
+		}
+		cond, err = filter.NewConditionFromStr(expr, filter.WithVars(vars))
+	}
+
+	if err != nil {
+		printParseError(err)
+		return nil, errors.Wrap(err, "parse filter error")
+	}
+
+	return cond, nil
+}
+
+// varValue infers type of variable passed by flag: true and false are booleans, numbers which are formatted back
+// to the same text are numbers (02134 or 1.50 are kept as strings), values in double quotes are strings without quotes,
+// other values are strings as is
+func varValue(val string) interface{} {
+	if strings.HasPrefix(val, `"`) {
+		if unquoted, err := strconv.Unquote(val); err == nil {
+			return unquoted
+		}
+	}
+	if val == "true" || val == "false" {
+		return val == "true"
+	}
+	num, err := strconv.ParseFloat(val, 64)
+	if err == nil && !math.IsInf(num, 0) && !math.IsNaN(num) && strconv.FormatFloat(num, 'g', -1, 64) == val {
+		return num
+	}
+	return val
+}
+
+// optimizeCondition optimizes condition and prints warnings about it to stderr, prefix tells where condition comes from
+func optimizeCondition(cond filter.Condition, prefix string) filter.Condition {
+	optimized, warnings := filter.Optimize(cond)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s%s\n", prefix, warning)
+	}
+	return optimized
+}
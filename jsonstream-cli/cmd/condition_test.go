@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarValue(t *testing.T) {
+	cases := []struct {
+		name     string
+		val      string
+		expected interface{}
+	}{
+		{name: "Integer", val: "250", expected: 250.0},
+		{name: "Float", val: "-0.5", expected: -0.5},
+		{name: "Leading zero is string", val: "02134", expected: "02134"},
+		{name: "Trailing zero is string", val: "1.50", expected: "1.50"},
+		{name: "Exponent is string", val: "1e3", expected: "1e3"},
+		{name: "Quoted number is string", val: `"250"`, expected: "250"},
+		{name: "Quoted boolean is string", val: `"true"`, expected: "true"},
+		{name: "Unterminated quote is kept", val: `"250`, expected: `"250`},
+		{name: "Boolean", val: "false", expected: false},
+		{name: "Capitalized boolean is string", val: "True", expected: "True"},
+		{name: "Non-finite number is string", val: "Inf", expected: "Inf"},
+		{name: "String", val: "api gateway", expected: "api gateway"},
+		{name: "Empty string", val: "", expected: ""},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, varValue(testCase.val))
+		})
+	}
+}
+
+func TestConditionFlags_ParseVars(t *testing.T) {
+	flags := conditionFlags{syntax: syntaxNative, vars: map[string]string{"threshold": "250", "flag": "true", "svc": "api"}}
+
+	cond, err := flags.parse("latency > $threshold and enabled = $flag and service ~ $svc")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "latency > json(250) and enabled = json(true) and service ~ api", cond.String())
+	}
+
+	_, err = flags.parse("enabled > $flag")
+	assert.Equal(t, filter.ErrUnsupportedOperator, errors.Cause(err))
+
+	_, err = flags.parse("code ~ $threshold")
+	assert.Equal(t, filter.ErrUnsupportedOperator, errors.Cause(err))
+
+	// numeric-looking values which aren't formatted back the same way stay strings
+	flags.vars = map[string]string{"zip": "02134", "code": `"250"`}
+	cond, err = flags.parse("zip = $zip and code = $code")
+	if assert.NoError(t, err) {
+		_, isOk, err := filter.ProcessElem(*cond, []byte(`{"zip": "02134", "code": "250"}`))
+		assert.NoError(t, err)
+		assert.True(t, isOk)
+
+		_, isOk, err = filter.ProcessElem(*cond, []byte(`{"zip": 2134, "code": 250}`))
+		assert.NoError(t, err)
+		assert.False(t, isOk)
+	}
+}
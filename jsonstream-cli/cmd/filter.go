@@ -1,9 +1,9 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 
 	"github.com/pkg/errors"
@@ -13,66 +13,125 @@ import (
 
 // FilterCommand represents command to filter stream
 type FilterCommand struct {
-	condition    string
-	skipErrLines bool
+	condition     *conditionFlags
+	records       *recordFlags
+	skipErrLines  bool
+	explain       bool
+	explainFormat string
+	annotate      bool
+	annotateField string
+	optimize      bool
+	prefilter     bool
+	backend       string
+	workers       int
+	unordered     bool
+	stats         bool
 }
 
 // NewFilter constructs FilterCommand
 func NewFilter() *FilterCommand {
-	return &FilterCommand{}
+	return &FilterCommand{
+		condition: newConditionFlags(),
+		records:   newRecordFlags(),
+	}
 }
 
 // InitArgs initialize arguments and flags to run command
 func (c *FilterCommand) InitArgs(cmd *kingpin.CmdClause) {
-	cmd.Flag("condition", "expression with condition").
-		Required().
-		StringVar(&c.condition)
+	c.condition.init(cmd)
+	c.records.init(cmd)
+	c.records.initOutput(cmd)
 
 	cmd.Flag("skip-err-lines", "skips lines that unable to parse").
 		BoolVar(&c.skipErrLines)
+
+	cmd.Flag("optimize", "simplifies condition and reorders its operands from cheap to expensive ones, prints warnings about never satisfied parts to stderr").
+		BoolVar(&c.optimize)
+
+	cmd.Flag("prefilter", "skips lines without literals required by condition (keys of paths, values of equalities) without decoding them, such lines are only checked to be valid json: errors of comparisons with values of other types aren't reported for them").
+		BoolVar(&c.prefilter)
+
+	backends := make([]string, 0, len(filter.Backends))
+	for _, backend := range filter.Backends {
+		backends = append(backends, backend.String())
+	}
+	cmd.Flag("backend", "representation of lines which condition is evaluated over: lazy scans only values by paths of condition, json decodes lines entirely").
+		Default(filter.BackendLazy.String()).
+		EnumVar(&c.backend, backends...)
+
+	cmd.Flag("workers", "count of goroutines processing lines, lines are read and processed by batches if it's greater than 1").
+		Default("1").
+		IntVar(&c.workers)
+
+	cmd.Flag("unordered", "writes outputs of lines processed by workers as soon as they are ready, not in order of input").
+		BoolVar(&c.unordered)
+
+	cmd.Flag("stats", "prints counts of read, matched, skipped and errored lines to stderr").
+		BoolVar(&c.stats)
+
+	cmd.Flag("explain", "prints trace of condition for every element instead of filtering").
+		BoolVar(&c.explain)
+
+	cmd.Flag("explain-format", "format of trace: text or json").
+		Default(string(filter.ExplainText)).
+		EnumVar(&c.explainFormat, string(filter.ExplainText), string(filter.ExplainJSON))
+
+	cmd.Flag("annotate-matches", "adds values which satisfied condition (matched elements of arrays, named groups of regular expressions) to output objects").
+		BoolVar(&c.annotate)
+
+	cmd.Flag("annotate-field", "field of output object to add matched values to").
+		Default("_matches").
+		StringVar(&c.annotateField)
 }
 
 // Run handles command execution
 func (c *FilterCommand) Run(_ *kingpin.ParseContext) error {
-	info, err := os.Stdin.Stat()
+	reader, err := openStdin()
 	if err != nil {
-		return errors.Wrap(err, "check stdin stat error")
+		return err
 	}
 
-	if info.Mode()&os.ModeCharDevice != 0 {
-		return errors.New("The command is intended to work with pipes")
-	}
-
-	reader := bufio.NewReader(os.Stdin)
-
-	filterExpr, err := filter.NewConditionFromStr(c.condition)
+	cond, err := c.condition.build()
 	if err != nil {
-		return errors.Wrap(err, "parse filter error")
+		return err
 	}
 
-	for {
-		line, _, err := reader.ReadLine()
-		if err != nil && err == io.EOF {
-			break
-		}
+	if c.optimize {
+		*cond = optimizeCondition(*cond, "")
+	}
 
-		if err != nil {
-			return errors.Wrap(err, "read line error")
-		}
+	opts := []filter.CompileOption{filter.WithBackend(filter.Backend(c.backend))}
+	if c.prefilter {
+		opts = append(opts, filter.WithPrefilter())
+	}
 
-		resLine, isOk, err := filter.ProcessElem(*filterExpr, line)
-		if err != nil {
-			if c.skipErrLines {
-				continue
-			}
+	program, err := filter.Compile(*cond, opts...)
+	if err != nil {
+		return errors.Wrap(err, "compile filter error")
+	}
 
-			return errors.Wrap(err, "process line error")
-		}
+	streamOpts := append(c.records.options(c.skipErrLines), filter.WithWorkers(c.workers))
+	if c.unordered {
+		streamOpts = append(streamOpts, filter.WithUnordered())
+	}
+	if c.explain {
+		streamOpts = append(streamOpts, filter.WithExplain(filter.ExplainFormat(c.explainFormat)))
+	} else if c.annotate {
+		streamOpts = append(streamOpts, filter.WithAnnotations(c.annotateField))
+	}
 
-		if isOk {
-			fmt.Println(string(resLine))
-		}
+	stats, err := filter.Stream(context.Background(), reader, os.Stdout, program, streamOpts...)
+	if c.stats {
+		printStats(stats)
 	}
+	return err
+}
 
-	return nil
+// printStats prints counters of stream to stderr as json object
+func printStats(stats filter.Stats) {
+	encoded, err := json.Marshal(stats)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(encoded))
 }
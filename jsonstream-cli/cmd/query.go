@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/shnellpavel/json-stream/jsonstream/query"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Output formats of query result
+const (
+	formatNDJSON = "ndjson"
+	formatTable  = "table"
+)
+
+// QueryCommand represents command to run SQL-like query over stream
+type QueryCommand struct {
+	query        string
+	format       string
+	skipErrLines bool
+	records      *recordFlags
+}
+
+// NewQuery constructs QueryCommand
+func NewQuery() *QueryCommand {
+	return &QueryCommand{
+		records: newRecordFlags(),
+	}
+}
+
+// InitArgs initialize arguments and flags to run command
+func (c *QueryCommand) InitArgs(cmd *kingpin.CmdClause) {
+	cmd.Arg("query", `query, e.g. "SELECT service, count(*), p95(latency) WHERE status >= 500 GROUP BY service"`).
+		Required().
+		StringVar(&c.query)
+
+	cmd.Flag("format", "output format: ndjson or table").
+		Default(formatNDJSON).
+		EnumVar(&c.format, formatNDJSON, formatTable)
+
+	cmd.Flag("skip-err-lines", "skips lines that unable to parse").
+		BoolVar(&c.skipErrLines)
+
+	c.records.init(cmd)
+}
+
+// Run handles command execution
+func (c *QueryCommand) Run(_ *kingpin.ParseContext) error {
+	reader, err := openStdin()
+	if err != nil {
+		return err
+	}
+
+	return c.run(reader, os.Stdout)
+}
+
+// run writes result of query over elements of reader, reading stops when LIMIT is reached
+func (c *QueryCommand) run(reader io.Reader, stdout io.Writer) error {
+	parsed, err := query.Parse(c.query)
+	if err != nil {
+		printParseError(err)
+		return errors.Wrap(err, "parse query error")
+	}
+
+	var writer query.RowWriter = query.NewNDJSONWriter(stdout, parsed.Columns())
+	if c.format == formatTable {
+		writer = query.NewTableWriter(stdout, parsed.Columns())
+	}
+
+	executor := parsed.NewExecutor()
+	for record, err := range filter.Records(context.Background(), reader, c.records.options(c.skipErrLines)...) {
+		if err != nil {
+			return err
+		}
+
+		row, isOk, err := executor.Process(record.Raw)
+		if err != nil {
+			if c.skipErrLines {
+				continue
+			}
+
+			return errors.Wrap(err, "process line error")
+		}
+
+		if isOk {
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		// the next element isn't read after the last row
+		if executor.Done() {
+			break
+		}
+	}
+
+	for _, row := range executor.Finish() {
+		if err := writer.WriteRow(row); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCommand_LimitStopsReading(t *testing.T) {
+	cmd := NewQuery()
+	if !parseArgs(t, cmd.InitArgs, "SELECT id WHERE note = limit LIMIT 2") {
+		return
+	}
+
+	// reading after the second row fails
+	reader := io.MultiReader(
+		strings.NewReader(`{"id": 1, "note": "limit"}`+"\n"+`{"id": 2, "note": "x"}`+"\n"+`{"id": 3, "note": "limit"}`+"\n"),
+		iotest.ErrReader(errors.New("element after limit is read")),
+	)
+	var out bytes.Buffer
+	assert.NoError(t, cmd.run(reader, &out))
+	assert.Equal(t, "{\"id\":1}\n{\"id\":3}\n", out.String())
+}
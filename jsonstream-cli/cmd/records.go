@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// recordFlags are flags of command describing framing of input into records and format of output
+type recordFlags struct {
+	inputFormat  string
+	inputPath    string
+	maxSize      int
+	oversize     string
+	outputFormat string
+}
+
+func newRecordFlags() *recordFlags {
+	return &recordFlags{outputFormat: string(filter.OutputNDJSON)}
+}
+
+// init registers flags of records in command
+func (f *recordFlags) init(cmd *kingpin.CmdClause) {
+	formats := make([]string, 0, len(filter.InputFormats))
+	for _, format := range filter.InputFormats {
+		formats = append(formats, string(format))
+	}
+	cmd.Flag("input-format", "splitting of input: ndjson is one element per line, concat splits pretty-printed or concatenated elements by their structure, json-seq is RFC 7464 sequence, array splits array into elements, auto detects json-seq or concat").
+		Default(string(filter.InputNDJSON)).
+		EnumVar(&f.inputFormat, formats...)
+
+	cmd.Flag("input-path", "keys of objects separated by dot which lead to array of array input format, e.g. data.items").
+		PlaceHolder("PATH").
+		StringVar(&f.inputPath)
+
+	cmd.Flag("max-record-size", "maximum size of element in bytes without line feed, 0 means unlimited size").
+		Default("0").
+		IntVar(&f.maxSize)
+
+	cmd.Flag("oversize", "handling of elements larger than --max-record-size: error stops the command, skip drops them (independently of --skip-err-lines)").
+		Default(string(filter.OversizeError)).
+		EnumVar(&f.oversize, string(filter.OversizeError), string(filter.OversizeSkip))
+}
+
+// initOutput registers flags of written records in command
+func (f *recordFlags) initOutput(cmd *kingpin.CmdClause) {
+	cmd.Flag("output-format", "format of written elements: ndjson is one element per line (multi-line elements are compacted), json-seq is RFC 7464 sequence").
+		Default(string(filter.OutputNDJSON)).
+		EnumVar(&f.outputFormat, string(filter.OutputNDJSON), string(filter.OutputJSONSeq))
+}
+
+// options builds options of reading records, skipping of errors resumes reading after malformed elements
+func (f *recordFlags) options(skipErrors bool) []filter.StreamOption {
+	errorPolicy := filter.ErrorStop
+	if skipErrors {
+		errorPolicy = filter.ErrorSkip
+	}
+
+	return []filter.StreamOption{
+		filter.WithInputFormat(filter.InputFormat(f.inputFormat)),
+		filter.WithInputPath(f.inputPath),
+		filter.WithErrorPolicy(errorPolicy),
+		filter.WithMaxRecordSize(f.maxSize),
+		filter.WithOversizePolicy(filter.OversizePolicy(f.oversize)),
+		filter.WithOutputFormat(filter.OutputFormat(f.outputFormat)),
+	}
+}
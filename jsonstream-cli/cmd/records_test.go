@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFlags_Options(t *testing.T) {
+	cases := []struct {
+		name          string
+		args          []string
+		skipErrors    bool
+		input         string
+		expected      []string
+		expectedCause error
+		expectedErr   string
+	}{
+		{
+			name:     "Defaults",
+			input:    "{\"a\": 1}\n{\"a\": 22}\n",
+			expected: []string{`{"a": 1}`, `{"a": 22}`},
+		},
+		{
+			name:          "Too large record stops reading",
+			args:          []string{"--max-record-size", "8"},
+			input:         "{\"a\": 1}\n{\"a\": 22}\n{\"a\": 3}\n",
+			expected:      []string{`{"a": 1}`},
+			expectedCause: filter.ErrRecordTooLarge,
+		},
+		{
+			name:     "Too large record is skipped",
+			args:     []string{"--max-record-size", "8", "--oversize", "skip"},
+			input:    "{\"a\": 1}\n{\"a\": 22}\n{\"a\": 3}\n",
+			expected: []string{`{"a": 1}`, `{"a": 3}`},
+		},
+		{
+			name:          "Skipping of errors doesn't skip too large records",
+			args:          []string{"--max-record-size", "8"},
+			skipErrors:    true,
+			input:         "{\"a\": 1}\n{\"a\": 22}\n{\"a\": 3}\n",
+			expected:      []string{`{"a": 1}`},
+			expectedCause: filter.ErrRecordTooLarge,
+		},
+		{
+			name:     "Concatenated values",
+			args:     []string{"--input-format", "concat"},
+			input:    "{\n  \"a\": 1\n}{\"a\": 2} 3\n",
+			expected: []string{"{\n  \"a\": 1\n}", `{"a": 2}`, "3"},
+		},
+		{
+			name:          "Malformed value stops reading",
+			args:          []string{"--input-format", "concat"},
+			input:         `{"a": 1} {"a": ] {"a": 3}`,
+			expected:      []string{`{"a": 1}`},
+			expectedCause: filter.ErrMalformedValue,
+		},
+		{
+			name:       "Malformed value is skipped",
+			args:       []string{"--input-format", "concat"},
+			skipErrors: true,
+			input:      "{\"a\": 1}\n{\"a\": ]\n{\"a\": 3}\n",
+			expected:   []string{`{"a": 1}`, `{"a": 3}`},
+		},
+		{
+			name:     "Json text sequence",
+			args:     []string{"--input-format", "json-seq"},
+			input:    "\x1e{\"a\": 1}\n\x1e{\"a\": 2}\n",
+			expected: []string{`{"a": 1}`, `{"a": 2}`},
+		},
+		{
+			name:     "Detected json text sequence",
+			args:     []string{"--input-format", "auto"},
+			input:    "\x1e{\"a\": 1}\n\x1e{\"a\": 2}\n",
+			expected: []string{`{"a": 1}`, `{"a": 2}`},
+		},
+		{
+			name:     "Elements of top-level array",
+			args:     []string{"--input-format", "array"},
+			input:    `[{"a": 1}, {"a": 2}]`,
+			expected: []string{`{"a": 1}`, `{"a": 2}`},
+		},
+		{
+			name:     "Elements of nested array",
+			args:     []string{"--input-format", "array", "--input-path", "data.items"},
+			input:    `{"meta": {"items": [0]}, "data": {"items": [{"a": 1}, 2]}, "rest": [3]}`,
+			expected: []string{`{"a": 1}`, "2"},
+		},
+		{
+			name:        "Missing array",
+			args:        []string{"--input-format", "array", "--input-path", "data.items"},
+			input:       `{"data": {}}`,
+			expected:    nil,
+			expectedErr: "path 'data.items' isn't found: key 'items' is missing",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			flags := newRecordFlags()
+			if !parseArgs(t, flags.init, testCase.args...) {
+				return
+			}
+
+			var records []string
+			var err error
+			for record, recordErr := range filter.Records(context.Background(), strings.NewReader(testCase.input),
+				flags.options(testCase.skipErrors)...) {
+				if err = recordErr; err != nil {
+					break
+				}
+				records = append(records, string(record.Raw))
+			}
+
+			assert.Equal(t, testCase.expected, records)
+			if testCase.expectedErr != "" {
+				assert.EqualError(t, err, testCase.expectedErr)
+			} else {
+				assert.Equal(t, testCase.expectedCause, errors.Cause(err))
+			}
+		})
+	}
+}
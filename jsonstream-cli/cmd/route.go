@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Modes of routing element satisfying many rules
+const (
+	routeFirst = "first"
+	routeAll   = "all"
+)
+
+// stdoutPath is a path of output meaning stdout
+const stdoutPath = "-"
+
+// RouteCommand represents command to fan out stream to many outputs by rules
+type RouteCommand struct {
+	condition    *conditionFlags
+	records      *recordFlags
+	rules        []string
+	outs         []string
+	defaultOut   string
+	mode         string
+	skipErrLines bool
+	optimize     bool
+}
+
+// NewRoute constructs RouteCommand
+func NewRoute() *RouteCommand {
+	return &RouteCommand{
+		condition: newConditionFlags(),
+		records:   newRecordFlags(),
+	}
+}
+
+// InitArgs initialize arguments and flags to run command
+func (c *RouteCommand) InitArgs(cmd *kingpin.CmdClause) {
+	cmd.Flag("rule", "named condition, e.g. --rule 'errors=level = error', rules are checked in order of flags").
+		PlaceHolder("NAME=CONDITION").
+		Required().
+		StringsVar(&c.rules)
+
+	cmd.Flag("out", "file to write elements satisfying rule to, '-' is stdout, e.g. --out errors=errors.ndjson").
+		PlaceHolder("NAME=FILE").
+		StringsVar(&c.outs)
+
+	cmd.Flag("default", "file to write elements satisfying no rule to, they are skipped if it isn't set").
+		PlaceHolder("FILE").
+		StringVar(&c.defaultOut)
+
+	cmd.Flag("mode", "first: element is written to output of the first satisfied rule, all: to outputs of all satisfied rules").
+		Default(routeFirst).
+		EnumVar(&c.mode, routeFirst, routeAll)
+
+	cmd.Flag("skip-err-lines", "skips lines that unable to parse").
+		BoolVar(&c.skipErrLines)
+
+	cmd.Flag("optimize", "simplifies conditions of rules, prints warnings about never satisfied parts to stderr").
+		BoolVar(&c.optimize)
+
+	c.condition.initSyntax(cmd)
+	c.records.init(cmd)
+	c.records.initOutput(cmd)
+}
+
+// Run handles command execution
+func (c *RouteCommand) Run(_ *kingpin.ParseContext) error {
+	reader, err := openStdin()
+	if err != nil {
+		return err
+	}
+
+	return c.run(reader, os.Stdout)
+}
+
+// run writes elements of reader to outputs of rules, output '-' is stdout
+func (c *RouteCommand) run(reader io.Reader, stdout io.Writer) (err error) {
+	ruleSet, err := c.buildRules()
+	if err != nil {
+		return err
+	}
+
+	outputs := newRouteOutputs(stdout, c.records.options(c.skipErrLines))
+	defer func() {
+		if closeErr := outputs.close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	routes, err := c.openRoutes(outputs)
+	if err != nil {
+		return err
+	}
+
+	var defaultOut *filter.RecordWriter
+	if c.defaultOut != "" {
+		if defaultOut, err = outputs.open(c.defaultOut); err != nil {
+			return err
+		}
+	}
+
+	for record, err := range filter.Records(context.Background(), reader, c.records.options(c.skipErrLines)...) {
+		if err != nil {
+			return err
+		}
+		line := record.Raw
+
+		var ids []string
+		if c.mode == routeFirst {
+			var id string
+			var found bool
+			if id, found, err = ruleSet.MatchFirst(line); found {
+				ids = []string{id}
+			}
+		} else {
+			ids, err = ruleSet.Match(line)
+		}
+
+		if err != nil {
+			if c.skipErrLines {
+				continue
+			}
+
+			return errors.Wrap(err, "process line error")
+		}
+
+		outs := make([]*filter.RecordWriter, 0, len(ids))
+		for _, id := range ids {
+			outs = append(outs, routes[id])
+		}
+		if len(ids) == 0 && defaultOut != nil {
+			outs = append(outs, defaultOut)
+		}
+
+		// rules sharing output write element once
+		written := map[*filter.RecordWriter]bool{}
+		for _, out := range outs {
+			if written[out] {
+				continue
+			}
+			written[out] = true
+
+			if err := out.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// buildRules parses rules in order of flags
+func (c *RouteCommand) buildRules() (*filter.RuleSet, error) {
+	rules := make([]filter.Rule, 0, len(c.rules))
+	for _, rule := range c.rules {
+		id, expr, err := splitNamed(rule, "rule")
+		if err != nil {
+			return nil, err
+		}
+
+		cond, err := c.condition.parse(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rule '%s'", id)
+		}
+		if c.optimize {
+			*cond = optimizeCondition(*cond, "rule '"+id+"': ")
+		}
+		rules = append(rules, filter.Rule{ID: id, Condition: *cond})
+	}
+
+	ruleSet, err := filter.NewRuleSet(rules)
+	return ruleSet, errors.Wrap(err, "compile rules error")
+}
+
+// openRoutes opens outputs of rules, every rule must have exactly one output
+func (c *RouteCommand) openRoutes(outputs *routeOutputs) (map[string]*filter.RecordWriter, error) {
+	paths := make(map[string]string, len(c.outs))
+	for _, out := range c.outs {
+		id, path, err := splitNamed(out, "out")
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := paths[id]; ok {
+			return nil, errors.Errorf("duplicate output of rule '%s'", id)
+		}
+		paths[id] = path
+	}
+
+	routes := make(map[string]*filter.RecordWriter, len(c.rules))
+	for _, rule := range c.rules {
+		id, _, _ := splitNamed(rule, "rule")
+		path, ok := paths[id]
+		if !ok {
+			return nil, errors.Errorf("missing output of rule '%s'", id)
+		}
+		delete(paths, id)
+
+		out, err := outputs.open(path)
+		if err != nil {
+			return nil, err
+		}
+		routes[id] = out
+	}
+
+	for id := range paths {
+		return nil, errors.Errorf("output of unknown rule '%s'", id)
+	}
+
+	return routes, nil
+}
+
+// splitNamed splits value of flag in form NAME=VALUE
+func splitNamed(value string, flag string) (string, string, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.Errorf("invalid --%s '%s', expected NAME=VALUE", flag, value)
+	}
+	return parts[0], parts[1], nil
+}
+
+// routeOutputs are files opened once per path, so rules may share output
+type routeOutputs struct {
+	stdout  io.Writer
+	opts    []filter.StreamOption
+	records map[string]*filter.RecordWriter
+	writers map[string]*bufio.Writer
+	files   []*os.File
+}
+
+func newRouteOutputs(stdout io.Writer, opts []filter.StreamOption) *routeOutputs {
+	return &routeOutputs{
+		stdout:  stdout,
+		opts:    opts,
+		records: map[string]*filter.RecordWriter{},
+		writers: map[string]*bufio.Writer{},
+	}
+}
+
+func (o *routeOutputs) open(path string) (*filter.RecordWriter, error) {
+	if out, ok := o.records[path]; ok {
+		return out, nil
+	}
+
+	file := o.stdout
+	if path != stdoutPath {
+		created, err := os.Create(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "open output error")
+		}
+		o.files = append(o.files, created)
+		file = created
+	}
+
+	writer := bufio.NewWriter(file)
+	o.writers[path] = writer
+	out, err := filter.NewRecordWriter(writer, o.opts...)
+	if err != nil {
+		return nil, err
+	}
+	o.records[path] = out
+	return out, nil
+}
+
+// close flushes all outputs and closes files
+func (o *routeOutputs) close() error {
+	var res error
+	for path, out := range o.writers {
+		if err := out.Flush(); err != nil && res == nil {
+			res = errors.Wrapf(err, "write output '%s' error", path)
+		}
+	}
+	for _, file := range o.files {
+		if err := file.Close(); err != nil && res == nil {
+			res = errors.Wrapf(err, "close output '%s' error", file.Name())
+		}
+	}
+	return res
+}
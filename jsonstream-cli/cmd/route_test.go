@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// parseArgs registers flags of command by init and parses arguments of the command
+func parseArgs(t *testing.T, init func(cmd *kingpin.CmdClause), args ...string) bool {
+	app := kingpin.New("jsonstream", "")
+	init(app.Command("cmd", ""))
+	_, err := app.Parse(append([]string{"cmd"}, args...))
+	return assert.NoError(t, err)
+}
+
+func TestSplitNamed(t *testing.T) {
+	cases := []struct {
+		name          string
+		value         string
+		expectedName  string
+		expectedValue string
+		expectedErr   string
+	}{
+		{
+			name:          "Condition with operators",
+			value:         "errors=level = error",
+			expectedName:  "errors",
+			expectedValue: "level = error",
+		},
+		{
+			name:          "Only the first equal sign splits",
+			value:         "a=b=c",
+			expectedName:  "a",
+			expectedValue: "b=c",
+		},
+		{
+			name:        "Missing equal sign",
+			value:       "errors",
+			expectedErr: "invalid --rule 'errors', expected NAME=VALUE",
+		},
+		{
+			name:        "Empty name",
+			value:       "=level = error",
+			expectedErr: "invalid --rule '=level = error', expected NAME=VALUE",
+		},
+		{
+			name:        "Empty value",
+			value:       "errors=",
+			expectedErr: "invalid --rule 'errors=', expected NAME=VALUE",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			name, value, err := splitNamed(testCase.value, "rule")
+			if testCase.expectedErr != "" {
+				assert.EqualError(t, err, testCase.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedName, name)
+			assert.Equal(t, testCase.expectedValue, value)
+		})
+	}
+}
+
+func TestRouteCommand_OpenRoutes(t *testing.T) {
+	cases := []struct {
+		name        string
+		rules       []string
+		outs        []string
+		expectedErr string
+	}{
+		{
+			name:  "Rules share output",
+			rules: []string{"errors=level = error", "api=service = api"},
+			outs:  []string{"api=-", "errors=-"},
+		},
+		{
+			name:        "Missing output",
+			rules:       []string{"errors=level = error", "api=service = api"},
+			outs:        []string{"errors=-"},
+			expectedErr: "missing output of rule 'api'",
+		},
+		{
+			name:        "Duplicate output",
+			rules:       []string{"errors=level = error"},
+			outs:        []string{"errors=-", "errors=errors.ndjson"},
+			expectedErr: "duplicate output of rule 'errors'",
+		},
+		{
+			name:        "Output of unknown rule",
+			rules:       []string{"errors=level = error"},
+			outs:        []string{"errors=-", "api=-"},
+			expectedErr: "output of unknown rule 'api'",
+		},
+		{
+			name:        "Invalid output",
+			rules:       []string{"errors=level = error"},
+			outs:        []string{"errors"},
+			expectedErr: "invalid --out 'errors', expected NAME=VALUE",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			command := &RouteCommand{rules: testCase.rules, outs: testCase.outs}
+			var out bytes.Buffer
+			routes, err := command.openRoutes(newRouteOutputs(&out, nil))
+			if testCase.expectedErr != "" {
+				assert.EqualError(t, err, testCase.expectedErr)
+				return
+			}
+
+			if assert.NoError(t, err) && assert.Len(t, routes, 2) {
+				// outputs are opened once per path
+				assert.True(t, routes["errors"] == routes["api"])
+			}
+		})
+	}
+}
+
+func TestRouteCommand_Run(t *testing.T) {
+	input := `{"level": "error", "service": "api"}` + "\n" +
+		`{"level": "info", "service": "api"}` + "\n" +
+		`{"level": "info", "service": "db"}` + "\n"
+	lines := strings.SplitAfter(input, "\n")
+
+	dir := t.TempDir()
+	errorsPath := filepath.Join(dir, "errors.ndjson")
+	apiPath := filepath.Join(dir, "api.ndjson")
+
+	cases := []struct {
+		name           string
+		args           []string
+		expectedStdout string
+		expectedFiles  map[string]string
+	}{
+		{
+			name: "All satisfied rules sharing output write element once",
+			args: []string{"--rule", "errors=level = error", "--rule", "api=service = api",
+				"--out", "errors=-", "--out", "api=-", "--default=-", "--mode", "all"},
+			expectedStdout: input,
+		},
+		{
+			name: "All satisfied rules",
+			args: []string{"--rule", "errors=level = error", "--rule", "api=service = api",
+				"--out", "errors=" + errorsPath, "--out", "api=-", "--mode", "all"},
+			expectedStdout: lines[0] + lines[1],
+			expectedFiles:  map[string]string{errorsPath: lines[0]},
+		},
+		{
+			name: "The first satisfied rule",
+			args: []string{"--rule", "errors=level = error", "--rule", "api=service = api",
+				"--out", "errors=" + errorsPath, "--out", "api=" + apiPath, "--default=-"},
+			expectedStdout: lines[2],
+			expectedFiles:  map[string]string{errorsPath: lines[0], apiPath: lines[1]},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			command := NewRoute()
+			if !parseArgs(t, command.InitArgs, testCase.args...) {
+				return
+			}
+
+			var out bytes.Buffer
+			assert.NoError(t, command.run(strings.NewReader(input), &out))
+			assert.Equal(t, testCase.expectedStdout, out.String())
+			for path, expected := range testCase.expectedFiles {
+				content, err := os.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(content))
+			}
+		})
+	}
+}
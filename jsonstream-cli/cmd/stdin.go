@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+)
+
+// openStdin checks that stdin is a pipe and returns reader of it
+func openStdin() (io.Reader, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "check stdin stat error")
+	}
+
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return nil, errors.New("The command is intended to work with pipes")
+	}
+
+	return os.Stdin, nil
+}
+
+// printParseError prints position of condition parsing error to stderr,
+// kingpin prints error message in one line only
+func printParseError(err error) {
+	// errors.Cause can't be used as it goes deeper than ParseError to its cause
+	for err != nil {
+		if perr, ok := err.(*filter.ParseError); ok {
+			fmt.Fprintln(os.Stderr, perr.Snippet())
+			return
+		}
+
+		wrapper, ok := err.(interface{ Cause() error })
+		if !ok {
+			return
+		}
+		err = wrapper.Cause()
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/translate"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// TranslateCommand represents command to translate condition into query of database
+type TranslateCommand struct {
+	condition *conditionFlags
+	target    string
+	column    string
+}
+
+// NewTranslate constructs TranslateCommand
+func NewTranslate() *TranslateCommand {
+	return &TranslateCommand{
+		condition: newConditionFlags(),
+	}
+}
+
+// InitArgs initialize arguments and flags to run command
+func (c *TranslateCommand) InitArgs(cmd *kingpin.CmdClause) {
+	c.condition.init(cmd)
+
+	cmd.Flag("to", "target of translation: postgres (WHERE clause over jsonb), sqlite (WHERE clause over json text) or mongo (filter document)").
+		Required().
+		EnumVar(&c.target, translate.Targets...)
+
+	cmd.Flag("column", "column storing elements in SQL queries").
+		Default(translate.DefaultColumn).
+		StringVar(&c.column)
+}
+
+// Run handles command execution
+func (c *TranslateCommand) Run(_ *kingpin.ParseContext) error {
+	return c.run(os.Stdout)
+}
+
+// run writes translated condition to stdout
+func (c *TranslateCommand) run(stdout io.Writer) error {
+	cond, err := c.condition.build()
+	if err != nil {
+		return err
+	}
+
+	res, err := translate.Translate(*cond, c.target, translate.WithColumn(c.column))
+	if err != nil {
+		return errors.Wrap(err, "translate condition error")
+	}
+
+	_, err = fmt.Fprintln(stdout, res)
+	return err
+}
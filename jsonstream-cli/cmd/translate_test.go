@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateCommand_Run(t *testing.T) {
+	cases := []struct {
+		name        string
+		args        []string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "Mongo filter",
+			args:     []string{"--to", "mongo", "--condition", "name = John"},
+			expected: `{"name":{"$eq":"John"}}` + "\n",
+		},
+		{
+			name:     "Mongo filter with typed variables",
+			args:     []string{"--to", "mongo", "--condition", "age > $age and flag = $flag", "--var", "age=5", "--var", "flag=true"},
+			expected: `{"$and":[{"age":{"$gt":5}},{"flag":{"$eq":true}}]}` + "\n",
+		},
+		{
+			name:     "SQLite clause over custom column",
+			args:     []string{"--to", "sqlite", "--column", "body", "--condition", "exists(job)"},
+			expected: "json_type(body, '$.\"job\"') IS NOT NULL\n",
+		},
+		{
+			name:        "Untranslatable condition",
+			args:        []string{"--to", "sqlite", "--condition", "jsonpath('$.a')"},
+			expectedErr: "translate condition error",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			command := NewTranslate()
+			if !parseArgs(t, command.InitArgs, testCase.args...) {
+				return
+			}
+
+			var out bytes.Buffer
+			err := command.run(&out)
+			if testCase.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), testCase.expectedErr)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, out.String())
+		})
+	}
+}
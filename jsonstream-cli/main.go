@@ -14,5 +14,17 @@ func main() {
 	filterCmd := app.Command("filter", "Filters json stream by conditions").Action(filterCommand.Run)
 	filterCommand.InitArgs(filterCmd)
 
+	queryCommand := cmd.NewQuery()
+	queryCmd := app.Command("query", "Runs SQL-like query over json stream").Action(queryCommand.Run)
+	queryCommand.InitArgs(queryCmd)
+
+	translateCommand := cmd.NewTranslate()
+	translateCmd := app.Command("translate", "Translates condition into SQL WHERE clause or Mongo filter").Action(translateCommand.Run)
+	translateCommand.InitArgs(translateCmd)
+
+	routeCommand := cmd.NewRoute()
+	routeCmd := app.Command("route", "Writes elements of json stream to outputs by rules").Action(routeCommand.Run)
+	routeCommand.InitArgs(routeCmd)
+
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 }
@@ -0,0 +1,275 @@
+package filter
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// arrayReader splits array into its elements without reading of the whole array, so memory is bounded
+// by the largest element. Array may be nested into objects, path of keys leads to it then.
+// Stream isn't read after the end of array
+type arrayReader struct {
+	values *valueReader
+	path   []string
+	// entered means that elements of array are being read, over means the end of array
+	entered bool
+	over    bool
+	// separated means that separator of the next element is read with malformed element
+	separated bool
+	// err is an error of structure of array, elements aren't read after it
+	err error
+}
+
+func newArrayReader(reader *bufio.Reader, maxSize int, path string) *arrayReader {
+	r := &arrayReader{values: newValueReader(reader, maxSize)}
+	if path != "" && path != "@" {
+		r.path = strings.Split(path, ".")
+	}
+	return r
+}
+
+func (r *arrayReader) position() (int64, int64) {
+	return r.values.position()
+}
+
+func (r *arrayReader) buffered() int {
+	return r.values.buffered()
+}
+
+// next returns the next element of array, elements larger than maximum size are read up to their end
+// and give ErrRecordTooLarge. Malformed elements are skipped up to the next element and give ErrMalformedValue.
+// Broken structure of array stops reading
+func (r *arrayReader) next() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.over {
+		return nil, io.EOF
+	}
+
+	elem, err := r.nextElem()
+	if err != nil && err != io.EOF && !isRecordError(err) {
+		r.err = err
+	}
+	return elem, err
+}
+
+func (r *arrayReader) nextElem() ([]byte, error) {
+	if !r.entered {
+		if err := r.enter(); err != nil {
+			return nil, err
+		}
+		r.entered = true
+
+		if c, err := r.peekByte(); err != nil || c != ']' {
+			return r.readElem(err)
+		}
+	} else if r.separated {
+		r.separated = false
+		return r.readElem(nil)
+	} else {
+		c, err := r.expect(",]")
+		if err != nil || c == ',' {
+			return r.readElem(err)
+		}
+	}
+
+	r.values.discard(1)
+	r.over = true
+	return nil, io.EOF
+}
+
+func (r *arrayReader) readElem(err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.peekByte(); err != nil {
+		return nil, err
+	}
+
+	r.values.num++
+	r.values.start = r.values.offset
+	elem, err := r.values.readValue(true)
+	if err != nil && errors.Cause(err) == ErrMalformedValue {
+		if skipErr := r.skipBroken(); skipErr != nil {
+			return nil, errors.Errorf("array is broken, element %d: %s", r.values.num, err)
+		}
+		return nil, errors.Wrapf(err, "element %d of array", r.values.num)
+	}
+	return elem, err
+}
+
+// skipBroken drops the rest of malformed element up to separator of the next element or the end of array.
+// Brackets and strings are tracked from the byte which broke element, unmatched closing bracket closes
+// all brackets opened before it
+func (r *arrayReader) skipBroken() error {
+	closings := append([]byte{}, r.values.scanner.closings...)
+	inString, escaped := r.values.scanner.inString, false
+	// handle returns true when separator or the end of array is found
+	handle := func(c byte) bool {
+		switch {
+		case inString && escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case inString && c == '"':
+			inString = false
+		case inString:
+		case c == '"':
+			inString = true
+		case c == '{':
+			closings = append(closings, '}')
+		case c == '[':
+			closings = append(closings, ']')
+		case c == '}' || c == ']':
+			matched := false
+			for len(closings) > 0 && !matched {
+				matched = closings[len(closings)-1] == c
+				closings = closings[:len(closings)-1]
+			}
+			if !matched && c == ']' {
+				r.over = true
+				return true
+			}
+		case c == ',' && len(closings) == 0:
+			r.separated = true
+			return true
+		}
+		return false
+	}
+
+	if handle(r.values.brokenBy) {
+		return nil
+	}
+	for {
+		chunk, err := r.values.peek()
+		if err == io.EOF {
+			return errors.Errorf("unexpected end of stream at offset %d", r.values.offset)
+		}
+		if err != nil {
+			return errors.Wrap(err, "read record error")
+		}
+
+		for i, c := range chunk {
+			if handle(c) {
+				r.values.discard(i + 1)
+				return nil
+			}
+		}
+		r.values.discard(len(chunk))
+	}
+}
+
+// enter reads stream up to the first element of array, values of other keys are skipped
+func (r *arrayReader) enter() error {
+	for _, key := range r.path {
+		if _, err := r.expect("{"); err != nil {
+			return errors.Wrapf(err, "path '%s' isn't found", strings.Join(r.path, "."))
+		}
+		if err := r.findKey(key); err != nil {
+			return errors.Wrapf(err, "path '%s' isn't found", strings.Join(r.path, "."))
+		}
+	}
+
+	_, err := r.expect("[")
+	return errors.Wrap(err, "array isn't found")
+}
+
+// findKey reads object up to value of key
+func (r *arrayReader) findKey(key string) error {
+	c, err := r.peekByte()
+	for ; err == nil && c != '}'; c, err = r.peekByte() {
+		var name string
+		if _, err = r.expect("\""); err != nil {
+			return err
+		}
+		if name, err = r.readKey(); err != nil {
+			return err
+		}
+		if _, err = r.expect(":"); err != nil {
+			return err
+		}
+		if name == key {
+			return nil
+		}
+
+		if _, err = r.peekByte(); err != nil {
+			return err
+		}
+		if _, err = r.values.readValue(false); err != nil {
+			return err
+		}
+		if c, err = r.expect(",}"); err != nil {
+			return err
+		}
+		if c == '}' {
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	return errors.Errorf("key '%s' is missing", key)
+}
+
+// readKey reads and decodes key of object, opening quote is already read
+func (r *arrayReader) readKey() (string, error) {
+	r.values.scanner.reset()
+	r.values.scanner.started, r.values.scanner.inString = true, true
+	raw := []byte{'"'}
+	for {
+		chunk, err := r.values.peek()
+		if err == io.EOF {
+			return "", errors.Wrap(ErrMalformedValue, "unexpected end of stream in key")
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "read record error")
+		}
+
+		n, done, err := r.values.scanner.scan(chunk)
+		raw = append(raw, chunk[:n]...)
+		r.values.discard(n)
+		if err != nil {
+			return "", err
+		}
+		if done {
+			var key string
+			err := json.Unmarshal(raw, &key)
+			return key, errors.Wrap(err, "parse key error")
+		}
+	}
+}
+
+// expect skips whitespaces and reads one of bytes
+func (r *arrayReader) expect(chars string) (byte, error) {
+	c, err := r.peekByte()
+	if err != nil {
+		return 0, err
+	}
+	if !strings.ContainsRune(chars, rune(c)) {
+		return 0, errors.Errorf("unexpected '%c' at offset %d, expected one of '%s'", c, r.values.offset, chars)
+	}
+
+	r.values.discard(1)
+	return c, nil
+}
+
+// peekByte skips whitespaces and returns the next byte without reading of it
+func (r *arrayReader) peekByte() (byte, error) {
+	if err := r.values.skipSpaces(); err == io.EOF {
+		return 0, errors.Errorf("unexpected end of stream at offset %d", r.values.offset)
+	} else if err != nil {
+		return 0, err
+	}
+
+	chunk, err := r.values.peek()
+	if err != nil {
+		return 0, errors.Wrap(err, "read record error")
+	}
+	return chunk[0], nil
+}
@@ -0,0 +1,188 @@
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PathBuilder builds conditions on value found by path.
+// Values passed to it are used as is, so building conditions from user input is safe
+type PathBuilder struct {
+	fn   Function
+	path string
+}
+
+// Path starts building of condition on value found by path
+func Path(path string) PathBuilder {
+	return PathBuilder{path: path}
+}
+
+// Len makes condition to compare length of value instead of value itself
+func (b PathBuilder) Len() PathBuilder {
+	return PathBuilder{fn: FuncLen, path: b.path}
+}
+
+// Lower makes condition to compare value in lower case
+func (b PathBuilder) Lower() PathBuilder {
+	return PathBuilder{fn: FuncLower, path: b.path}
+}
+
+// Upper makes condition to compare value in upper case
+func (b PathBuilder) Upper() PathBuilder {
+	return PathBuilder{fn: FuncUpper, path: b.path}
+}
+
+// Eq builds condition "path = value"
+func (b PathBuilder) Eq(value interface{}) Condition {
+	return b.compare(OpEq, value)
+}
+
+// NotEq builds condition "path != value"
+func (b PathBuilder) NotEq(value interface{}) Condition {
+	return b.compare(OpNotEq, value)
+}
+
+// Lt builds condition "path < value"
+func (b PathBuilder) Lt(value interface{}) Condition {
+	return b.compare(OpLt, value)
+}
+
+// Lte builds condition "path <= value"
+func (b PathBuilder) Lte(value interface{}) Condition {
+	return b.compare(OpLte, value)
+}
+
+// Gt builds condition "path > value"
+func (b PathBuilder) Gt(value interface{}) Condition {
+	return b.compare(OpGt, value)
+}
+
+// Gte builds condition "path >= value"
+func (b PathBuilder) Gte(value interface{}) Condition {
+	return b.compare(OpGte, value)
+}
+
+// Match builds condition "path ~ pattern", pattern is a regular expression matched against strings
+func (b PathBuilder) Match(pattern string) Condition {
+	return b.like(OpLike, pattern)
+}
+
+// NotMatch builds condition "path !~ pattern"
+func (b PathBuilder) NotMatch(pattern string) Condition {
+	return b.like(OpNotLike, pattern)
+}
+
+// In builds condition that is satisfied when value found by path equals to one of values
+func (b PathBuilder) In(values ...interface{}) Condition {
+	if len(values) == 0 {
+		return Condition{err: errors.Wrapf(ErrInvalidExpr, "no values passed to In of path '%s'", b.path)}
+	}
+
+	res := b.Eq(values[0])
+	for _, value := range values[1:] {
+		res = res.Or(b.Eq(value))
+	}
+
+	return res
+}
+
+// Exists builds condition "exists(path)"
+func (b PathBuilder) Exists() Condition {
+	if b.fn != FuncNone {
+		return Condition{err: errors.Wrapf(ErrInvalidExpr, "exists can't be applied to result of %s", b.fn.String())}
+	}
+
+	return Condition{expr: &ExistsExpr{Path: b.path}}
+}
+
+// Any builds condition "any(path, cond)" that is satisfied by array having an element satisfying cond
+func (b PathBuilder) Any(cond Condition) Condition {
+	if b.fn != FuncNone {
+		return Condition{err: errors.Wrapf(ErrInvalidExpr, "any can't be applied to result of %s", b.fn.String())}
+	}
+	if err := cond.Err(); err != nil {
+		return Condition{err: err}
+	}
+
+	return Condition{expr: &AnyExpr{Path: b.path, Operand: cond.expr}}
+}
+
+func (b PathBuilder) like(op Operator, pattern string) Condition {
+	expr, err := newLikeExpr(b.fn, b.path, op, pattern)
+	if err != nil {
+		return Condition{err: errors.Wrapf(err, "invalid pattern of path '%s'", b.path)}
+	}
+
+	return Condition{expr: expr}
+}
+
+func (b PathBuilder) compare(op Operator, value interface{}) Condition {
+	res, typ, err := formatValue(value)
+	if err == nil && typ == TypeBool && op != OpEq && op != OpNotEq {
+		err = errors.Wrapf(ErrUnsupportedOperator, "value of path '%s' is boolean, passed %s", b.path, op.String())
+	}
+	if err != nil {
+		return Condition{err: errors.Wrapf(err, "invalid value of path '%s'", b.path)}
+	}
+
+	return Condition{expr: &CompareExpr{Func: b.fn, Path: b.path, Operator: op, Value: res}}
+}
+
+// And joins condition with others by "and" logic
+func (c Condition) And(others ...Condition) Condition {
+	return c.join(LogicalAnd, others)
+}
+
+// Or joins condition with others by "or" logic
+func (c Condition) Or(others ...Condition) Condition {
+	return c.join(LogicalOr, others)
+}
+
+// Const builds condition that is satisfied by any element (true value) or by none (false value)
+func Const(value bool) Condition {
+	return Condition{expr: &ConstExpr{Value: value}}
+}
+
+// Not negates condition
+func Not(c Condition) Condition {
+	if err := c.Err(); err != nil {
+		return Condition{err: err}
+	}
+
+	return Condition{expr: &NotExpr{Operand: c.expr}}
+}
+
+// Err returns error that appeared while building condition
+func (c Condition) Err() error {
+	if c.err == nil && c.expr == nil {
+		return errors.Wrap(ErrInvalidExpr, "empty condition")
+	}
+
+	return c.err
+}
+
+// join appends others to operands of condition if it's already joined by the same operator,
+// so chain a.And(b).And(c) gives the same tree as parsing of "a and b and c"
+func (c Condition) join(op LogicalOperator, others []Condition) Condition {
+	for _, cond := range append([]Condition{c}, others...) {
+		if err := cond.Err(); err != nil {
+			return Condition{err: err}
+		}
+	}
+
+	var operands []Expr
+	if logical, ok := c.expr.(*LogicalExpr); ok && logical.Operator == op {
+		operands = append(operands, logical.Operands...)
+	} else {
+		operands = append(operands, c.expr)
+	}
+
+	for _, other := range others {
+		operands = append(operands, other.expr)
+	}
+
+	if len(operands) == 1 {
+		return c
+	}
+
+	return Condition{expr: &LogicalExpr{Operator: op, Operands: operands}}
+}
@@ -0,0 +1,114 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathBuilder_SameAsParser(t *testing.T) {
+	cases := []struct {
+		name      string
+		built     filter.Condition
+		inputExpr string
+	}{
+		{
+			name:      "Simple comparison",
+			built:     filter.Path("job.company").Eq("Some firm"),
+			inputExpr: "job.company = 'Some firm'",
+		},
+		{
+			name:      "Chain of and",
+			built:     filter.Path("a").Gt(5).And(filter.Path("b").Lte(2.5)).And(filter.Path("c").NotEq(true)),
+			inputExpr: "a > 5 and b <= 2.5 and c != true",
+		},
+		{
+			name:      "Nested or",
+			built:     filter.Path("a").Lt(int64(-1)).And(filter.Path("b").Eq("x").Or(filter.Path("c").Gte(uint8(3)))),
+			inputExpr: "a < -1 and (b = x or c >= 3)",
+		},
+		{
+			name:      "Functions and not",
+			built:     filter.Not(filter.Path("error").Exists()).And(filter.Path("tags").Len().Gt(1), filter.Path("name").Lower().Eq("ann")),
+			inputExpr: "not exists(error) and len(tags) > 1 and lower(name) = ann",
+		},
+		{
+			name:      "In",
+			built:     filter.Path("status").In(500, 502, "503"),
+			inputExpr: "status = 500 or status = 502 or status = 503",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			parsed, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, testCase.built.Err())
+			assert.Equal(t, parsed.Expr(), testCase.built.Expr())
+		})
+	}
+}
+
+func TestPathBuilder_ValuesAreNotParsed(t *testing.T) {
+	value := `'x' or a = 1 ) "`
+	condition := filter.Path("name").Eq(value)
+	assert.Equal(t, value, condition.Value())
+
+	_, isOk, err := filter.ProcessElem(condition, []byte(`{"name": "'x' or a = 1 ) \""}`))
+	assert.NoError(t, err)
+	assert.True(t, isOk)
+
+	reparsed, err := filter.NewConditionFromStr(condition.String())
+	if assert.NoError(t, err) {
+		assert.Equal(t, condition.Expr(), reparsed.Expr())
+	}
+}
+
+func TestPathBuilder_Negative(t *testing.T) {
+	cases := []struct {
+		name          string
+		built         filter.Condition
+		expectedCause error
+	}{
+		{
+			name:          "Boolean with ordering operator",
+			built:         filter.Path("a").Gt(true),
+			expectedCause: filter.ErrUnsupportedOperator,
+		},
+		{
+			name:          "Unsupported type",
+			built:         filter.Path("a").Eq(struct{}{}),
+			expectedCause: filter.ErrUnsupportedType,
+		},
+		{
+			name:          "Error in joined condition",
+			built:         filter.Path("a").Eq(1).Or(filter.Path("b").Eq([]int{1})),
+			expectedCause: filter.ErrUnsupportedType,
+		},
+		{
+			name:          "Empty condition",
+			built:         filter.Not(filter.Condition{}),
+			expectedCause: filter.ErrInvalidExpr,
+		},
+		{
+			name:          "Empty in",
+			built:         filter.Path("a").In(),
+			expectedCause: filter.ErrInvalidExpr,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedCause, errors.Cause(testCase.built.Err()))
+
+			_, isOk, err := filter.ProcessElem(testCase.built, []byte(`{"a": 1}`))
+			assert.False(t, isOk)
+			assert.Equal(t, testCase.expectedCause, errors.Cause(err))
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package filter
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MatchResult is a result of matching element with values captured by satisfied sub-expressions
+type MatchResult struct {
+	IsOk bool
+	// Captures are collected only for satisfied element, in order of evaluation
+	Captures []Capture
+}
+
+// Capture is a value which satisfied sub-expression of condition
+type Capture struct {
+	Expr string `json:"expr"`
+	// Path is concrete path to the value including indexes of arrays, e.g. children[1].age.
+	// It's a query for JSONPath sub-expressions, then value is a list of selected nodes
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+	// Groups are named groups of regular expression matched by the value
+	Groups map[string]string `json:"groups,omitempty"`
+}
+
+// MatchCaptures checks element like Match does and returns values which satisfied sub-expressions:
+// matched elements of arrays, values of paths and named groups of regular expressions
+func (p *Program) MatchCaptures(elem []byte) (MatchResult, error) {
+	if p.prefilter.rejects(elem) {
+		return MatchResult{}, nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(elem, &data); err != nil {
+		return MatchResult{}, errors.Wrap(err, "parse json error")
+	}
+
+	var captures []Capture
+	isOk, err := captureExpr(data, "", p.expr, &captures)
+	if err != nil || !isOk {
+		return MatchResult{}, errors.Wrap(err, "error check path")
+	}
+
+	return MatchResult{IsOk: true, Captures: captures}, nil
+}
+
+// captureExpr evaluates expression like checkExpr and appends captures of satisfied sub-expressions.
+// Prefix is a concrete path of checked element
+func captureExpr(data interface{}, prefix string, expr Expr, captures *[]Capture) (bool, error) {
+	switch e := expr.(type) {
+	case *CompareExpr:
+		isOk, err := checkExpr(decodedDoc{data: data}, e)
+		if err != nil || !isOk {
+			return false, err
+		}
+		return true, captureCompare(data, prefix, e, captures)
+	case *ExistsExpr:
+		val, found := lookupDecoded(data, e.Path)
+		if !found {
+			return false, nil
+		}
+		*captures = append(*captures, Capture{Expr: exprString(e), Path: concretePath(prefix, e.Path), Value: val})
+		return true, nil
+	case *AnyExpr:
+		return captureAny(data, prefix, e, captures)
+	case *JSONPathExpr:
+		query, err := e.query()
+		if err != nil {
+			return false, errors.Wrap(err, "invalid JSONPath query")
+		}
+		nodes := query.eval(&jsonPathContext{root: data, current: data})
+		if len(nodes) == 0 {
+			return false, nil
+		}
+		*captures = append(*captures, Capture{Expr: exprString(e), Path: e.Query, Value: nodes})
+		return true, nil
+	case *LogicalExpr:
+		// captures of "or" operand are kept only if it is satisfied, all operands of satisfied "and" are kept
+		stopOn := e.Operator == LogicalOr
+		start := len(*captures)
+		for _, operand := range e.Operands {
+			operandStart := len(*captures)
+			isOk, err := captureExpr(data, prefix, operand, captures)
+			if err != nil {
+				return false, err
+			}
+			if !isOk {
+				*captures = (*captures)[:operandStart]
+			}
+			if isOk == stopOn {
+				if !stopOn {
+					*captures = (*captures)[:start]
+				}
+				return stopOn, nil
+			}
+		}
+		return !stopOn, nil
+	case *NotExpr:
+		// satisfied negation has nothing to capture
+		var discarded []Capture
+		isOk, err := captureExpr(data, prefix, e.Operand, &discarded)
+		return !isOk && err == nil, err
+	default:
+		return checkExpr(decodedDoc{data: data}, expr)
+	}
+}
+
+// captureCompare finds the first value by path which satisfies comparison, it's the one decided result of checkExpr
+func captureCompare(data interface{}, prefix string, e *CompareExpr, captures *[]Capture) error {
+	capture := Capture{Expr: exprString(e)}
+	if e.Func == FuncLen {
+		capture.Path = concretePath(prefix, e.Path)
+		capture.Value, _ = lookupDecoded(data, e.Path)
+		*captures = append(*captures, capture)
+		return nil
+	}
+
+	values, _ := resolvePath(data, prefix, e.Path)
+	for _, leaf := range leafValues(values, nil) {
+		checkVal, err := applyFunction(e.Func, leaf.value)
+		if err != nil {
+			return err
+		}
+
+		isOk, err := chechkValue(checkVal, *e)
+		if err != nil {
+			return err
+		}
+		if !isOk {
+			continue
+		}
+
+		capture.Path, capture.Value = leaf.path, leaf.value
+		if str, ok := checkVal.(string); ok && e.Operator == OpLike {
+			capture.Groups, err = namedGroups(e, str)
+			if err != nil {
+				return err
+			}
+		}
+		*captures = append(*captures, capture)
+		return nil
+	}
+
+	return nil
+}
+
+func namedGroups(e *CompareExpr, str string) (map[string]string, error) {
+	re, err := e.regexp()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups map[string]string
+	match := re.FindStringSubmatchIndex(str)
+	for i, name := range re.SubexpNames() {
+		if name == "" || match == nil || match[2*i] < 0 {
+			continue
+		}
+		if groups == nil {
+			groups = map[string]string{}
+		}
+		groups[name] = str[match[2*i]:match[2*i+1]]
+	}
+
+	return groups, nil
+}
+
+func captureAny(data interface{}, prefix string, e *AnyExpr, captures *[]Capture) (bool, error) {
+	val, _ := lookupDecoded(data, e.Path)
+	elems, isArray := val.([]interface{})
+	if !isArray {
+		return false, nil
+	}
+
+	values, throughArray := resolvePath(data, prefix, e.Path)
+	for i, elem := range elems {
+		// array found by path going through arrays consists of values found in their elements
+		elemPath := concretePath(prefix, e.Path) + "[" + strconv.Itoa(i) + "]"
+		if !throughArray && len(values) == 1 {
+			elemPath = values[0].path + "[" + strconv.Itoa(i) + "]"
+		} else if throughArray && len(values) == len(elems) {
+			elemPath = values[i].path
+		}
+
+		start := len(*captures)
+		*captures = append(*captures, Capture{Expr: exprString(e), Path: elemPath, Value: elem})
+		isOk, err := captureExpr(elem, elemPath, e.Operand, captures)
+		if err != nil {
+			return false, errors.Wrapf(err, "error check elems of array in path '%s'", e.Path)
+		}
+
+		if isOk {
+			return true, nil
+		}
+		*captures = (*captures)[:start]
+	}
+
+	return false, nil
+}
+
+// pathValue is a value found by path with concrete path to it
+type pathValue struct {
+	path  string
+	value interface{}
+}
+
+// resolvePath finds values by path the same way as lookupDecoded does, path going through arrays gives value
+// for every element of them
+func resolvePath(data interface{}, prefix string, path string) (values []pathValue, throughArray bool) {
+	if path == selfPath {
+		return []pathValue{{path: concretePath(prefix, path), value: data}}, false
+	}
+
+	var resolve func(val interface{}, valPath string, keys []string)
+	resolve = func(val interface{}, valPath string, keys []string) {
+		for i, key := range keys {
+			switch v := val.(type) {
+			case map[string]interface{}:
+				var ok bool
+				if val, ok = v[key]; !ok {
+					return
+				}
+				valPath = concretePath(valPath, key)
+			case []interface{}:
+				throughArray = true
+				for j, elem := range v {
+					resolve(elem, valPath+"["+strconv.Itoa(j)+"]", keys[i:])
+				}
+				return
+			default:
+				return
+			}
+		}
+		values = append(values, pathValue{path: valPath, value: val})
+	}
+
+	resolve(data, prefix, strings.Split(path, "."))
+	return values, throughArray
+}
+
+// leafValues expands arrays to their elements like chechkValue does
+func leafValues(values []pathValue, res []pathValue) []pathValue {
+	for _, val := range values {
+		elems, isArray := val.value.([]interface{})
+		if !isArray {
+			res = append(res, val)
+			continue
+		}
+
+		for i, elem := range elems {
+			res = leafValues([]pathValue{{path: val.path + "[" + strconv.Itoa(i) + "]", value: elem}}, res)
+		}
+	}
+	return res
+}
+
+// concretePath joins path of element with path relative to it, "@" refers to the element itself
+func concretePath(prefix string, path string) string {
+	switch {
+	case path == selfPath && prefix == "":
+		return selfPath
+	case path == selfPath:
+		return prefix
+	case prefix == "":
+		return path
+	default:
+		return prefix + "." + path
+	}
+}
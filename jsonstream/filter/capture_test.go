@@ -0,0 +1,112 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgram_MatchCaptures(t *testing.T) {
+	elem := []byte(`{"id": 1, "name": "John", "emails": ["john@gmail.com", "john@mail.ru"],` +
+		` "children": [{"name": "Alex", "age": 10, "toys": ["car"]}, {"name": "Pit", "age": 5, "toys": ["ball", "doll"]}],` +
+		` "job": {"company": "Some firm"}}`)
+
+	cases := []struct {
+		name             string
+		inputExpr        string
+		expectedCaptures []filter.Capture
+	}{
+		{
+			name:      "Matched element of array",
+			inputExpr: "emails = john@mail.ru",
+			expectedCaptures: []filter.Capture{
+				{Expr: "emails = john@mail.ru", Path: "emails[1]", Value: "john@mail.ru"},
+			},
+		},
+		{
+			name:      "Named groups of regular expression",
+			inputExpr: `emails ~ "^(?P<user>[a-z]+)@(?P<domain>mail\\.ru)$|(?P<other>x)"`,
+			expectedCaptures: []filter.Capture{
+				{
+					Expr:   `emails ~ '^(?P<user>[a-z]+)@(?P<domain>mail\.ru)$|(?P<other>x)'`,
+					Path:   "emails[1]",
+					Value:  "john@mail.ru",
+					Groups: map[string]string{"user": "john", "domain": "mail.ru"},
+				},
+			},
+		},
+		{
+			name:      "Path going through arrays",
+			inputExpr: "children.age < 8 and upper(children.toys) = DOLL",
+			expectedCaptures: []filter.Capture{
+				{Expr: "children.age < 8", Path: "children[1].age", Value: float64(5)},
+				{Expr: "upper(children.toys) = DOLL", Path: "children[1].toys[1]", Value: "doll"},
+			},
+		},
+		{
+			name:      "Element of any with nested captures",
+			inputExpr: "any(children, exists(toys) and any(toys, @ = doll))",
+			expectedCaptures: []filter.Capture{
+				{Expr: "any(children, exists(toys) and any(toys, @ = doll))", Path: "children[1]", Value: map[string]interface{}{
+					"name": "Pit", "age": float64(5), "toys": []interface{}{"ball", "doll"}}},
+				{Expr: "exists(toys)", Path: "children[1].toys", Value: []interface{}{"ball", "doll"}},
+				{Expr: "any(toys, @ = doll)", Path: "children[1].toys[1]", Value: "doll"},
+				{Expr: "@ = doll", Path: "children[1].toys[1]", Value: "doll"},
+			},
+		},
+		{
+			name:      "Only satisfied operand of or is captured",
+			inputExpr: "(id = 1 and name = Jack) or job.company ~ firm or len(emails) = 2",
+			expectedCaptures: []filter.Capture{
+				{Expr: "job.company ~ firm", Path: "job.company", Value: "Some firm"},
+			},
+		},
+		{
+			name:      "Negation and constants capture nothing",
+			inputExpr: "not id = 2 and true and len(emails) = 2",
+			expectedCaptures: []filter.Capture{
+				{Expr: "len(emails) = 2", Path: "emails", Value: []interface{}{"john@gmail.com", "john@mail.ru"}},
+			},
+		},
+		{
+			name:      "JSONPath",
+			inputExpr: "jsonpath('$.children[?@.age > 5].name')",
+			expectedCaptures: []filter.Capture{
+				{Expr: "jsonpath('$.children[?@.age > 5].name')", Path: "$.children[?@.age > 5].name", Value: []interface{}{"Alex"}},
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cond, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			program, err := filter.Compile(*cond)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			result, err := program.MatchCaptures(elem)
+			assert.NoError(t, err)
+			assert.True(t, result.IsOk)
+			assert.Equal(t, testCase.expectedCaptures, result.Captures)
+		})
+	}
+}
+
+func TestProgram_MatchCaptures_NotMatched(t *testing.T) {
+	program, err := filter.Compile(filter.Path("id").Eq(1).And(filter.Path("name").Eq("Jack")))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	result, err := program.MatchCaptures([]byte(`{"id": 1, "name": "John"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, filter.MatchResult{}, result)
+
+	_, err = program.MatchCaptures([]byte(`{"id": 1,`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,254 @@
+package filter
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// valueReader splits stream into json values by their structure: values may span many lines, follow each other
+// or be separated by whitespaces. Content of values isn't validated, only brackets and strings are tracked.
+// After malformed value the rest of its line and following lines are dropped up to the line starting
+// with object or array, so reading is resumed from the next object or array written from the beginning of line
+type valueReader struct {
+	reader  *bufio.Reader
+	maxSize int
+	buf     []byte
+	scanner valueScanner
+	// num is a number of the last read value starting from 1
+	num int64
+	// offset is a count of bytes read from stream, start is an offset of the last read value
+	offset int64
+	start  int64
+	// brokenBy is a byte which broke the last malformed value
+	brokenBy byte
+}
+
+func newValueReader(reader *bufio.Reader, maxSize int) *valueReader {
+	return &valueReader{reader: reader, maxSize: maxSize}
+}
+
+func (r *valueReader) position() (int64, int64) {
+	return r.num, r.start
+}
+
+func (r *valueReader) buffered() int {
+	return r.reader.Buffered()
+}
+
+// next returns the next value, values larger than maximum size are read up to their end
+// and give ErrRecordTooLarge
+func (r *valueReader) next() ([]byte, error) {
+	if err := r.skipSpaces(); err != nil {
+		return nil, err
+	}
+
+	r.num++
+	r.start = r.offset
+	value, err := r.readValue(true)
+	if err != nil && errors.Cause(err) == ErrMalformedValue {
+		if resyncErr := r.resync(r.brokenBy == '\n'); resyncErr != nil {
+			return nil, resyncErr
+		}
+	}
+	return value, err
+}
+
+// readValue reads value starting at current position, value which isn't kept is only skipped
+func (r *valueReader) readValue(keep bool) ([]byte, error) {
+	r.buf = r.buf[:0]
+	r.scanner.reset()
+	r.brokenBy = 0
+	tooLarge := false
+	for {
+		chunk, err := r.peek()
+		if err == io.EOF {
+			if r.scanner.scalar {
+				return r.value(r.buf, tooLarge)
+			}
+			return nil, errors.Wrapf(ErrMalformedValue, "unexpected end of stream at offset %d", r.offset)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read record error")
+		}
+
+		n, done, scanErr := r.scanner.scan(chunk)
+		value := chunk[:n]
+		if !done || len(r.buf) > 0 || tooLarge || !keep {
+			// value doesn't fit into buffer of reader, it's copied
+			if !tooLarge && keep {
+				r.buf = append(r.buf, value...)
+			}
+			if r.maxSize > 0 && len(r.buf) > r.maxSize {
+				tooLarge = true
+				r.buf = r.buf[:0]
+			}
+			value = r.buf
+		}
+		r.discard(n)
+
+		if scanErr != nil {
+			r.brokenBy = chunk[n-1]
+			return nil, errors.Wrapf(scanErr, "broken at offset %d", r.offset-1)
+		}
+		if done {
+			return r.value(value, tooLarge)
+		}
+	}
+}
+
+func (r *valueReader) value(data []byte, tooLarge bool) ([]byte, error) {
+	if tooLarge || (r.maxSize > 0 && len(data) > r.maxSize) {
+		return nil, errors.Wrapf(ErrRecordTooLarge, "maximum size is %d bytes", r.maxSize)
+	}
+	return data, nil
+}
+
+// peek returns bytes available without waiting for input, stream is read if there are no such bytes
+func (r *valueReader) peek() ([]byte, error) {
+	if r.reader.Buffered() == 0 {
+		if _, err := r.reader.Peek(1); err != nil {
+			return nil, err
+		}
+	}
+	return r.reader.Peek(r.reader.Buffered())
+}
+
+func (r *valueReader) discard(n int) {
+	discarded, _ := r.reader.Discard(n)
+	r.offset += int64(discarded)
+}
+
+// skipSpaces drops whitespaces before value, io.EOF is returned if there are no more values
+func (r *valueReader) skipSpaces() error {
+	for {
+		chunk, err := r.peek()
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return errors.Wrap(err, "read record error")
+		}
+
+		n := 0
+		for n < len(chunk) && isSpace(chunk[n]) {
+			n++
+		}
+		r.discard(n)
+		if n < len(chunk) {
+			return nil
+		}
+	}
+}
+
+// resync drops lines up to the line starting with object or array
+func (r *valueReader) resync(lineStart bool) error {
+	for {
+		if lineStart {
+			next, err := r.reader.Peek(1)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(err, "read record error")
+			}
+			if next[0] == '{' || next[0] == '[' {
+				return nil
+			}
+		}
+
+		line, err := r.reader.ReadSlice('\n')
+		r.offset += int64(len(line))
+		lineStart = err == nil
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return errors.Wrap(err, "read record error")
+		}
+	}
+}
+
+// isValueContinuation checks that byte can't start json value, but may continue it
+func isValueContinuation(c byte) bool {
+	return c == '}' || c == ']' || c == ',' || c == ':'
+}
+
+// valueScanner tracks structure of json value which is read by chunks
+type valueScanner struct {
+	// closings are expected closing brackets of nested arrays and objects
+	closings []byte
+	started  bool
+	inString bool
+	escaped  bool
+	// scalar means top level number or literal, it's over on the first byte which can't belong to it
+	scalar bool
+}
+
+func (s *valueScanner) reset() {
+	s.closings = s.closings[:0]
+	s.started, s.inString, s.escaped, s.scalar = false, false, false, false
+}
+
+// scan processes the next chunk of value. It returns count of bytes which belong to value
+// and whether value is over. On error the count includes the byte which broke structure
+func (s *valueScanner) scan(data []byte) (int, bool, error) {
+	for i, c := range data {
+		if !s.started {
+			s.started = true
+			switch {
+			case c == '"':
+				s.inString = true
+			case c == '{':
+				s.closings = append(s.closings, '}')
+			case c == '[':
+				s.closings = append(s.closings, ']')
+			case isValueContinuation(c):
+				return i + 1, false, errors.Wrapf(ErrMalformedValue, "unexpected '%c'", c)
+			default:
+				s.scalar = true
+			}
+			continue
+		}
+
+		switch {
+		case s.inString && s.escaped:
+			s.escaped = false
+		case s.inString && c == '\\':
+			s.escaped = true
+		case s.inString && c == '"':
+			s.inString = false
+			if len(s.closings) == 0 {
+				return i + 1, true, nil
+			}
+		case s.inString && c == '\n':
+			return i + 1, false, errors.Wrap(ErrMalformedValue, "unexpected line feed in string")
+		case s.inString:
+		case s.scalar:
+			if isSpace(c) || c == '"' || c == '{' || c == '[' || isValueContinuation(c) {
+				return i, true, nil
+			}
+		case c == '"':
+			s.inString = true
+		case c == '{' || c == '[':
+			if len(s.closings) >= maxNestingDepth {
+				return i + 1, false, errors.Wrap(ErrMalformedValue, "exceeded max depth")
+			}
+			if c == '{' {
+				s.closings = append(s.closings, '}')
+			} else {
+				s.closings = append(s.closings, ']')
+			}
+		case c == '}' || c == ']':
+			last := len(s.closings) - 1
+			if s.closings[last] != c {
+				return i + 1, false, errors.Wrapf(ErrMalformedValue, "unexpected '%c', expected '%c'", c, s.closings[last])
+			}
+			if s.closings = s.closings[:last]; len(s.closings) == 0 {
+				return i + 1, true, nil
+			}
+		}
+	}
+	return len(data), false, nil
+}
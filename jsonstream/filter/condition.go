@@ -1,9 +1,7 @@
 package filter
 
 import (
-	"regexp"
 	"strings"
-	"unicode"
 
 	"github.com/pkg/errors"
 )
@@ -29,8 +27,6 @@ const (
 
 var validOperators = []Operator{OpEq, OpNotEq, OpLt, OpLte, OpGt, OpGte, OpLike, OpNotLike}
 
-var conditionRegexp = regexp.MustCompile(`^\s*(.+?)\s*(!?=|=|<=?|>=?|!?~)\s*(.*?)\s*$`)
-
 func newOperator(op string) Operator {
 	res := Operator(strings.TrimSpace(op))
 	for _, validOp := range validOperators {
@@ -49,47 +45,53 @@ func (o Operator) String() string {
 
 // Condition is parsed string expression. It used to solve inclusion of stream elem
 type Condition struct {
-	path     string
-	operator Operator
-	value    string
+	expr Expr
+	// err is an error appeared while building condition by PathBuilder
+	err error
+}
+
+// Expr returns root of condition expression tree
+func (c Condition) Expr() Expr {
+	return c.expr
 }
 
-// Path returns path to left operand of condition
+// Path returns path to left operand of condition. It's empty for compound conditions
 func (c Condition) Path() string {
-	return c.path
+	if cmp, ok := c.expr.(*CompareExpr); ok {
+		return cmp.Path
+	}
+	return ""
 }
 
-// Operator returns operator of condition
+// Operator returns operator of condition. It's OpUnknown for compound conditions
 func (c Condition) Operator() Operator {
-	return c.operator
+	if cmp, ok := c.expr.(*CompareExpr); ok {
+		return cmp.Operator
+	}
+	return OpUnknown
 }
 
-// Value returns value (right operand of condition)
+// Value returns value (right operand of condition). It's empty for compound conditions
 func (c Condition) Value() string {
-	return c.value
+	if cmp, ok := c.expr.(*CompareExpr); ok {
+		return cmp.Value
+	}
+	return ""
 }
 
-// NewConditionFromStr builds condition object from string representation
-func NewConditionFromStr(conditionStr string) (*Condition, error) {
-	found := conditionRegexp.FindStringSubmatch(conditionStr)
-	if found == nil {
-		return nil, errors.New("invalid expression")
+// NewConditionFromStr builds condition object from string representation.
+// Comparisons may be combined with "and", "or", "not" and brackets,
+// values may refer to variables ($name) passed with WithVars option
+func NewConditionFromStr(conditionStr string, opts ...ParseOption) (*Condition, error) {
+	options := parseOptions{}
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	path := found[1]
-	operatorStr := found[2]
-	value := found[3]
-
-	op := newOperator(operatorStr)
-	if op == OpUnknown {
-		return nil, errors.Wrapf(ErrInvalidOperator, "found operator %s", operatorStr)
+	expr, err := newParser(conditionStr, options).parse()
+	if err != nil {
+		return nil, err
 	}
 
-	return &Condition{
-		path:     strings.TrimSpace(path),
-		operator: op,
-		value: strings.TrimFunc(value, func(c rune) bool {
-			return unicode.IsSpace(c) || c == '\'' || c == '"'
-		}),
-	}, nil
+	return &Condition{expr: expr}, nil
 }
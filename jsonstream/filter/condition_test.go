@@ -3,6 +3,7 @@ package filter_test
 import (
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/shnellpavel/json-stream/jsonstream/filter"
 	"github.com/stretchr/testify/assert"
 )
@@ -181,6 +182,35 @@ func TestNewConditionFromStr_Negative(t *testing.T) {
 			name:      "Empty operator",
 			inputExpr: "attr value",
 		},
+		{
+			name:      "Unclosed bracket",
+			inputExpr: "(attr = value",
+		},
+		{
+			name:      "Unexpected closing bracket",
+			inputExpr: "(attr = value))",
+		},
+		{
+			name:      "Dangling and",
+			inputExpr: "attr = value and",
+		},
+		{
+			name:      "Invalid regular expression",
+			inputExpr: "attr ~ '(unclosed'",
+		},
+		{
+			name:      "Any without condition",
+			inputExpr: "any(attr)",
+		},
+		{
+			name:      "Unterminated quote",
+			inputExpr: "attr = 'value",
+		},
+		{
+			// value "a and b" of single comparisons must be quoted since compound conditions are supported
+			name:      "Unquoted value with keyword",
+			inputExpr: "key = a and b",
+		},
 	}
 
 	for _, testCase := range cases {
@@ -191,3 +221,253 @@ func TestNewConditionFromStr_Negative(t *testing.T) {
 		})
 	}
 }
+
+func TestNewConditionFromStr_Compound(t *testing.T) {
+	cases := []struct {
+		name         string
+		inputExpr    string
+		expectedExpr filter.Expr
+	}{
+		{
+			name:      "And",
+			inputExpr: "attr1 = value1 and attr2 > 5",
+			expectedExpr: &filter.LogicalExpr{
+				Operator: filter.LogicalAnd,
+				Operands: []filter.Expr{
+					&filter.CompareExpr{Path: "attr1", Operator: filter.OpEq, Value: "value1"},
+					&filter.CompareExpr{Path: "attr2", Operator: filter.OpGt, Value: "5"},
+				},
+			},
+		},
+		{
+			name:      "Or with symbols",
+			inputExpr: "attr1 = value1 || attr2 > 5",
+			expectedExpr: &filter.LogicalExpr{
+				Operator: filter.LogicalOr,
+				Operands: []filter.Expr{
+					&filter.CompareExpr{Path: "attr1", Operator: filter.OpEq, Value: "value1"},
+					&filter.CompareExpr{Path: "attr2", Operator: filter.OpGt, Value: "5"},
+				},
+			},
+		},
+		{
+			name:      "And has priority over or",
+			inputExpr: "a = 1 OR b = 2 AND c = 3",
+			expectedExpr: &filter.LogicalExpr{
+				Operator: filter.LogicalOr,
+				Operands: []filter.Expr{
+					&filter.CompareExpr{Path: "a", Operator: filter.OpEq, Value: "1"},
+					&filter.LogicalExpr{
+						Operator: filter.LogicalAnd,
+						Operands: []filter.Expr{
+							&filter.CompareExpr{Path: "b", Operator: filter.OpEq, Value: "2"},
+							&filter.CompareExpr{Path: "c", Operator: filter.OpEq, Value: "3"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "Brackets and not",
+			inputExpr: "not (a = 1 or b = 'x and y')",
+			expectedExpr: &filter.NotExpr{
+				Operand: &filter.LogicalExpr{
+					Operator: filter.LogicalOr,
+					Operands: []filter.Expr{
+						&filter.CompareExpr{Path: "a", Operator: filter.OpEq, Value: "1"},
+						&filter.CompareExpr{Path: "b", Operator: filter.OpEq, Value: "x and y"},
+					},
+				},
+			},
+		},
+		{
+			name:         "Escaped quote in value",
+			inputExpr:    `name = 'O\'Brien'`,
+			expectedExpr: &filter.CompareExpr{Path: "name", Operator: filter.OpEq, Value: "O'Brien"},
+		},
+		{
+			name:         "Function in comparison",
+			inputExpr:    "len(tags) >= 2",
+			expectedExpr: &filter.CompareExpr{Func: filter.FuncLen, Path: "tags", Operator: filter.OpGte, Value: "2"},
+		},
+		{
+			name:      "Exists predicate",
+			inputExpr: "exists(job.company) and not EXISTS( error )",
+			expectedExpr: &filter.LogicalExpr{
+				Operator: filter.LogicalAnd,
+				Operands: []filter.Expr{
+					&filter.ExistsExpr{Path: "job.company"},
+					&filter.NotExpr{Operand: &filter.ExistsExpr{Path: "error"}},
+				},
+			},
+		},
+		{
+			name:         "Quoted value with keyword",
+			inputExpr:    "key = 'a and b'",
+			expectedExpr: &filter.CompareExpr{Path: "key", Operator: filter.OpEq, Value: "a and b"},
+		},
+		{
+			name:         "Keyword as part of word",
+			inputExpr:    "notes = android",
+			expectedExpr: &filter.CompareExpr{Path: "notes", Operator: filter.OpEq, Value: "android"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, testCase.expectedExpr, condition.Expr())
+		})
+	}
+}
+
+func TestNewConditionFromStr_Vars(t *testing.T) {
+	vars := map[string]interface{}{
+		"threshold": 250,
+		"ratio":     0.5,
+		"svc":       "api gateway",
+		"flag":      true,
+	}
+
+	cases := []struct {
+		name          string
+		inputExpr     string
+		expectedValue string
+	}{
+		{
+			name:          "Integer",
+			inputExpr:     "latency > $threshold",
+			expectedValue: "250",
+		},
+		{
+			name:          "Float",
+			inputExpr:     "ratio <= $ratio",
+			expectedValue: "0.5",
+		},
+		{
+			name:          "String",
+			inputExpr:     "service = $svc",
+			expectedValue: "api gateway",
+		},
+		{
+			name:          "Boolean",
+			inputExpr:     "enabled != $flag",
+			expectedValue: "true",
+		},
+		{
+			name:          "Quoted dollar is not variable",
+			inputExpr:     "price = '$threshold'",
+			expectedValue: "$threshold",
+		},
+		{
+			name:          "Dollar without name is not variable",
+			inputExpr:     "price = $5",
+			expectedValue: "$5",
+		},
+		{
+			name:          "String with ordering operator",
+			inputExpr:     "service >= $svc",
+			expectedValue: "api gateway",
+		},
+		{
+			name:          "String as regular expression",
+			inputExpr:     "service ~ $svc",
+			expectedValue: "api gateway",
+		},
+		{
+			name:          "Number compared with length",
+			inputExpr:     "len(tags) < $threshold",
+			expectedValue: "250",
+		},
+		{
+			name:          "String compared with lower case",
+			inputExpr:     "lower(service) = $svc",
+			expectedValue: "api gateway",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromStr(testCase.inputExpr, filter.WithVars(vars))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, testCase.expectedValue, condition.Value())
+		})
+	}
+}
+
+func TestNewConditionFromStr_VarsNegative(t *testing.T) {
+	vars := map[string]interface{}{
+		"flag":  true,
+		"num":   5,
+		"svc":   "api",
+		"list":  []string{"a"},
+		"empty": nil,
+	}
+
+	cases := []struct {
+		name          string
+		inputExpr     string
+		expectedCause error
+	}{
+		{
+			name:          "Unbound variable",
+			inputExpr:     "latency > $threshold and service = api",
+			expectedCause: filter.ErrUnboundVariable,
+		},
+		{
+			name:          "Boolean with ordering operator",
+			inputExpr:     "enabled > $flag",
+			expectedCause: filter.ErrUnsupportedOperator,
+		},
+		{
+			name:          "Number as regular expression",
+			inputExpr:     "code ~ $num",
+			expectedCause: filter.ErrUnsupportedOperator,
+		},
+		{
+			name:          "Boolean as regular expression",
+			inputExpr:     "enabled !~ $flag",
+			expectedCause: filter.ErrUnsupportedOperator,
+		},
+		{
+			name:          "String compared with length",
+			inputExpr:     "len(tags) = $svc",
+			expectedCause: filter.ErrUnsupportedType,
+		},
+		{
+			name:          "Boolean compared with length",
+			inputExpr:     "len(tags) = $flag",
+			expectedCause: filter.ErrUnsupportedType,
+		},
+		{
+			name:          "Number compared with upper case",
+			inputExpr:     "upper(code) = $num",
+			expectedCause: filter.ErrUnsupportedType,
+		},
+		{
+			name:          "Slice value",
+			inputExpr:     "tags = $list",
+			expectedCause: filter.ErrUnsupportedType,
+		},
+		{
+			name:          "Nil value",
+			inputExpr:     "tags = $empty",
+			expectedCause: filter.ErrUnsupportedType,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromStr(testCase.inputExpr, filter.WithVars(vars))
+			assert.Nil(t, condition)
+			assert.Equal(t, testCase.expectedCause, errors.Cause(err))
+		})
+	}
+}
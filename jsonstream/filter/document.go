@@ -0,0 +1,147 @@
+package filter
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Backend is a representation of element which condition is evaluated over
+type Backend string
+
+// Available backends
+const (
+	// BackendLazy scans raw bytes of element along paths of condition, only found values are decoded
+	BackendLazy = Backend("lazy")
+	// BackendJSON decodes element entirely by encoding/json before evaluation
+	BackendJSON = Backend("json")
+	// BackendDecoded evaluates values already decoded to maps, slices and primitive values of encoding/json
+	// (Program.MatchDecoded evaluates them with any backend). Raw elements are decoded by encoding/json
+	// like json backend does
+	BackendDecoded = Backend("decoded")
+)
+
+// Backends lists backends of raw elements
+var Backends = []Backend{BackendLazy, BackendJSON}
+
+// String casts backend to string
+func (b Backend) String() string {
+	return string(b)
+}
+
+// document is an element evaluated by condition. Implementations give access to elements of different
+// representations, results of evaluation don't depend on them
+type document interface {
+	// compare compares values found by path with value of condition like chechkValue does
+	compare(e *CompareExpr) (bool, error)
+	// lookup returns value found by path as encoding/json decodes it, path going through arrays gives
+	// array of values found in their elements
+	lookup(path string) (val interface{}, found bool)
+	// exists checks that path is present in element
+	exists(path string) bool
+	// anyElem evaluates operand for elements of array found by path until it is satisfied
+	anyElem(e *AnyExpr) (bool, error)
+	// decode returns element decoded entirely, it's a root of JSONPath queries
+	decode() interface{}
+}
+
+// decodeElem decodes element by encoding/json
+func decodeElem(elem []byte) (document, error) {
+	var data interface{}
+	if err := json.Unmarshal(elem, &data); err != nil {
+		return nil, errors.Wrap(err, "parse json error")
+	}
+	return decodedDoc{data: data}, nil
+}
+
+// decodedDoc is an element decoded to maps, slices and primitive values the same way as encoding/json does
+type decodedDoc struct {
+	data interface{}
+}
+
+func (d decodedDoc) compare(e *CompareExpr) (bool, error) {
+	val, _ := d.lookup(e.Path)
+	return chechkValue(val, *e)
+}
+
+func (d decodedDoc) lookup(path string) (interface{}, bool) {
+	return lookupDecoded(d.data, path)
+}
+
+func (d decodedDoc) exists(path string) bool {
+	_, found := lookupDecoded(d.data, path)
+	return found
+}
+
+func (d decodedDoc) anyElem(e *AnyExpr) (bool, error) {
+	val, _ := lookupDecoded(d.data, e.Path)
+	return anyDecoded(val, e)
+}
+
+func (d decodedDoc) decode() interface{} {
+	return d.data
+}
+
+// anyDecoded evaluates operand of any() for elements of decoded array until it is satisfied
+func anyDecoded(val interface{}, e *AnyExpr) (bool, error) {
+	elems, isArray := val.([]interface{})
+	if !isArray {
+		return false, nil
+	}
+
+	for _, elem := range elems {
+		isOk, err := checkExpr(decodedDoc{data: elem}, e.Operand)
+		if err != nil {
+			return false, errors.Wrapf(err, "error check elems of array in path '%s'", e.Path)
+		}
+
+		if isOk {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// LookupDecoded searches value of already decoded element by path the same way as conditions do
+func LookupDecoded(data interface{}, path string) (interface{}, bool) {
+	return lookupDecoded(data, path)
+}
+
+// lookupDecoded searches value by dot notation path, "@" refers to the root. Path going through arrays
+// gives array of values found in their elements, elements without path are skipped
+func lookupDecoded(data interface{}, path string) (interface{}, bool) {
+	if path == selfPath {
+		return data, true
+	}
+
+	return searchDecoded(data, strings.Split(path, "."))
+}
+
+func searchDecoded(data interface{}, keys []string) (interface{}, bool) {
+	for i, key := range keys {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if data, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			res := []interface{}{}
+			for _, elem := range v {
+				if val, found := searchDecoded(elem, keys[i:]); found {
+					res = append(res, val)
+				}
+			}
+			if len(res) == 0 {
+				return nil, false
+			}
+			return res, true
+		default:
+			return nil, false
+		}
+	}
+
+	return data, true
+}
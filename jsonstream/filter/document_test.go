@@ -0,0 +1,102 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithBackend_SameResults(t *testing.T) {
+	elems := []string{
+		`{"id": 1, "name": "John", "emails": ["john@gmail.com"], "children": [{"name": "Alex", "age": 10}, {"age": 5}]}`,
+		`{"id": "1", "name": "John", "name": "Jack", "children": {"age": 3}}`,
+		`{"id": {"x": 1}, "children": [[{"age": 7}], {"name": null}]}`,
+		`[{"name": "John"}, "x"]`,
+		`{"id": 1,}`,
+	}
+	exprs := []string{
+		"name = John",
+		"name ~ ^Ja",
+		"id = 1",
+		"children.age > 6",
+		"exists(children.name)",
+		"any(children, age < 6 or name = Alex)",
+		"len(children.age) = 2",
+		"jsonpath('$.children[?@.age > 5]')",
+	}
+
+	for _, expr := range exprs {
+		cond, err := filter.NewConditionFromStr(expr)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+		lazy, err := filter.Compile(*cond, filter.WithBackend(filter.BackendLazy))
+		assert.NoError(t, err, expr)
+		strict, err := filter.Compile(*cond, filter.WithBackend(filter.BackendJSON))
+		assert.NoError(t, err, expr)
+		decoded, err := filter.Compile(*cond, filter.WithBackend(filter.BackendDecoded))
+		assert.NoError(t, err, expr)
+
+		for _, elem := range elems {
+			expected, expectedErr := strict.Match([]byte(elem))
+			isOk, err := lazy.Match([]byte(elem))
+			assert.Equal(t, expected, isOk, "%s on %s", expr, elem)
+			if expectedErr != nil && assert.Error(t, err, "%s on %s", expr, elem) {
+				assert.Equal(t, expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err, "%s on %s", expr, elem)
+			}
+
+			isOk, err = decoded.Match([]byte(elem))
+			assert.Equal(t, expected, isOk, "%s on %s", expr, elem)
+			if expectedErr != nil && assert.Error(t, err, "%s on %s", expr, elem) {
+				assert.Equal(t, expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err, "%s on %s", expr, elem)
+			}
+
+			var val interface{}
+			if json.Unmarshal([]byte(elem), &val) != nil {
+				continue
+			}
+			isOk, err = decoded.MatchDecoded(val)
+			assert.Equal(t, expected, isOk, "%s on decoded %s", expr, elem)
+			if expectedErr != nil && assert.Error(t, err, "%s on decoded %s", expr, elem) {
+				assert.Equal(t, expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err, "%s on decoded %s", expr, elem)
+			}
+		}
+	}
+
+	_, err := filter.Compile(filter.Path("name").Eq("John"), filter.WithBackend("gabs"))
+	assert.EqualError(t, err, "unknown backend 'gabs'")
+}
+
+func TestProgram_MatchDecoded(t *testing.T) {
+	cond := filter.Path("name").Eq("John").And(filter.Path("children").Any(filter.Path("age").Lt(6)))
+	for _, backend := range []filter.Backend{filter.BackendDecoded, filter.BackendLazy, filter.BackendJSON} {
+		t.Run(backend.String(), func(t *testing.T) {
+			program, err := filter.Compile(cond, filter.WithBackend(backend))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			isOk, err := program.MatchDecoded(map[string]interface{}{
+				"name":     "John",
+				"children": []interface{}{map[string]interface{}{"age": 10.0}, map[string]interface{}{"age": 5.0}},
+			})
+			assert.NoError(t, err)
+			assert.True(t, isOk)
+
+			isOk, err = program.MatchDecoded(map[string]interface{}{"name": "John", "children": []interface{}{}})
+			assert.NoError(t, err)
+			assert.False(t, isOk)
+
+			_, err = program.MatchDecoded(map[string]interface{}{"name": map[string]interface{}{}})
+			assert.Error(t, err)
+		})
+	}
+}
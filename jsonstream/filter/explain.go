@@ -0,0 +1,296 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Trace explains why element satisfies condition or not
+type Trace struct {
+	Verdict bool `json:"verdict"`
+	// Error is an error of element parsing or checking, verdict is false then
+	Error string     `json:"error,omitempty"`
+	Root  *TraceNode `json:"root,omitempty"`
+}
+
+// TraceNode explains result of sub-expression of condition. Operands which weren't evaluated are omitted
+type TraceNode struct {
+	Expr   string `json:"expr"`
+	Result bool   `json:"result"`
+	// Element is index of array element the node is evaluated for inside any()
+	Element *int `json:"element,omitempty"`
+	// Path is checked by node, Value is found by it. Found is false when path is missing
+	Path  string      `json:"path,omitempty"`
+	Found bool        `json:"found,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+	// Index is index of array element which satisfied comparison or any()
+	Index *int `json:"index,omitempty"`
+	// Coercions describe how found value was transformed and compared with value of condition
+	Coercions []string     `json:"coercions,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Operands  []*TraceNode `json:"operands,omitempty"`
+}
+
+// Explain checks element like Match does and traces evaluation of every sub-expression
+func (p *Program) Explain(elem []byte) Trace {
+	var data interface{}
+	if err := json.Unmarshal(elem, &data); err != nil {
+		return Trace{Error: errors.Wrap(err, "parse json error").Error()}
+	}
+
+	root, err := explainExpr(data, p.expr)
+	if err != nil {
+		return Trace{Error: err.Error(), Root: root}
+	}
+
+	return Trace{Verdict: root.Result, Root: root}
+}
+
+// String formats trace as indented tree of sub-expressions
+func (t Trace) String() string {
+	var res strings.Builder
+	res.WriteString("verdict: " + strconv.FormatBool(t.Verdict) + "\n")
+	if t.Error != "" {
+		res.WriteString("error: " + t.Error + "\n")
+	}
+	if t.Root != nil {
+		t.Root.write(&res, "")
+	}
+	return res.String()
+}
+
+func (n *TraceNode) write(res *strings.Builder, indent string) {
+	res.WriteString(indent + fmt.Sprintf("%-5t ", n.Result))
+	if n.Element != nil {
+		res.WriteString("[" + strconv.Itoa(*n.Element) + "] ")
+	}
+	res.WriteString(n.Expr)
+
+	var details []string
+	if n.Path != "" {
+		if n.Found {
+			details = append(details, n.Path+" = "+formatTraceValue(n.Value))
+		} else {
+			details = append(details, n.Path+" is missing")
+		}
+	}
+	if n.Index != nil {
+		details = append(details, "element ["+strconv.Itoa(*n.Index)+"] matched")
+	}
+	details = append(details, n.Coercions...)
+	if n.Error != "" {
+		details = append(details, "error: "+n.Error)
+	}
+	if len(details) > 0 {
+		res.WriteString("  (" + strings.Join(details, "; ") + ")")
+	}
+	res.WriteString("\n")
+
+	for _, operand := range n.Operands {
+		operand.write(res, indent+"  ")
+	}
+}
+
+func formatTraceValue(val interface{}) string {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+	return string(data)
+}
+
+func exprString(expr Expr) string {
+	var res strings.Builder
+	writeExpr(&res, expr)
+	return res.String()
+}
+
+// explainExpr evaluates expression the same way as checkExpr does
+func explainExpr(data interface{}, expr Expr) (*TraceNode, error) {
+	node := &TraceNode{Expr: exprString(expr)}
+
+	var err error
+	switch e := expr.(type) {
+	case *CompareExpr:
+		err = explainCompare(data, e, node)
+	case *ExistsExpr:
+		node.setPath(data, e.Path)
+		node.Result = node.Found
+	case *AnyExpr:
+		err = explainAny(data, e, node)
+	case *JSONPathExpr:
+		err = explainJSONPath(data, e, node)
+	case *ConstExpr:
+		node.Result = e.Value
+	case *LogicalExpr:
+		stopOn := e.Operator == LogicalOr
+		node.Result = !stopOn
+		for _, operand := range e.Operands {
+			var operandNode *TraceNode
+			operandNode, err = explainExpr(data, operand)
+			node.Operands = append(node.Operands, operandNode)
+			if err != nil || operandNode.Result == stopOn {
+				node.Result = stopOn
+				break
+			}
+		}
+	case *NotExpr:
+		var operandNode *TraceNode
+		operandNode, err = explainExpr(data, e.Operand)
+		node.Operands = append(node.Operands, operandNode)
+		node.Result = !operandNode.Result
+	case nil:
+		err = errors.New("empty condition")
+	default:
+		err = errors.Errorf("unknown expression %T", expr)
+	}
+
+	if err != nil {
+		node.Result = false
+		if node.Error == "" && len(node.Operands) == 0 {
+			node.Error = err.Error()
+		}
+	}
+
+	return node, err
+}
+
+func (n *TraceNode) setPath(data interface{}, path string) {
+	n.Path = path
+	n.Value, n.Found = lookupDecoded(data, path)
+}
+
+func explainCompare(data interface{}, e *CompareExpr, node *TraceNode) error {
+	node.setPath(data, e.Path)
+
+	checkVal, err := applyFunction(e.Func, node.Value)
+	if err != nil {
+		return errors.Wrapf(err, "error apply function %s to path '%s'", e.Func.String(), e.Path)
+	}
+	if e.Func != FuncNone {
+		node.Coercions = append(node.Coercions, fmt.Sprintf("%s(%s) = %s", e.Func.String(), e.Path, formatTraceValue(checkVal)))
+	}
+
+	elems, isArray := checkVal.([]interface{})
+	if !isArray {
+		node.Coercions = append(node.Coercions, describeCoercion(checkVal, e))
+		node.Result, err = chechkValue(checkVal, *e)
+		return err
+	}
+
+	// elements are checked one by one like chechkValue does to find the matched one
+	for i, elem := range elems {
+		isOk, err := chechkValue(elem, *e)
+		if err != nil {
+			node.Coercions = append(node.Coercions, "element ["+strconv.Itoa(i)+"]: "+describeCoercion(elem, e))
+			return errors.Wrapf(err, "error process elems of array in path as string")
+		}
+
+		if isOk {
+			index := i
+			node.Index = &index
+			node.Result = true
+			node.Coercions = append(node.Coercions, describeCoercion(elem, e))
+			return nil
+		}
+	}
+
+	node.Coercions = append(node.Coercions, fmt.Sprintf("none of %d elements matched", len(elems)))
+	return nil
+}
+
+// describeCoercion tells how value found by path is compared with value of condition
+func describeCoercion(val interface{}, e *CompareExpr) string {
+	if valueType, ok := jsonValueType(val); ok && !e.accepts(valueType) {
+		return fmt.Sprintf("%s value never satisfies comparison with %s", valueType.String(), e.Type.String())
+	}
+
+	switch val.(type) {
+	case string:
+		if isLikeOperator(e.Operator) {
+			return "string matched by regular expression"
+		}
+		return "compared as strings"
+	case float64:
+		if isLikeOperator(e.Operator) {
+			return "number never matches regular expression"
+		}
+		return fmt.Sprintf("'%s' converted to number", e.Value)
+	case bool:
+		if isLikeOperator(e.Operator) {
+			return "boolean never matches regular expression"
+		}
+		return fmt.Sprintf("'%s' converted to boolean", e.Value)
+	case nil:
+		if e.Type == TypeNull {
+			return "null or missing value compared with null"
+		}
+		return "null or missing value never satisfies comparison"
+	case []interface{}:
+		return "compared with elements of nested array"
+	default:
+		return fmt.Sprintf("value of type %T can't be compared", val)
+	}
+}
+
+// jsonValueType returns type of scalar value decoded by encoding/json
+func jsonValueType(val interface{}) (ValueType, bool) {
+	switch val.(type) {
+	case string:
+		return TypeString, true
+	case float64:
+		return TypeNumber, true
+	case bool:
+		return TypeBool, true
+	case nil:
+		return TypeNull, true
+	default:
+		return TypeAny, false
+	}
+}
+
+func explainAny(data interface{}, e *AnyExpr, node *TraceNode) error {
+	node.setPath(data, e.Path)
+
+	elems, isArray := node.Value.([]interface{})
+	if !isArray {
+		node.Coercions = append(node.Coercions, "value is not an array")
+		return nil
+	}
+
+	for i, elem := range elems {
+		operandNode, err := explainExpr(elem, e.Operand)
+		index := i
+		operandNode.Element = &index
+		node.Operands = append(node.Operands, operandNode)
+		if err != nil {
+			return errors.Wrapf(err, "error check elems of array in path '%s'", e.Path)
+		}
+
+		if operandNode.Result {
+			node.Index = &index
+			node.Result = true
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func explainJSONPath(data interface{}, e *JSONPathExpr, node *TraceNode) error {
+	query, err := e.query()
+	if err != nil {
+		return errors.Wrap(err, "invalid JSONPath query")
+	}
+
+	nodes := query.eval(&jsonPathContext{root: data, current: data})
+	node.Found = len(nodes) > 0
+	node.Value = nodes
+	node.Coercions = append(node.Coercions, fmt.Sprintf("query selected %d nodes", len(nodes)))
+	node.Result = len(nodes) > 0
+	return nil
+}
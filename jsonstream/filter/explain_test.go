@@ -0,0 +1,97 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompile(t *testing.T) {
+	program, err := filter.Compile(filter.Path("name").Eq("John").And(filter.Path("age").Gt(5)))
+	if assert.NoError(t, err) {
+		isOk, err := program.Match([]byte(`{"name": "John", "age": 10}`))
+		assert.NoError(t, err)
+		assert.True(t, isOk)
+
+		isOk, err = program.Match([]byte(`{"name": "John", "age": 1}`))
+		assert.NoError(t, err)
+		assert.False(t, isOk)
+
+		_, err = program.Match([]byte(`{"name":`))
+		assert.Error(t, err)
+
+		assert.Equal(t, "name = John and age > 5", program.Condition().String())
+	}
+
+	_, err = filter.Compile(filter.Path("name").Match("("))
+	assert.Error(t, err)
+
+	_, err = filter.Compile(filter.Path("age").Gt([]int{1}))
+	assert.Error(t, err)
+
+	_, err = filter.Compile(filter.Condition{})
+	assert.EqualError(t, err, "empty condition")
+}
+
+func TestProgram_Explain(t *testing.T) {
+	elem := []byte(`{"id": 1, "name": "John", "emails": ["john@gmail.com", "john@mail.ru"],` +
+		` "children": [{"name": "Alex", "age": 10}, {"name": "Pit", "age": 5}]}`)
+
+	cond, err := filter.NewConditionFromStr(`emails ~ "mail\\.ru$" and (id = 2 or any(children, age < 10)) and not exists(job)`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	program, err := filter.Compile(*cond)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	trace := program.Explain(elem)
+	assert.True(t, trace.Verdict)
+	assert.Empty(t, trace.Error)
+	assert.Equal(t, `verdict: true
+true  emails ~ 'mail\.ru$' and (id = 2 or any(children, age < 10)) and not exists(job)
+  true  emails ~ 'mail\.ru$'  (emails = ["john@gmail.com","john@mail.ru"]; element [1] matched; string matched by regular expression)
+  true  id = 2 or any(children, age < 10)
+    false id = 2  (id = 1; '2' converted to number)
+    true  any(children, age < 10)  (children = [{"age":10,"name":"Alex"},{"age":5,"name":"Pit"}]; element [1] matched)
+      false [0] age < 10  (age = 10; '10' converted to number)
+      true  [1] age < 10  (age = 5; '10' converted to number)
+  true  not exists(job)
+    false exists(job)  (job is missing)
+`, trace.String())
+
+	data, err := json.Marshal(program.Explain([]byte(`{"id": 1, "emails": "a@mail.ru", "children": true}`)))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"verdict": false, "root": {"expr": "emails ~ 'mail\\.ru$' and (id = 2 or any(children, age < 10)) and not exists(job)",
+		"result": false, "operands": [
+			{"expr": "emails ~ 'mail\\.ru$'", "result": true, "path": "emails", "found": true, "value": "a@mail.ru",
+				"coercions": ["string matched by regular expression"]},
+			{"expr": "id = 2 or any(children, age < 10)", "result": false, "operands": [
+				{"expr": "id = 2", "result": false, "path": "id", "found": true, "value": 1, "coercions": ["'2' converted to number"]},
+				{"expr": "any(children, age < 10)", "result": false, "path": "children", "found": true, "value": true,
+					"coercions": ["value is not an array"]}]}]}}`, string(data))
+}
+
+func TestProgram_Explain_Errors(t *testing.T) {
+	program, err := filter.Compile(filter.Path("flag").Eq("yes").Or(filter.Path("len").Len().Gt(1)))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	trace := program.Explain([]byte(`{"flag": true}`))
+	assert.False(t, trace.Verdict)
+	assert.Equal(t, `error process path as boolean: fail to parse 'yes' as bool: strconv.ParseBool: parsing "yes": invalid syntax`, trace.Error)
+	assert.Equal(t, trace.Error, trace.Root.Operands[0].Error)
+	assert.Equal(t, []string{"'yes' converted to boolean"}, trace.Root.Operands[0].Coercions)
+
+	_, expectedErr := program.Match([]byte(`{"flag": true}`))
+	assert.Error(t, expectedErr)
+
+	trace = program.Explain([]byte(`{"flag"`))
+	assert.False(t, trace.Verdict)
+	assert.Nil(t, trace.Root)
+	assert.Contains(t, trace.Error, "parse json error")
+}
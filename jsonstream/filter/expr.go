@@ -0,0 +1,126 @@
+package filter
+
+import "regexp"
+
+// Expr is a node of parsed condition tree
+type Expr interface {
+	isExpr()
+}
+
+// LogicalOperator is used to join several expressions into one
+type LogicalOperator string
+
+// Available logical operators
+const (
+	LogicalAnd = LogicalOperator("and")
+	LogicalOr  = LogicalOperator("or")
+)
+
+// String casts logical operator to string
+func (o LogicalOperator) String() string {
+	return string(o)
+}
+
+// Function transforms value found by path before comparison
+type Function string
+
+// Available functions to use in comparisons
+const (
+	FuncNone  = Function("")
+	FuncLen   = Function("len")
+	FuncLower = Function("lower")
+	FuncUpper = Function("upper")
+)
+
+// String casts function to string
+func (f Function) String() string {
+	return string(f)
+}
+
+// ValueType is a JSON type of value which typed comparison accepts
+type ValueType string
+
+// Available types of comparisons
+const (
+	// TypeAny makes value of condition converted to type of found value
+	TypeAny    = ValueType("")
+	TypeString = ValueType("string")
+	TypeNumber = ValueType("number")
+	TypeBool   = ValueType("boolean")
+	// TypeNull comparison is satisfied by null or missing value
+	TypeNull = ValueType("null")
+)
+
+// String casts value type to string
+func (t ValueType) String() string {
+	return string(t)
+}
+
+// CompareExpr compares value found by path (optionally transformed by function) with a value of condition.
+// Typed comparison is never satisfied by values of other types, even with != operator
+type CompareExpr struct {
+	Func     Function
+	Path     string
+	Operator Operator
+	Value    string
+	Type     ValueType
+
+	// re is a compiled pattern of like operators
+	re *regexp.Regexp
+}
+
+// ExistsExpr checks that path is present in element
+type ExistsExpr struct {
+	Path string
+}
+
+// AnyExpr checks that at least one element of array found by path satisfies operand.
+// Paths of operand are relative to the element, "@" refers to the element itself
+type AnyExpr struct {
+	Path    string
+	Operand Expr
+}
+
+// JSONPathExpr is satisfied by element for which JSONPath query (RFC 9535) returns non-empty nodelist.
+// Root of the query ($) is the element or element of array inside any()
+type JSONPathExpr struct {
+	Query string
+
+	compiled *jsonPathQuery
+}
+
+// ConstExpr is satisfied by any element (true value) or by none (false value)
+type ConstExpr struct {
+	Value bool
+}
+
+// LogicalExpr joins results of operands with "and" or "or" logic
+type LogicalExpr struct {
+	Operator LogicalOperator
+	Operands []Expr
+}
+
+// NotExpr negates result of operand
+type NotExpr struct {
+	Operand Expr
+}
+
+func (*CompareExpr) isExpr()  {}
+func (*ExistsExpr) isExpr()   {}
+func (*AnyExpr) isExpr()      {}
+func (*JSONPathExpr) isExpr() {}
+func (*ConstExpr) isExpr()    {}
+func (*LogicalExpr) isExpr()  {}
+func (*NotExpr) isExpr()      {}
+
+// joinExprs joins expressions by logical operator. Empty list gives neutral constant of operator
+func joinExprs(op LogicalOperator, exprs []Expr) Expr {
+	switch len(exprs) {
+	case 0:
+		return &ConstExpr{Value: op == LogicalAnd}
+	case 1:
+		return exprs[0]
+	default:
+		return &LogicalExpr{Operator: op, Operands: exprs}
+	}
+}
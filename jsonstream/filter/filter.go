@@ -3,8 +3,8 @@ package filter
 import (
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/Jeffail/gabs"
 	"github.com/pkg/errors"
 )
 
@@ -19,20 +19,132 @@ var (
 // ProcessElem solves accordance of stream element to condition
 func ProcessElem(condition Condition, elem []byte) (resElem []byte, isOk bool, err error) {
 	resElem = elem
-	jsonParsed, err := gabs.ParseJSON(elem)
-	if err != nil {
-		return resElem, false, errors.Wrap(err, "parse json error")
+	if condition.err != nil {
+		return resElem, false, errors.Wrap(condition.err, "invalid condition")
 	}
 
-	isOk, err = chechkValue(jsonParsed.Path(condition.path).Data(), condition)
+	isOk, err = matchElem(elem, condition.expr, BackendLazy)
 	if err != nil {
-		return resElem, false, errors.Wrap(err, "error check path")
+		return resElem, false, err
 	}
 
 	return resElem, isOk, nil
 }
 
-func chechkValue(checkVal interface{}, condition Condition) (isOk bool, err error) {
+// checkExpr solves accordance of element to expression
+func checkExpr(doc document, expr Expr) (bool, error) {
+	switch e := expr.(type) {
+	case *CompareExpr:
+		if e.Func == FuncNone {
+			return doc.compare(e)
+		}
+		val, _ := doc.lookup(e.Path)
+		checkVal, err := applyFunction(e.Func, val)
+		if err != nil {
+			return false, errors.Wrapf(err, "error apply function %s to path '%s'", e.Func.String(), e.Path)
+		}
+		return chechkValue(checkVal, *e)
+	case *ExistsExpr:
+		return doc.exists(e.Path), nil
+	case *AnyExpr:
+		return doc.anyElem(e)
+	case *JSONPathExpr:
+		return checkJSONPath(doc.decode(), e)
+	case *ConstExpr:
+		return e.Value, nil
+	case *LogicalExpr:
+		return checkLogical(doc, *e)
+	case *NotExpr:
+		isOk, err := checkExpr(doc, e.Operand)
+		if err != nil {
+			return false, err
+		}
+		return !isOk, nil
+	case nil:
+		return false, errors.New("empty condition")
+	default:
+		return false, errors.Errorf("unknown expression %T", expr)
+	}
+}
+
+// checkJSONPath checks that query selects at least one node of element
+func checkJSONPath(data interface{}, expr *JSONPathExpr) (bool, error) {
+	query, err := expr.query()
+	if err != nil {
+		return false, errors.Wrap(err, "invalid JSONPath query")
+	}
+
+	nodes := query.eval(&jsonPathContext{root: data, current: data})
+	return len(nodes) > 0, nil
+}
+
+// checkLogical evaluates operands until result is known
+func checkLogical(doc document, expr LogicalExpr) (bool, error) {
+	stopOn := expr.Operator == LogicalOr
+	for _, operand := range expr.Operands {
+		isOk, err := checkExpr(doc, operand)
+		if err != nil {
+			return false, err
+		}
+
+		if isOk == stopOn {
+			return stopOn, nil
+		}
+	}
+
+	return !stopOn, nil
+}
+
+// applyFunction transforms value found by path, nil value means absence of path
+func applyFunction(fn Function, val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	switch fn {
+	case FuncNone:
+		return val, nil
+	case FuncLen:
+		return valueLen(val)
+	case FuncLower:
+		return mapStrings(val, strings.ToLower), nil
+	case FuncUpper:
+		return mapStrings(val, strings.ToUpper), nil
+	default:
+		return nil, errors.Errorf("unknown function '%s'", fn.String())
+	}
+}
+
+func valueLen(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedType, "unable to get length of %T", val)
+	}
+}
+
+// mapStrings applies fn to string value or to every string in array
+func mapStrings(val interface{}, fn func(string) string) interface{} {
+	switch v := val.(type) {
+	case string:
+		return fn(v)
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, elem := range v {
+			res[i] = mapStrings(elem, fn)
+		}
+		return res
+	default:
+		return val
+	}
+}
+
+func chechkValue(checkVal interface{}, condition CompareExpr) (isOk bool, err error) {
 	switch val := checkVal.(type) {
 	case string:
 		isOk, err = checkString(val, condition)
@@ -66,38 +178,55 @@ func chechkValue(checkVal interface{}, condition Condition) (isOk bool, err erro
 			}
 		}
 	default:
-		return false, errors.Wrapf(ErrUnsupportedType, "unsupported type of val by path '%s'", condition.path)
+		return false, errors.Wrapf(ErrUnsupportedType, "unsupported type of val by path '%s'", condition.Path)
 	}
 
 	return isOk, err
 }
 
-func checkString(checkVal string, condition Condition) (bool, error) {
-	switch condition.operator {
+func checkString(checkVal string, condition CompareExpr) (bool, error) {
+	if !condition.accepts(TypeString) {
+		return false, nil
+	}
+
+	switch condition.Operator {
 	case OpEq:
-		return strings.Compare(checkVal, condition.value) == 0, nil
+		return strings.Compare(checkVal, condition.Value) == 0, nil
 	case OpNotEq:
-		return strings.Compare(checkVal, condition.value) != 0, nil
+		return strings.Compare(checkVal, condition.Value) != 0, nil
 	case OpGt:
-		return strings.Compare(checkVal, condition.value) > 0, nil
+		return strings.Compare(checkVal, condition.Value) > 0, nil
 	case OpGte:
-		return strings.Compare(checkVal, condition.value) >= 0, nil
+		return strings.Compare(checkVal, condition.Value) >= 0, nil
 	case OpLt:
-		return strings.Compare(checkVal, condition.value) < 0, nil
+		return strings.Compare(checkVal, condition.Value) < 0, nil
 	case OpLte:
-		return strings.Compare(checkVal, condition.value) <= 0, nil
+		return strings.Compare(checkVal, condition.Value) <= 0, nil
+	case OpLike, OpNotLike:
+		re, err := condition.regexp()
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(checkVal) == (condition.Operator == OpLike), nil
 	default:
-		return false, errors.Wrapf(ErrUnsupportedOperator, "passed %s", condition.operator.String())
+		return false, errors.Wrapf(ErrUnsupportedOperator, "passed %s", condition.Operator.String())
 	}
 }
 
-func checkFloat64(checkVal float64, condition Condition) (bool, error) {
-	conditionVal, err := strconv.ParseFloat(condition.value, 64)
+func checkFloat64(checkVal float64, condition CompareExpr) (bool, error) {
+	if !condition.accepts(TypeNumber) {
+		return false, nil
+	}
+	if isLikeOperator(condition.Operator) {
+		return condition.Operator == OpNotLike, nil
+	}
+
+	conditionVal, err := strconv.ParseFloat(condition.Value, 64)
 	if err != nil {
-		return false, errors.Wrapf(err, "fail to parse '%s' as number", condition.value)
+		return false, errors.Wrapf(err, "fail to parse '%s' as number", condition.Value)
 	}
 
-	switch condition.operator {
+	switch condition.Operator {
 	case OpEq:
 		return checkVal == conditionVal, nil
 	case OpNotEq:
@@ -111,26 +240,71 @@ func checkFloat64(checkVal float64, condition Condition) (bool, error) {
 	case OpGte:
 		return checkVal >= conditionVal, nil
 	default:
-		return false, errors.Wrapf(ErrUnsupportedOperator, "passed %s", condition.operator.String())
+		return false, errors.Wrapf(ErrUnsupportedOperator, "passed %s", condition.Operator.String())
 	}
 }
 
-func checkNil(condition Condition) (bool, error) {
-	return false, nil
+// checkNil checks null or missing value, it satisfies only equality with null
+func checkNil(condition CompareExpr) (bool, error) {
+	return condition.Type == TypeNull && condition.Operator == OpEq, nil
 }
 
-func checkBool(checkVal bool, condition Condition) (bool, error) {
-	conditionVal, err := strconv.ParseBool(condition.value)
+func checkBool(checkVal bool, condition CompareExpr) (bool, error) {
+	if !condition.accepts(TypeBool) {
+		return false, nil
+	}
+	if isLikeOperator(condition.Operator) {
+		return condition.Operator == OpNotLike, nil
+	}
+
+	conditionVal, err := strconv.ParseBool(condition.Value)
 	if err != nil {
-		return false, errors.Wrapf(err, "fail to parse '%s' as bool", condition.value)
+		return false, errors.Wrapf(err, "fail to parse '%s' as bool", condition.Value)
 	}
 
-	switch condition.operator {
+	switch condition.Operator {
 	case OpEq:
 		return checkVal == conditionVal, nil
 	case OpNotEq:
 		return checkVal != conditionVal, nil
 	default:
-		return false, errors.Wrapf(ErrUnsupportedOperator, "passed %s", condition.operator.String())
+		return false, errors.Wrapf(ErrUnsupportedOperator, "passed %s", condition.Operator.String())
+	}
+}
+
+// accepts checks that comparison is applied to values of type
+func (e *CompareExpr) accepts(valueType ValueType) bool {
+	return e.Type == TypeAny || e.Type == valueType
+}
+
+// validateType checks that value of typed comparison belongs to its type and operator can be applied to it
+func (e *CompareExpr) validateType() error {
+	var err error
+	switch e.Type {
+	case TypeAny, TypeString:
+		return nil
+	case TypeNumber:
+		_, err = strconv.ParseFloat(e.Value, 64)
+	case TypeBool:
+		_, err = strconv.ParseBool(e.Value)
+		if err == nil && e.Operator != OpEq && e.Operator != OpNotEq {
+			err = errors.Wrapf(ErrUnsupportedOperator, "passed %s", e.Operator.String())
+		}
+	case TypeNull:
+		if e.Value != "null" || (e.Operator != OpEq && e.Operator != OpNotEq) {
+			err = errors.Errorf("null is compared only by = and != with value 'null', passed %s '%s'", e.Operator.String(), e.Value)
+		}
+	default:
+		return errors.Errorf("unknown type '%s' of comparison", e.Type.String())
 	}
+
+	if err == nil && isLikeOperator(e.Operator) {
+		err = errors.Wrapf(ErrUnsupportedOperator, "passed %s", e.Operator.String())
+	}
+	return errors.Wrapf(err, "invalid %s value '%s' of path '%s'", e.Type.String(), e.Value, e.Path)
+}
+
+// isLikeOperator checks that operator matches strings by regular expression, values of other types never match it
+func isLikeOperator(op Operator) bool {
+	return op == OpLike || op == OpNotLike
 }
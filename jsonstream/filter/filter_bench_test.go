@@ -1,6 +1,8 @@
 package filter_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -73,3 +75,148 @@ func BenchmarkProcessElem_16KB_NestedField(b *testing.B) {
 		}
 	}
 }
+
+func benchmarkRules(b *testing.B) []filter.Rule {
+	var rules []filter.Rule
+	for i := 0; i < 1000; i++ {
+		cond, err := filter.NewConditionFromStr(fmt.Sprintf("name = user%d and children.age > %d", i, i%10))
+		if err != nil {
+			b.FailNow()
+		}
+		rules = append(rules, filter.Rule{ID: fmt.Sprintf("name-%d", i), Condition: *cond})
+	}
+	for i := 0; i < 100; i++ {
+		cond, err := filter.NewConditionFromStr(fmt.Sprintf("id > %d", i))
+		if err != nil {
+			b.FailNow()
+		}
+		rules = append(rules, filter.Rule{ID: fmt.Sprintf("id-%d", i), Condition: *cond})
+	}
+	return rules
+}
+
+func BenchmarkRuleSet_200B_1100Rules(b *testing.B) {
+	testElem := getFileContent(b, "./test/200b.json")
+	set, err := filter.NewRuleSet(benchmarkRules(b))
+	if err != nil {
+		b.FailNow()
+	}
+
+	for n := 0; n < b.N; n++ {
+		if _, err := set.Match(testElem); err != nil {
+			b.FailNow()
+		}
+	}
+}
+
+func BenchmarkProcessElem_200B_1100Rules(b *testing.B) {
+	testElem := getFileContent(b, "./test/200b.json")
+	rules := benchmarkRules(b)
+
+	for n := 0; n < b.N; n++ {
+		for _, rule := range rules {
+			if _, _, err := filter.ProcessElem(rule.Condition, testElem); err != nil {
+				b.FailNow()
+			}
+		}
+	}
+}
+
+func BenchmarkProgram_16KB_NotMatched(b *testing.B) {
+	testElem := getFileContent(b, "./test/16Kb.json")
+	program, err := filter.Compile(filter.Path("mix").Eq("absent"))
+	if err != nil {
+		b.FailNow()
+	}
+
+	for n := 0; n < b.N; n++ {
+		isOk, err := program.Match(testElem)
+		if isOk || err != nil {
+			b.FailNow()
+		}
+	}
+}
+
+func BenchmarkProgram_16KB_NotMatched_Prefilter(b *testing.B) {
+	testElem := getFileContent(b, "./test/16Kb.json")
+	program, err := filter.Compile(filter.Path("mix").Eq("absent"), filter.WithPrefilter())
+	if err != nil {
+		b.FailNow()
+	}
+
+	for n := 0; n < b.N; n++ {
+		isOk, err := program.Match(testElem)
+		if isOk || err != nil {
+			b.FailNow()
+		}
+	}
+}
+
+func BenchmarkProgram_16KB_NestedField_JSONBackend(b *testing.B) {
+	testElem := getFileContent(b, "./test/16Kb.json")
+	program, err := filter.Compile(filter.Path("coast.construction.specific").Gte(1219861845), filter.WithBackend(filter.BackendJSON))
+	if err != nil {
+		b.FailNow()
+	}
+
+	for n := 0; n < b.N; n++ {
+		isOk, err := program.Match(testElem)
+		if !isOk || err != nil {
+			b.FailNow()
+		}
+	}
+}
+
+type benchmarkPerson struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Emails   []string
+	Children []struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	} `json:"children"`
+	Job struct {
+		Company string `json:"company"`
+	} `json:"job"`
+}
+
+func benchmarkValue(b *testing.B) (*filter.Program, benchmarkPerson) {
+	var person benchmarkPerson
+	err := json.Unmarshal([]byte(`{"id": 1, "name": "John", "Emails": ["john@gmail.com", "john@mail.ru"],`+
+		` "children": [{"name": "Alex", "age": 10}, {"name": "Jinny", "age": 5}], "job": {"company": "Some firm"}}`), &person)
+	if err != nil {
+		b.FailNow()
+	}
+
+	program, err := filter.Compile(filter.Path("job.company").Eq("Some firm").And(filter.Path("children.age").Lt(6)))
+	if err != nil {
+		b.FailNow()
+	}
+	return program, person
+}
+
+func BenchmarkProgram_MatchValue(b *testing.B) {
+	program, person := benchmarkValue(b)
+
+	for n := 0; n < b.N; n++ {
+		isOk, err := program.MatchValue(person)
+		if !isOk || err != nil {
+			b.FailNow()
+		}
+	}
+}
+
+func BenchmarkProgram_MarshalAndMatch(b *testing.B) {
+	program, person := benchmarkValue(b)
+
+	for n := 0; n < b.N; n++ {
+		elem, err := json.Marshal(person)
+		if err != nil {
+			b.FailNow()
+		}
+		isOk, err := program.Match(elem)
+		if !isOk || err != nil {
+			b.FailNow()
+		}
+	}
+}
@@ -594,3 +594,165 @@ func TestProcessElem_DiffferentPathsAndValues(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessElem_Compound(t *testing.T) {
+	elem := []byte(`{"service": "api", "latency": 300, "status": 200, "tags": ["a", "b"]}`)
+
+	cases := []struct {
+		name         string
+		condition    string
+		vars         map[string]interface{}
+		expectedIsOk bool
+	}{
+		{
+			name:         "And. Ok",
+			condition:    "service = api and latency > 250",
+			expectedIsOk: true,
+		},
+		{
+			name:         "And. Not ok",
+			condition:    "service = api and latency > 500",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Or. Ok",
+			condition:    "service = web or latency > 250",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Or. Not ok",
+			condition:    "service = web || status != 200",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Not. Ok",
+			condition:    "not tags = c",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Not array. Not ok",
+			condition:    "not (tags = a)",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Brackets. Ok",
+			condition:    "(service = web or service = api) and (status < 300)",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Variables. Ok",
+			condition:    "latency > $threshold and service = $svc",
+			vars:         map[string]interface{}{"threshold": 250, "svc": "api"},
+			expectedIsOk: true,
+		},
+		{
+			name:         "Variables. Not ok",
+			condition:    "latency > $threshold and service = $svc",
+			vars:         map[string]interface{}{"threshold": 250.5, "svc": "web"},
+			expectedIsOk: false,
+		},
+		{
+			name:         "Variables are typed. Not ok",
+			condition:    "latency = $latency or status = $status",
+			vars:         map[string]interface{}{"latency": "300", "status": true},
+			expectedIsOk: false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromStr(testCase.condition, filter.WithVars(testCase.vars))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, actualIsOk, err := filter.ProcessElem(*condition, elem)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedIsOk, actualIsOk)
+		})
+	}
+}
+
+func TestProcessElem_Functions(t *testing.T) {
+	elem := []byte(`{"name": "Ann", "tags": ["Red", "Green"], "job": {"company": "Some firm"}, "empty": null}`)
+
+	cases := []struct {
+		name         string
+		condition    string
+		expectedIsOk bool
+	}{
+		{
+			name:         "Len of string. Ok",
+			condition:    "len(name) = 3",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Len of array. Not ok",
+			condition:    "len(tags) > 2",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Len of absent path. Not ok",
+			condition:    "len(absent) < 2",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Lower of array elems. Ok",
+			condition:    "lower(tags) = green",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Upper. Ok",
+			condition:    "upper(job.company) = 'SOME FIRM'",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Like. Ok",
+			condition:    "name ~ '^A.n$'",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Like of array elems. Ok",
+			condition:    "tags ~ ^G",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Not like. Not ok",
+			condition:    "name !~ '(?i)ann'",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Any with self path. Ok",
+			condition:    "any(tags, @ = Red or @ = Blue)",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Exists. Ok",
+			condition:    "exists(job.company)",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Exists of null. Ok",
+			condition:    "exists(empty)",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Exists of absent path. Not ok",
+			condition:    "exists(job.title)",
+			expectedIsOk: false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromStr(testCase.condition)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, actualIsOk, err := filter.ProcessElem(*condition, elem)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedIsOk, actualIsOk)
+		})
+	}
+}
@@ -0,0 +1,452 @@
+package filter
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// NewConditionFromJSONPath builds condition that is satisfied by element for which JSONPath query (RFC 9535)
+// returns non-empty nodelist, e.g. `$.children[?@.age > 5 && @.name == "Pit"]`.
+// Function extensions length, count, match, search and value are supported
+func NewConditionFromJSONPath(query string) (*Condition, error) {
+	expr, err := newJSONPathExpr(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Condition{expr: expr}, nil
+}
+
+func compileJSONPath(query string) (*jsonPathQuery, error) {
+	return newJSONPathParser(query).parse()
+}
+
+// newJSONPathExpr builds JSONPath predicate, query is compiled once for all elements
+func newJSONPathExpr(query string) (*JSONPathExpr, error) {
+	compiled, err := compileJSONPath(query)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONPathExpr{Query: query, compiled: compiled}, nil
+}
+
+// query returns query compiled by parser of expression
+func (e *JSONPathExpr) query() (*jsonPathQuery, error) {
+	if e.compiled != nil {
+		return e.compiled, nil
+	}
+	return compileJSONPath(e.Query)
+}
+
+// jsonPathContext holds root of element and current node of filter selector (@)
+type jsonPathContext struct {
+	root    interface{}
+	current interface{}
+}
+
+// jsonPathQuery is compiled absolute ($) or relative (@) query
+type jsonPathQuery struct {
+	isRelative bool
+	segments   []jsonPathSegment
+}
+
+type jsonPathSegment struct {
+	isDescendant bool
+	selectors    []jsonPathSelector
+}
+
+// jsonPathSelector appends nodes selected from children of node to res
+type jsonPathSelector interface {
+	selectNodes(ctx *jsonPathContext, node interface{}, res []interface{}) []interface{}
+}
+
+type jsonPathName string
+
+type jsonPathWildcard struct{}
+
+type jsonPathIndex int64
+
+type jsonPathSlice struct {
+	start, end, step          int64
+	hasStart, hasEnd, hasStep bool
+}
+
+type jsonPathFilter struct {
+	expr jsonPathLogical
+}
+
+// jsonPathLogical is a node of filter expression giving LogicalType result
+type jsonPathLogical interface {
+	test(ctx *jsonPathContext) bool
+}
+
+type jsonPathOr []jsonPathLogical
+
+type jsonPathAnd []jsonPathLogical
+
+type jsonPathNot struct {
+	operand jsonPathLogical
+}
+
+// jsonPathExists is satisfied when query selects at least one node
+type jsonPathExists struct {
+	query *jsonPathQuery
+}
+
+type jsonPathComparison struct {
+	op          string
+	left, right jsonPathOperand
+}
+
+// jsonPathOperand gives ValueType result, false result means Nothing (absence of value)
+type jsonPathOperand interface {
+	value(ctx *jsonPathContext) (interface{}, bool)
+}
+
+type jsonPathLiteral struct {
+	val interface{}
+}
+
+// jsonPathCall is call of function extension. Query argument is used by count and value,
+// operands are used by length, match and search
+type jsonPathCall struct {
+	name     string
+	query    *jsonPathQuery
+	operands []jsonPathOperand
+	// re is a pattern of match or search compiled by parser when it is a literal
+	re *regexp.Regexp
+}
+
+func (q *jsonPathQuery) eval(ctx *jsonPathContext) []interface{} {
+	nodes := []interface{}{ctx.root}
+	if q.isRelative {
+		nodes[0] = ctx.current
+	}
+
+	for _, segment := range q.segments {
+		var next []interface{}
+		for _, node := range nodes {
+			if segment.isDescendant {
+				next = segment.selectDescendants(ctx, node, next)
+			} else {
+				next = segment.selectChildren(ctx, node, next)
+			}
+		}
+		nodes = next
+	}
+
+	return nodes
+}
+
+// isSingular checks that query selects at most one node: it has only name and index segments
+func (q *jsonPathQuery) isSingular() bool {
+	for _, segment := range q.segments {
+		if segment.isDescendant || len(segment.selectors) != 1 {
+			return false
+		}
+		switch segment.selectors[0].(type) {
+		case jsonPathName, jsonPathIndex:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (q *jsonPathQuery) value(ctx *jsonPathContext) (interface{}, bool) {
+	nodes := q.eval(ctx)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0], true
+}
+
+func (s jsonPathSegment) selectChildren(ctx *jsonPathContext, node interface{}, res []interface{}) []interface{} {
+	for _, selector := range s.selectors {
+		res = selector.selectNodes(ctx, node, res)
+	}
+	return res
+}
+
+// selectDescendants applies selectors to node and all its descendants in document order
+func (s jsonPathSegment) selectDescendants(ctx *jsonPathContext, node interface{}, res []interface{}) []interface{} {
+	res = s.selectChildren(ctx, node, res)
+	for _, child := range jsonPathChildren(node) {
+		res = s.selectDescendants(ctx, child, res)
+	}
+	return res
+}
+
+// jsonPathChildren returns elements of array or values of object ordered by keys
+func jsonPathChildren(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case []interface{}:
+		return n
+	case map[string]interface{}:
+		keys := make([]string, 0, len(n))
+		for key := range n {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		res := make([]interface{}, 0, len(n))
+		for _, key := range keys {
+			res = append(res, n[key])
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+func (s jsonPathName) selectNodes(_ *jsonPathContext, node interface{}, res []interface{}) []interface{} {
+	if obj, ok := node.(map[string]interface{}); ok {
+		if val, ok := obj[string(s)]; ok {
+			res = append(res, val)
+		}
+	}
+	return res
+}
+
+func (jsonPathWildcard) selectNodes(_ *jsonPathContext, node interface{}, res []interface{}) []interface{} {
+	return append(res, jsonPathChildren(node)...)
+}
+
+func (s jsonPathIndex) selectNodes(_ *jsonPathContext, node interface{}, res []interface{}) []interface{} {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return res
+	}
+
+	idx := int64(s)
+	if idx < 0 {
+		idx += int64(len(arr))
+	}
+	if idx >= 0 && idx < int64(len(arr)) {
+		res = append(res, arr[idx])
+	}
+	return res
+}
+
+// selectNodes selects elements of array following slice algorithm of RFC 9535
+func (s jsonPathSlice) selectNodes(_ *jsonPathContext, node interface{}, res []interface{}) []interface{} {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return res
+	}
+
+	step := int64(1)
+	if s.hasStep {
+		step = s.step
+	}
+	if step == 0 {
+		return res
+	}
+
+	length := int64(len(arr))
+	normalize := func(idx int64) int64 {
+		if idx < 0 {
+			return idx + length
+		}
+		return idx
+	}
+	bound := func(idx, lower, upper int64) int64 {
+		if idx < lower {
+			return lower
+		}
+		if idx > upper {
+			return upper
+		}
+		return idx
+	}
+
+	if step > 0 {
+		start, end := int64(0), length
+		if s.hasStart {
+			start = normalize(s.start)
+		}
+		if s.hasEnd {
+			end = normalize(s.end)
+		}
+		for i := bound(start, 0, length); i < bound(end, 0, length); i += step {
+			res = append(res, arr[i])
+		}
+		return res
+	}
+
+	start, end := length-1, -length-1
+	if s.hasStart {
+		start = normalize(s.start)
+	}
+	if s.hasEnd {
+		end = normalize(s.end)
+	}
+	for i := bound(start, -1, length-1); i > bound(end, -1, length-1); i += step {
+		res = append(res, arr[i])
+	}
+	return res
+}
+
+func (s jsonPathFilter) selectNodes(ctx *jsonPathContext, node interface{}, res []interface{}) []interface{} {
+	for _, child := range jsonPathChildren(node) {
+		if s.expr.test(&jsonPathContext{root: ctx.root, current: child}) {
+			res = append(res, child)
+		}
+	}
+	return res
+}
+
+func (e jsonPathOr) test(ctx *jsonPathContext) bool {
+	for _, operand := range e {
+		if operand.test(ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e jsonPathAnd) test(ctx *jsonPathContext) bool {
+	for _, operand := range e {
+		if !operand.test(ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e jsonPathNot) test(ctx *jsonPathContext) bool {
+	return !e.operand.test(ctx)
+}
+
+func (e jsonPathExists) test(ctx *jsonPathContext) bool {
+	return len(e.query.eval(ctx)) > 0
+}
+
+func (e jsonPathComparison) test(ctx *jsonPathContext) bool {
+	left, hasLeft := e.left.value(ctx)
+	right, hasRight := e.right.value(ctx)
+
+	switch e.op {
+	case "==":
+		return jsonPathEqual(left, hasLeft, right, hasRight)
+	case "!=":
+		return !jsonPathEqual(left, hasLeft, right, hasRight)
+	case "<":
+		return hasLeft && hasRight && jsonPathLess(left, right)
+	case "<=":
+		return hasLeft && hasRight && jsonPathLess(left, right) || jsonPathEqual(left, hasLeft, right, hasRight)
+	case ">":
+		return hasLeft && hasRight && jsonPathLess(right, left)
+	case ">=":
+		return hasLeft && hasRight && jsonPathLess(right, left) || jsonPathEqual(left, hasLeft, right, hasRight)
+	default:
+		return false
+	}
+}
+
+// jsonPathEqual compares values, Nothing is equal only to Nothing
+func jsonPathEqual(left interface{}, hasLeft bool, right interface{}, hasRight bool) bool {
+	if !hasLeft || !hasRight {
+		return hasLeft == hasRight
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// jsonPathLess orders numbers and strings, values of other types are not ordered
+func jsonPathLess(left, right interface{}) bool {
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		return ok && l < r
+	case string:
+		r, ok := right.(string)
+		return ok && l < r
+	default:
+		return false
+	}
+}
+
+func (l jsonPathLiteral) value(*jsonPathContext) (interface{}, bool) {
+	return l.val, true
+}
+
+// value evaluates functions with ValueType result: length, count and value
+func (f *jsonPathCall) value(ctx *jsonPathContext) (interface{}, bool) {
+	switch f.name {
+	case "length":
+		val, ok := f.operands[0].value(ctx)
+		if !ok {
+			return nil, false
+		}
+		switch v := val.(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), true
+		case []interface{}:
+			return float64(len(v)), true
+		case map[string]interface{}:
+			return float64(len(v)), true
+		default:
+			return nil, false
+		}
+	case "count":
+		return float64(len(f.query.eval(ctx))), true
+	case "value":
+		return f.query.value(ctx)
+	default:
+		return nil, false
+	}
+}
+
+// test evaluates functions with LogicalType result: match and search
+func (f *jsonPathCall) test(ctx *jsonPathContext) bool {
+	val, _ := f.operands[0].value(ctx)
+	pattern, _ := f.operands[1].value(ctx)
+	str, isStr := val.(string)
+	patternStr, isPatternStr := pattern.(string)
+	if !isStr || !isPatternStr {
+		return false
+	}
+
+	re := f.re
+	if re == nil {
+		var err error
+		if re, err = compileRegexp(translateIRegexp(patternStr, f.name == "match")); err != nil {
+			return false
+		}
+	}
+
+	return re.MatchString(str)
+}
+
+// translateIRegexp translates I-Regexp (RFC 9485) to RE2 syntax: dot doesn't match line breaks,
+// match function checks the whole string
+func translateIRegexp(pattern string, isFullMatch bool) string {
+	var res strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			res.WriteByte(c)
+			i++
+			c = pattern[i]
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '.' && !inClass:
+			res.WriteString(`[^\n\r]`)
+			continue
+		}
+		res.WriteByte(c)
+	}
+
+	if isFullMatch {
+		return "^(?:" + res.String() + ")$"
+	}
+	return res.String()
+}
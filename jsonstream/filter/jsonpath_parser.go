@@ -0,0 +1,652 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxJSONPathInt is the max absolute value of integer in JSONPath query (I-JSON range)
+const maxJSONPathInt = 1<<53 - 1
+
+type jsonPathType int
+
+const (
+	jsonPathValueType jsonPathType = iota
+	jsonPathLogicalType
+	jsonPathNodesType
+)
+
+// jsonPathSignature describes parameters and result of function extension
+type jsonPathSignature struct {
+	params []jsonPathType
+	result jsonPathType
+}
+
+var (
+	jsonPathFunctions = map[string]jsonPathSignature{
+		"length": {params: []jsonPathType{jsonPathValueType}, result: jsonPathValueType},
+		"count":  {params: []jsonPathType{jsonPathNodesType}, result: jsonPathValueType},
+		"match":  {params: []jsonPathType{jsonPathValueType, jsonPathValueType}, result: jsonPathLogicalType},
+		"search": {params: []jsonPathType{jsonPathValueType, jsonPathValueType}, result: jsonPathLogicalType},
+		"value":  {params: []jsonPathType{jsonPathNodesType}, result: jsonPathValueType},
+	}
+
+	jsonPathFunctionNames = []string{"count", "length", "match", "search", "value"}
+
+	jsonPathComparisonOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+	expectedJSONPathSelector = []string{"name", "'*'", "index", "slice", "'?'"}
+)
+
+// jsonPathParser builds query from its string representation following grammar of RFC 9535
+type jsonPathParser struct {
+	*parser
+}
+
+func newJSONPathParser(input string) *jsonPathParser {
+	return &jsonPathParser{parser: newParser(input, parseOptions{})}
+}
+
+func (p *jsonPathParser) parse() (*jsonPathQuery, error) {
+	if p.peek() != '$' {
+		return nil, p.fail(p.pos, "query must start with root identifier", "'$'")
+	}
+	p.pos++
+
+	query, err := p.parseSegments(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.eof() {
+		return nil, p.fail(p.pos, "unexpected input", "'.'", "'..'", "'['", endOfInput)
+	}
+
+	return query, nil
+}
+
+// parseSegments parses segments following root (or current node) identifier
+func (p *jsonPathParser) parseSegments(isRelative bool) (*jsonPathQuery, error) {
+	query := &jsonPathQuery{isRelative: isRelative}
+	for {
+		start := p.pos
+		p.skipSpaces()
+
+		var segment jsonPathSegment
+		switch {
+		case strings.HasPrefix(p.input[p.pos:], ".."):
+			p.pos += 2
+			segment.isDescendant = true
+			if p.peek() == '[' {
+				break
+			}
+			selector, err := p.parseDotSelector()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = []jsonPathSelector{selector}
+		case p.peek() == '.':
+			p.pos++
+			selector, err := p.parseDotSelector()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = []jsonPathSelector{selector}
+		case p.peek() == '[':
+		default:
+			p.pos = start
+			return query, nil
+		}
+
+		if segment.selectors == nil {
+			selectors, err := p.parseBracketed()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = selectors
+		}
+
+		query.segments = append(query.segments, segment)
+	}
+}
+
+// parseDotSelector parses wildcard or member name shorthand following dot
+func (p *jsonPathParser) parseDotSelector() (jsonPathSelector, error) {
+	if p.peek() == '*' {
+		p.pos++
+		return jsonPathWildcard{}, nil
+	}
+
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !isJSONPathNameChar(r) || (p.pos == start && r >= '0' && r <= '9') {
+			break
+		}
+		p.pos += size
+	}
+
+	if p.pos == start {
+		return nil, p.fail(start, "invalid member name", "name", "'*'")
+	}
+
+	return jsonPathName(p.input[start:p.pos]), nil
+}
+
+func (p *jsonPathParser) parseBracketed() ([]jsonPathSelector, error) {
+	p.pos++
+	var selectors []jsonPathSelector
+	for {
+		p.skipSpaces()
+		selector, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return selectors, nil
+		default:
+			return nil, p.fail(p.pos, "unclosed bracketed selection", "','", "']'")
+		}
+	}
+}
+
+func (p *jsonPathParser) parseSelector() (jsonPathSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		name, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathName(name), nil
+	case c == '*':
+		p.pos++
+		return jsonPathWildcard{}, nil
+	case c == '?':
+		p.pos++
+		p.skipSpaces()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathFilter{expr: expr}, nil
+	case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+		return p.parseIndexOrSlice()
+	default:
+		return nil, p.fail(p.pos, "invalid selector", expectedJSONPathSelector...)
+	}
+}
+
+func (p *jsonPathParser) parseIndexOrSlice() (jsonPathSelector, error) {
+	var slice jsonPathSlice
+	var err error
+	if p.peek() != ':' {
+		if slice.start, err = p.readInt(); err != nil {
+			return nil, err
+		}
+		slice.hasStart = true
+
+		afterStart := p.pos
+		p.skipSpaces()
+		if p.peek() != ':' {
+			p.pos = afterStart
+			return jsonPathIndex(slice.start), nil
+		}
+	}
+
+	// optional end and step follow colons
+	for _, bound := range []struct {
+		value *int64
+		isSet *bool
+	}{{&slice.end, &slice.hasEnd}, {&slice.step, &slice.hasStep}} {
+		p.skipSpaces()
+		if p.peek() != ':' {
+			break
+		}
+		p.pos++
+		p.skipSpaces()
+
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			if *bound.value, err = p.readInt(); err != nil {
+				return nil, err
+			}
+			*bound.isSet = true
+		}
+	}
+
+	return slice, nil
+}
+
+// readInt reads integer without leading zeros in I-JSON range, "-0" is not allowed
+func (p *jsonPathParser) readInt() (int64, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	digitsStart := p.pos
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+
+	digits := p.input[digitsStart:p.pos]
+	if digits == "" || (len(digits) > 1 && digits[0] == '0') || p.input[start:p.pos] == "-0" {
+		return 0, p.fail(start, "invalid integer", "integer")
+	}
+
+	res, err := strconv.ParseInt(p.input[start:p.pos], 10, 64)
+	if err != nil || res > maxJSONPathInt || res < -maxJSONPathInt {
+		return 0, p.fail(start, "integer is out of range", "integer")
+	}
+
+	return res, nil
+}
+
+func (p *jsonPathParser) parseOr() (jsonPathLogical, error) {
+	return p.parseLogical("||", func(operands []jsonPathLogical) jsonPathLogical { return jsonPathOr(operands) },
+		func() (jsonPathLogical, error) {
+			return p.parseLogical("&&", func(operands []jsonPathLogical) jsonPathLogical { return jsonPathAnd(operands) },
+				p.parseBasic)
+		})
+}
+
+func (p *jsonPathParser) parseLogical(symbol string, join func([]jsonPathLogical) jsonPathLogical,
+	parseOperand func() (jsonPathLogical, error)) (jsonPathLogical, error) {
+	first, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []jsonPathLogical{first}
+	for {
+		start := p.pos
+		p.skipSpaces()
+		if !strings.HasPrefix(p.input[p.pos:], symbol) {
+			p.pos = start
+			break
+		}
+		p.pos += len(symbol)
+		p.skipSpaces()
+
+		next, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+
+	return join(operands), nil
+}
+
+// parseBasic parses negation, expression in brackets, comparison or test expression
+func (p *jsonPathParser) parseBasic() (jsonPathLogical, error) {
+	if p.peek() == '!' {
+		p.pos++
+		p.skipSpaces()
+
+		start := p.pos
+		operand, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := operand.(jsonPathComparison); ok && p.input[start] != '(' {
+			return nil, p.fail(start, "comparison must be enclosed in brackets to be negated", "'('")
+		}
+		return jsonPathNot{operand: operand}, nil
+	}
+
+	if p.peek() == '(' {
+		start := p.pos
+		p.pos++
+		p.skipSpaces()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		if p.peek() != ')' {
+			perr := p.fail(p.pos, "unclosed bracket", "')'")
+			perr.Msg += " opened at " + newParseError(p.input, start, "").position()
+			return nil, perr
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	start := p.pos
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	afterLeft := p.pos
+	p.skipSpaces()
+	op := p.readComparisonOp()
+	if op == "" {
+		p.pos = afterLeft
+		return p.toTest(left, start)
+	}
+
+	leftOperand, err := p.toComparable(left, start)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	rightStart := p.pos
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	rightOperand, err := p.toComparable(right, rightStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonPathComparison{op: op, left: leftOperand, right: rightOperand}, nil
+}
+
+func (p *jsonPathParser) readComparisonOp() string {
+	for _, op := range jsonPathComparisonOps {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// parseOperand parses query, function call or literal
+func (p *jsonPathParser) parseOperand() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		return p.parseSegments(c == '@')
+	case c == '\'' || c == '"':
+		str, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathLiteral{val: str}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.readNumber()
+	case c >= 'a' && c <= 'z':
+		return p.parseWord()
+	default:
+		return nil, p.fail(p.pos, "invalid filter expression", "'@'", "'$'", "literal", "function", "'('", "'!'")
+	}
+}
+
+// parseWord parses literals true, false, null or function call
+func (p *jsonPathParser) parseWord() (interface{}, error) {
+	start := p.pos
+	for !p.eof() && (p.peek() >= 'a' && p.peek() <= 'z' || p.peek() >= '0' && p.peek() <= '9' || p.peek() == '_') {
+		p.pos++
+	}
+	word := p.input[start:p.pos]
+
+	if p.peek() == '(' {
+		return p.parseFunc(word, start)
+	}
+
+	switch word {
+	case "true":
+		return jsonPathLiteral{val: true}, nil
+	case "false":
+		return jsonPathLiteral{val: false}, nil
+	case "null":
+		return jsonPathLiteral{val: nil}, nil
+	default:
+		perr := p.fail(start, "unknown literal '"+word+"'", "'true'", "'false'", "'null'")
+		perr.Found = word
+		return nil, perr
+	}
+}
+
+func (p *jsonPathParser) parseFunc(name string, start int) (*jsonPathCall, error) {
+	signature, ok := jsonPathFunctions[name]
+	if !ok {
+		perr := p.fail(start, "unknown function '"+name+"'")
+		perr.Found = name
+		perr.Suggestions = suggest(name, jsonPathFunctionNames)
+		return nil, perr
+	}
+
+	p.pos++
+	fn := &jsonPathCall{name: name}
+	for i, param := range signature.params {
+		p.skipSpaces()
+		if i > 0 {
+			if p.peek() != ',' {
+				return nil, p.fail(p.pos, "function "+name+" expects "+strconv.Itoa(len(signature.params))+" arguments", "','")
+			}
+			p.pos++
+			p.skipSpaces()
+		}
+
+		argStart := p.pos
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		if param == jsonPathNodesType {
+			query, ok := arg.(*jsonPathQuery)
+			if !ok {
+				return nil, p.fail(argStart, "argument of function "+name+" must be a query", "'@'", "'$'")
+			}
+			fn.query = query
+			continue
+		}
+
+		operand, err := p.toComparable(arg, argStart)
+		if err != nil {
+			return nil, err
+		}
+		fn.operands = append(fn.operands, operand)
+	}
+
+	p.skipSpaces()
+	if p.peek() != ')' {
+		return nil, p.fail(p.pos, "unclosed bracket of function call", "')'")
+	}
+	p.pos++
+
+	// literal pattern is compiled once, invalid one is left to evaluation which gives false for it
+	if name == "match" || name == "search" {
+		if lit, ok := fn.operands[1].(jsonPathLiteral); ok {
+			if pattern, ok := lit.val.(string); ok {
+				fn.re, _ = compileRegexp(translateIRegexp(pattern, name == "match"))
+			}
+		}
+	}
+
+	return fn, nil
+}
+
+// toTest checks that operand may be used as test expression: query or function with LogicalType result
+func (p *jsonPathParser) toTest(operand interface{}, start int) (jsonPathLogical, error) {
+	switch o := operand.(type) {
+	case *jsonPathQuery:
+		return jsonPathExists{query: o}, nil
+	case *jsonPathCall:
+		if jsonPathFunctions[o.name].result == jsonPathLogicalType {
+			return o, nil
+		}
+		return nil, p.fail(start, "result of function "+o.name+" must be compared", jsonPathComparisonOpsList()...)
+	default:
+		return nil, p.fail(start, "literal must be compared", jsonPathComparisonOpsList()...)
+	}
+}
+
+// toComparable checks that operand gives ValueType result: literal, singular query or function
+func (p *jsonPathParser) toComparable(operand interface{}, start int) (jsonPathOperand, error) {
+	switch o := operand.(type) {
+	case *jsonPathQuery:
+		if !o.isSingular() {
+			return nil, p.fail(start, "query must be singular to be used as value", "singular query")
+		}
+		return o, nil
+	case *jsonPathCall:
+		if jsonPathFunctions[o.name].result != jsonPathValueType {
+			return nil, p.fail(start, "result of function "+o.name+" can't be used as value", "value")
+		}
+		return o, nil
+	default:
+		return operand.(jsonPathLiteral), nil
+	}
+}
+
+// readNumber reads number literal: integer part without leading zeros, optional fraction and exponent
+func (p *jsonPathParser) readNumber() (jsonPathLiteral, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+
+	digits := p.skipDigits()
+	if digits == 0 || (digits > 1 && p.input[p.pos-digits] == '0') {
+		return jsonPathLiteral{}, p.fail(start, "invalid number", "number")
+	}
+
+	if p.peek() == '.' {
+		p.pos++
+		if p.skipDigits() == 0 {
+			return jsonPathLiteral{}, p.fail(start, "invalid number", "number")
+		}
+	}
+
+	if p.peek() == 'e' || p.peek() == 'E' {
+		p.pos++
+		if p.peek() == '-' || p.peek() == '+' {
+			p.pos++
+		}
+		if p.skipDigits() == 0 {
+			return jsonPathLiteral{}, p.fail(start, "invalid number", "number")
+		}
+	}
+
+	res, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return jsonPathLiteral{}, p.fail(start, "invalid number", "number")
+	}
+
+	return jsonPathLiteral{val: res}, nil
+}
+
+func (p *jsonPathParser) skipDigits() int {
+	start := p.pos
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	return p.pos - start
+}
+
+// readString reads string literal in single or double quotes with JSON escapes
+func (p *jsonPathParser) readString() (string, error) {
+	start := p.pos
+	quote := p.peek()
+	p.pos++
+
+	var res strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == quote:
+			p.pos++
+			return res.String(), nil
+		case c < 0x20:
+			return "", p.fail(p.pos, "control character must be escaped in string literal")
+		case c == '\\':
+			r, err := p.readEscape(quote)
+			if err != nil {
+				return "", err
+			}
+			res.WriteRune(r)
+		default:
+			res.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	perr := p.fail(start, "unterminated string literal", "'"+string(quote)+"'")
+	perr.Found = string(quote)
+	return "", perr
+}
+
+func (p *jsonPathParser) readEscape(quote byte) (rune, error) {
+	start := p.pos
+	p.pos++
+
+	escapes := map[byte]rune{'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', '/': '/', '\\': '\\'}
+	c := p.peek()
+	if r, ok := escapes[c]; ok {
+		p.pos++
+		return r, nil
+	}
+	if c == quote {
+		p.pos++
+		return rune(c), nil
+	}
+	if c != 'u' {
+		return 0, p.fail(start, "invalid escape sequence", `'\uXXXX'`, `'\n'`, `'\\'`, `'\`+string(quote)+"'")
+	}
+
+	r, ok := p.readHex()
+	switch {
+	case !ok:
+		return 0, p.fail(start, "invalid unicode escape sequence", `'\uXXXX'`)
+	case utf16.IsSurrogate(r) && r < 0xDC00 && strings.HasPrefix(p.input[p.pos:], `\u`):
+		p.pos++
+		low, ok := p.readHex()
+		if pair := utf16.DecodeRune(r, low); ok && pair != utf8.RuneError {
+			return pair, nil
+		}
+	case !utf16.IsSurrogate(r):
+		return r, nil
+	}
+
+	return 0, p.fail(start, "invalid surrogate pair", `'\uXXXX'`)
+}
+
+// readHex reads 4 hex digits following "u" of escape sequence
+func (p *jsonPathParser) readHex() (rune, bool) {
+	if p.pos+5 > len(p.input) {
+		return 0, false
+	}
+
+	res, err := strconv.ParseUint(p.input[p.pos+1:p.pos+5], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	p.pos += 5
+
+	return rune(res), true
+}
+
+func jsonPathComparisonOpsList() []string {
+	res := make([]string, 0, len(jsonPathComparisonOps))
+	for _, op := range jsonPathComparisonOps {
+		res = append(res, "'"+op+"'")
+	}
+	return res
+}
+
+// isJSONPathNameChar checks that rune is allowed in member name shorthand
+func isJSONPathNameChar(r rune) bool {
+	return r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+		r >= 0x80 && r != utf8.RuneError
+}
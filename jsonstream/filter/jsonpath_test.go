@@ -0,0 +1,133 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConditionFromJSONPath(t *testing.T) {
+	elem := []byte(`{"id": 1, "name": "John", "emails": ["john@gmail.com", "john@mail.ru"],` +
+		` "children": [{"name": "Alex", "age": 10}, {"name": "Pit", "age": 5, "toys": []}],` +
+		` "job": {"company": "Some firm", "tags": {"a": 1, "b": null}}, "note": "line1\nline2", "nothing": null}`)
+
+	cases := []struct {
+		name         string
+		query        string
+		expectedIsOk bool
+	}{
+		{name: "Root", query: "$", expectedIsOk: true},
+		{name: "Member. Ok", query: "$.job.company", expectedIsOk: true},
+		{name: "Member. Not ok", query: "$.job.salary", expectedIsOk: false},
+		{name: "Null member is a node", query: "$.nothing", expectedIsOk: true},
+		{name: "Bracketed names", query: `$['job']["company"]`, expectedIsOk: true},
+		{name: "Escaped name", query: `$['j\u006fb'].company`, expectedIsOk: true},
+		{name: "Index. Ok", query: "$.emails[1]", expectedIsOk: true},
+		{name: "Negative index. Ok", query: "$.emails[-2]", expectedIsOk: true},
+		{name: "Index. Not ok", query: "$.emails[2]", expectedIsOk: false},
+		{name: "Index of object. Not ok", query: "$.job[0]", expectedIsOk: false},
+		{name: "Wildcard of empty array. Not ok", query: "$.children[1].toys[*]", expectedIsOk: false},
+		{name: "Slice. Ok", query: "$.emails[1:]", expectedIsOk: true},
+		{name: "Slice. Not ok", query: "$.emails[2:10]", expectedIsOk: false},
+		{name: "Reversed slice. Ok", query: "$.emails[::-1]", expectedIsOk: true},
+		{name: "Zero step slice. Not ok", query: "$.emails[::0]", expectedIsOk: false},
+		{name: "Descendants. Ok", query: "$..age", expectedIsOk: true},
+		{name: "Descendants. Not ok", query: "$..salary", expectedIsOk: false},
+		{name: "Filter. Ok", query: `$.children[?@.age > 5 && @.name == "Alex"]`, expectedIsOk: true},
+		{name: "Filter. Not ok", query: `$.children[?@.age > 5 && @.name == "Pit"]`, expectedIsOk: false},
+		{name: "Filter with or and not", query: `$.children[?!(@.age < 10) || @.name == 'Nobody']`, expectedIsOk: true},
+		{name: "Existence test", query: "$.children[?@.toys]", expectedIsOk: true},
+		{name: "Negated existence test", query: "$.children[?!@.age]", expectedIsOk: false},
+		{name: "Filter of object values", query: "$.job.tags[?@ == 1]", expectedIsOk: true},
+		{name: "Null equals null", query: "$.job.tags[?@ == null]", expectedIsOk: true},
+		{name: "Absent is not null", query: "$[?@.salary == null]", expectedIsOk: false},
+		{name: "Absent equals absent", query: "$.children[?@.salary == @.bonus]", expectedIsOk: true},
+		{name: "Absolute query in filter", query: "$.children[?@.age == $.children[1].age]", expectedIsOk: true},
+		{name: "Strings are not ordered with numbers", query: "$.children[?@.name > 1]", expectedIsOk: false},
+		{name: "Arrays are compared deeply", query: "$.children[?@.toys == $.children[1].toys]", expectedIsOk: true},
+		{name: "Length of string", query: "$.emails[?length(@) == 14]", expectedIsOk: true},
+		{name: "Length of number is nothing", query: "$.children[?length(@.age) >= 0]", expectedIsOk: false},
+		{name: "Count", query: "$.job[?count(@.*) == 2]", expectedIsOk: true},
+		{name: "Match is anchored", query: "$.emails[?match(@, 'gmail')]", expectedIsOk: false},
+		{name: "Match", query: "$.emails[?match(@, '[a-z]+@gmail\\\\.com')]", expectedIsOk: true},
+		{name: "Search", query: `$.emails[?search(@, "mail\\.ru$")]`, expectedIsOk: true},
+		{name: "Dot doesn't match line break", query: "$[?match(@, 'line1.line2')]", expectedIsOk: false},
+		{name: "Invalid regular expression doesn't match", query: "$.emails[?search(@, '(')]", expectedIsOk: false},
+		{name: "Value", query: "$[?value(@..company) == 'Some firm']", expectedIsOk: true},
+		{name: "Value of several nodes is nothing", query: "$[?value(@..name) == 'John']", expectedIsOk: false},
+		{name: "Whitespaces", query: "$.children[ ?  @.age >= 10 && ( @.name != 'x' ) , 0 ]", expectedIsOk: true},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromJSONPath(testCase.query)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, actualIsOk, err := filter.ProcessElem(*condition, elem)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedIsOk, actualIsOk)
+		})
+	}
+}
+
+func TestNewConditionFromJSONPath_Negative(t *testing.T) {
+	cases := []struct {
+		name           string
+		query          string
+		expectedOffset int
+		expectedMsg    string
+	}{
+		{name: "Missing root", query: "children[0]", expectedOffset: 0, expectedMsg: "query must start with root identifier"},
+		{name: "Leading whitespace", query: " $", expectedOffset: 0, expectedMsg: "query must start with root identifier"},
+		{name: "Trailing whitespace", query: "$.a ", expectedOffset: 3, expectedMsg: "unexpected input"},
+		{name: "Name starts with digit", query: "$.1a", expectedOffset: 2, expectedMsg: "invalid member name"},
+		{name: "Unclosed selection", query: "$['a'", expectedOffset: 5, expectedMsg: "unclosed bracketed selection"},
+		{name: "Leading zero", query: "$[01]", expectedOffset: 2, expectedMsg: "invalid integer"},
+		{name: "Negative zero", query: "$[-0]", expectedOffset: 2, expectedMsg: "invalid integer"},
+		{name: "Index out of range", query: "$[9007199254740992]", expectedOffset: 2, expectedMsg: "integer is out of range"},
+		{name: "Invalid escape", query: `$['\a']`, expectedOffset: 3, expectedMsg: "invalid escape sequence"},
+		{name: "Lone surrogate", query: `$['\uD800']`, expectedOffset: 3, expectedMsg: "invalid surrogate pair"},
+		{name: "Unterminated string", query: `$["a]`, expectedOffset: 2, expectedMsg: "unterminated string literal"},
+		{name: "Non singular comparison", query: "$[?@.* == 1]", expectedOffset: 3, expectedMsg: "query must be singular to be used as value"},
+		{name: "Literal test", query: "$[?true]", expectedOffset: 3, expectedMsg: "literal must be compared"},
+		{name: "Value function as test", query: "$[?length(@)]", expectedOffset: 3, expectedMsg: "result of function length must be compared"},
+		{name: "Logical function compared", query: "$[?match(@, 'a') == true]", expectedOffset: 3, expectedMsg: "result of function match can't be used as value"},
+		{name: "Unknown function", query: "$[?lenght(@) == 1]", expectedOffset: 3, expectedMsg: "unknown function 'lenght'"},
+		{name: "Wrong arguments count", query: "$[?search(@)]", expectedOffset: 11, expectedMsg: "function search expects 2 arguments"},
+		{name: "Literal as nodes argument", query: "$[?count(1) == 1]", expectedOffset: 9, expectedMsg: "argument of function count must be a query"},
+		{name: "Negated comparison", query: "$[?!@.a == 1]", expectedOffset: 4, expectedMsg: "comparison must be enclosed in brackets to be negated"},
+		{name: "Invalid number", query: "$[?@.a == 01]", expectedOffset: 10, expectedMsg: "invalid number"},
+		{name: "Unclosed bracket", query: "$[?(@.a]", expectedOffset: 7, expectedMsg: "unclosed bracket opened at 1:4"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromJSONPath(testCase.query)
+			assert.Nil(t, condition)
+
+			perr, ok := err.(*filter.ParseError)
+			if assert.True(t, ok, "expected *ParseError, got %T", err) {
+				assert.Equal(t, testCase.expectedOffset, perr.Offset)
+				assert.Equal(t, testCase.expectedMsg, perr.Msg)
+			}
+		})
+	}
+}
+
+func TestNewConditionFromStr_JSONPath(t *testing.T) {
+	condition, err := filter.NewConditionFromStr(`id = 1 and jsonpath('$.children[?@.age > 5]')`)
+	if assert.NoError(t, err) {
+		_, isOk, err := filter.ProcessElem(*condition, []byte(`{"id": 1, "children": [{"age": 10}]}`))
+		assert.NoError(t, err)
+		assert.True(t, isOk)
+	}
+
+	_, err = filter.NewConditionFromStr(`jsonpath('$.children[?@.age >]')`)
+	if perr, ok := err.(*filter.ParseError); assert.True(t, ok, "expected *ParseError, got %T", err) {
+		assert.Equal(t, 29, perr.Offset)
+		assert.Equal(t, "invalid JSONPath query: invalid filter expression", perr.Msg)
+	}
+}
@@ -0,0 +1,77 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// recordSeparator starts every json text of json text sequence (RFC 7464)
+const recordSeparator = 0x1E
+
+// seqReader splits json text sequence into records by record separators. Whitespaces around json texts
+// are dropped, empty records are ignored. Truncated json texts give ErrMalformedValue as RFC 7464 requires
+// to detect them: arrays, objects and strings must be complete, numbers and literals must be followed
+// by whitespace, otherwise they may be truncated
+type seqReader struct {
+	records *recordReader
+	// num is a number of the last read json text starting from 1
+	num int64
+	// start is an offset of the last read json text
+	start int64
+}
+
+func newSeqReader(reader *bufio.Reader, maxSize int) *seqReader {
+	return &seqReader{records: newRecordReader(reader, reader.Size(), recordSeparator, maxSize)}
+}
+
+func (r *seqReader) position() (int64, int64) {
+	return r.num, r.start
+}
+
+func (r *seqReader) buffered() int {
+	return r.records.buffered()
+}
+
+// next returns the next json text, bytes before the first record separator give ErrMalformedValue
+func (r *seqReader) next() ([]byte, error) {
+	for {
+		record, err := r.records.next()
+		if err != nil && !isRecordError(err) {
+			return nil, err
+		}
+
+		num, start := r.records.position()
+		text := bytes.TrimLeft(record, " \t\r\n")
+		start += int64(len(record) - len(text))
+		trimmed := bytes.TrimRight(text, " \t\r\n")
+		if err == nil && len(trimmed) == 0 {
+			continue
+		}
+
+		r.num++
+		r.start = start
+		switch {
+		case err != nil:
+			return nil, err
+		case num == 1:
+			// the first record precedes the first record separator
+			return nil, errors.Wrap(ErrMalformedValue, "json text without record separator")
+		case isTruncatedText(trimmed, len(trimmed) < len(text)):
+			return nil, errors.Wrap(ErrMalformedValue, "json text is truncated")
+		default:
+			return trimmed, nil
+		}
+	}
+}
+
+// isTruncatedText checks that json text is incomplete, broken texts aren't truncated ones
+func isTruncatedText(text []byte, followedBySpace bool) bool {
+	var scanner valueScanner
+	_, done, err := scanner.scan(text)
+	if err != nil || done {
+		return false
+	}
+	return !scanner.scalar || !followedBySpace
+}
@@ -0,0 +1,289 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// matchElem solves accordance of element to expression evaluating it over element in representation of backend.
+// Lazy backend hands invalid elements and errors of evaluation over to json backend,
+// so results and errors are the same for all backends
+func matchElem(elem []byte, expr Expr, backend Backend) (bool, error) {
+	if backend == BackendLazy {
+		if data, ok := validJSON(elem); ok {
+			if isOk, err := checkExpr(&lazyDoc{data: data}, expr); err == nil {
+				return isOk, nil
+			}
+		}
+	}
+
+	doc, err := decodeElem(elem)
+	if err != nil {
+		return false, err
+	}
+
+	isOk, err := checkExpr(doc, expr)
+	return isOk, errors.Wrap(err, "error check path")
+}
+
+// lazyDoc is a raw valid element, values are decoded only for paths of condition
+type lazyDoc struct {
+	data []byte
+}
+
+func (d *lazyDoc) compare(e *CompareExpr) (bool, error) {
+	if e.Type == TypeNull {
+		// comparison with null is satisfied by missing value too
+		val, _ := d.lookup(e.Path)
+		return chechkValue(val, *e)
+	}
+	if e.Path == selfPath {
+		return compareLazyValue(d.data, e)
+	}
+	return compareLazyPath(d.data, e.Path, e)
+}
+
+func (d *lazyDoc) lookup(path string) (interface{}, bool) {
+	return lazyLookup(d.data, path)
+}
+
+func (d *lazyDoc) exists(path string) bool {
+	return path == selfPath || lazyFound(d.data, path)
+}
+
+func (d *lazyDoc) anyElem(e *AnyExpr) (bool, error) {
+	value, found, throughArray := lazyResolve(d.data, e.Path)
+	if throughArray {
+		// array found by path going through arrays consists of values found in their elements
+		val, _ := lazyLookup(d.data, e.Path)
+		return anyDecoded(val, e)
+	}
+
+	if !found || value[0] != '[' {
+		return false, nil
+	}
+
+	// the same document is reused for all elements, evaluation doesn't keep it
+	elemDoc := &lazyDoc{}
+	for i := firstItem(value, 0); value[i] != ']'; {
+		elemDoc.data, i = nextElem(value, i)
+		if isOk, err := checkExpr(elemDoc, e.Operand); err != nil || isOk {
+			return isOk, err
+		}
+	}
+	return false, nil
+}
+
+func (d *lazyDoc) decode() interface{} {
+	return decodeRaw(d.data)
+}
+
+// compareLazyPath compares values found by path like chechkValue does: values found in elements
+// of arrays on the path and elements of found arrays are compared until one of them satisfies condition
+func compareLazyPath(data []byte, path string, e *CompareExpr) (bool, error) {
+	key, rest, last := nextKey(path)
+	switch data[0] {
+	case '{':
+		value, found := lazyMember(data, key)
+		if !found {
+			return false, nil
+		}
+		if last {
+			return compareLazyValue(value, e)
+		}
+		return compareLazyPath(value, rest, e)
+	case '[':
+		for i := firstItem(data, 0); data[i] != ']'; {
+			var elem []byte
+			elem, i = nextElem(data, i)
+			if isOk, err := compareLazyPath(elem, path, e); err != nil || isOk {
+				return isOk, err
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// compareLazyValue compares raw value or elements of array with value of condition
+func compareLazyValue(value []byte, e *CompareExpr) (bool, error) {
+	switch value[0] {
+	case '[':
+		for i := firstItem(value, 0); value[i] != ']'; {
+			var elem []byte
+			elem, i = nextElem(value, i)
+			if isOk, err := compareLazyValue(elem, e); err != nil || isOk {
+				return isOk, err
+			}
+		}
+		return false, nil
+	case '"':
+		raw := value[1 : len(value)-1]
+		if bytes.IndexByte(raw, '\\') >= 0 || !utf8.Valid(raw) {
+			var str string
+			if err := json.Unmarshal(value, &str); err != nil {
+				return false, err
+			}
+			return checkString(str, *e)
+		}
+		return checkLazyString(raw, e)
+	case 't', 'f':
+		return checkBool(value[0] == 't', *e)
+	case 'n':
+		return checkNil(*e)
+	case '{':
+		return false, errors.Wrapf(ErrUnsupportedType, "unsupported type of val by path '%s'", e.Path)
+	default:
+		num, err := strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return false, err
+		}
+		return checkFloat64(num, *e)
+	}
+}
+
+// checkLazyString compares raw string without escape sequences like checkString does
+func checkLazyString(raw []byte, e *CompareExpr) (bool, error) {
+	if !e.accepts(TypeString) {
+		return false, nil
+	}
+
+	switch e.Operator {
+	case OpEq:
+		return string(raw) == e.Value, nil
+	case OpNotEq:
+		return string(raw) != e.Value, nil
+	case OpGt:
+		return string(raw) > e.Value, nil
+	case OpGte:
+		return string(raw) >= e.Value, nil
+	case OpLt:
+		return string(raw) < e.Value, nil
+	case OpLte:
+		return string(raw) <= e.Value, nil
+	case OpLike, OpNotLike:
+		re, err := e.regexp()
+		if err != nil {
+			return false, err
+		}
+		return re.Match(raw) == (e.Operator == OpLike), nil
+	default:
+		return false, errors.Wrapf(ErrUnsupportedOperator, "passed %s", e.Operator.String())
+	}
+}
+
+// lazyResolve finds raw value by path which doesn't go through arrays
+func lazyResolve(data []byte, path string) (value []byte, found bool, throughArray bool) {
+	if path == selfPath {
+		return data, true, false
+	}
+
+	for {
+		key, rest, last := nextKey(path)
+		switch data[0] {
+		case '{':
+			if data, found = lazyMember(data, key); !found {
+				return nil, false, false
+			}
+		case '[':
+			return nil, false, true
+		default:
+			return nil, false, false
+		}
+
+		if last {
+			return data, true, false
+		}
+		path = rest
+	}
+}
+
+// lazyFound checks that path is present in element like lookupDecoded does
+func lazyFound(data []byte, path string) bool {
+	key, rest, last := nextKey(path)
+	switch data[0] {
+	case '{':
+		value, found := lazyMember(data, key)
+		return found && (last || lazyFound(value, rest))
+	case '[':
+		for i := firstItem(data, 0); data[i] != ']'; {
+			var elem []byte
+			elem, i = nextElem(data, i)
+			if lazyFound(elem, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lazyLookup decodes value found by path, it's the same value as lookupDecoded gives
+func lazyLookup(data []byte, path string) (interface{}, bool) {
+	if path == selfPath {
+		return decodeRaw(data), true
+	}
+	return lazyLookupKeys(data, path)
+}
+
+func lazyLookupKeys(data []byte, path string) (interface{}, bool) {
+	key, rest, last := nextKey(path)
+	switch data[0] {
+	case '{':
+		value, found := lazyMember(data, key)
+		if !found {
+			return nil, false
+		}
+		if last {
+			return decodeRaw(value), true
+		}
+		return lazyLookupKeys(value, rest)
+	case '[':
+		res := []interface{}{}
+		for i := firstItem(data, 0); data[i] != ']'; {
+			var elem []byte
+			elem, i = nextElem(data, i)
+			if val, found := lazyLookupKeys(elem, path); found {
+				res = append(res, val)
+			}
+		}
+		if len(res) == 0 {
+			return nil, false
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+func decodeRaw(data []byte) interface{} {
+	var val interface{}
+	if err := json.Unmarshal(data, &val); err != nil {
+		return nil
+	}
+	return val
+}
+
+// lazyMember finds value of key in raw object, the last of duplicated keys is used like encoding/json does
+func lazyMember(data []byte, key string) (value []byte, found bool) {
+	for i := firstItem(data, 0); data[i] != '}'; {
+		var rawKey, rawValue []byte
+		rawKey, rawValue, i = nextMember(data, i)
+		if keyEquals(rawKey, key) {
+			value, found = rawValue, true
+		}
+	}
+	return value, found
+}
+
+// nextKey splits path into the first key and rest of path, path is split by dots like lookupDecoded does
+func nextKey(path string) (key string, rest string, last bool) {
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		return path[:i], path[i+1:], false
+	}
+	return path, "", true
+}
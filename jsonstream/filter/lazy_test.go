@@ -0,0 +1,84 @@
+package filter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessElem_SameAsParsed(t *testing.T) {
+	elems := []string{
+		`{"id": 1, "name": "John", "emails": ["john@gmail.com", "john@mail.ru"], "children": [{"name": "Alex", "age": 10}, {"name": "Pit", "age": 5, "toys": ["car", ["ball"]]}], "job": {"company": "Some firm"}}`,
+		` { "id" : 1e0 , "name":"John", "name": "Jack", "job": {"company": null}, "children": [[{"age": 7}], {"name": null}] } `,
+		`{"name": "John", "name": "Jack", "id": -0.5E+1, "emails": [], "children": {"age": 3}}`,
+		`{"name": "J` + "\xff" + `hn", "job": {"company": "Some\tfirm"}, "flag": true, "id": "1"}`,
+		`{"id": {"x": 1}, "name": ["John", {"a": 1}], "children": [{"age": "5"}, {"age": true}]}`,
+		`[{"name": "John", "id": 1}, {"name": "Alex"}, "x", [{"id": 2}]]`,
+		`{"children": [{"name": "Alex"}, []]}`,
+		`"John"`,
+		`17`,
+		`null`,
+		`{"id": 1e400}`,
+		`{"id": 1, "name": "John"} x`,
+		`{"id": 1,}`,
+		`{"name": "bad \x01"}`,
+		`{"name": "bad \u12"}`,
+		`{"id": 01}`,
+		`{"a": ` + strings.Repeat("[", 10000) + strings.Repeat("]", 10000) + `}`,
+		`{"a": ` + strings.Repeat("[", 10001) + strings.Repeat("]", 10001) + `}`,
+		``,
+	}
+	exprs := []string{
+		"name = John",
+		"name != John",
+		`name ~ "^J.h"`,
+		"name > Ja and name <= John",
+		"id = 1",
+		"id < 0",
+		"flag = true",
+		"job.company = 'Some\tfirm' or job.company = 'Some firm'",
+		"children.age > 6",
+		"children.toys = ball",
+		"exists(job.company) and not exists(children.name)",
+		"exists(children.toys)",
+		"any(children, age < 6 or name = Alex)",
+		"any(children.toys, @ = car)",
+		"any(emails, @ ~ mail)",
+		"len(emails) = 2 or len(children.age) = 2",
+		"len(children.age) = 0 or len(children.age) > 0",
+		"lower(name) = john",
+		"jsonpath('$.children[?@.age > 5]')",
+		"any(children, jsonpath('$.toys'))",
+		"@ = John or @ > 10",
+		"exists(@) and len(@) > 1",
+		"id = x",
+		"name = a.",
+		"a = 1 or a.b = 1",
+	}
+
+	for _, expr := range exprs {
+		cond, err := filter.NewConditionFromStr(expr)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+		program, err := filter.Compile(*cond)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+
+		for _, elem := range elems {
+			// captures are collected over parsed element
+			expected, expectedErr := program.MatchCaptures([]byte(elem))
+
+			_, isOk, err := filter.ProcessElem(*cond, []byte(elem))
+			assert.Equal(t, expected.IsOk, isOk, "%s on %.80s", expr, elem)
+			if expectedErr != nil && assert.Error(t, err, "%s on %.80s", expr, elem) {
+				assert.Equal(t, expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err, "%s on %.80s", expr, elem)
+			}
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	luceneExistsField = "_exists_"
+	luceneWildcard    = "*"
+)
+
+// luceneOccur defines how clause of Lucene query affects result: it may be required (+), prohibited (-, NOT)
+// or optional (without prefix)
+type luceneOccur int
+
+const (
+	occurShould luceneOccur = iota
+	occurMust
+	occurMustNot
+)
+
+var expectedLuceneClause = []string{"field:value", "'('", "'NOT'", "'+'", "'-'"}
+
+// NewConditionFromLucene builds condition from Lucene/Kibana-style query, e.g.
+// `status:500 AND path:/api/* AND NOT user.name:"bot"`.
+// Supported: field:value, quoted phrases, wildcards (* and ?), ranges [a TO b] and {a TO b}, comparisons
+// field:>value, field:* and _exists_:field, field groups field:(a OR b), AND/OR/NOT (&&, ||, !),
+// +/- prefixes and brackets. Clauses without operator are joined by OR
+func NewConditionFromLucene(query string) (*Condition, error) {
+	expr, err := newLuceneParser(query).parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Condition{expr: expr}, nil
+}
+
+// luceneParser builds expression tree from Lucene query. Phrases and values are matched exactly,
+// there is no analysis of text.
+//
+// Grammar:
+//
+//	query   = clause {["OR" | "||"] clause}
+//	clause  = unary {("AND" | "&&") unary}
+//	unary   = ("NOT" | "!" | "-") unary | "+" primary | primary
+//	primary = "(" query ")" | field ":" value
+//	value   = term | quoted phrase | range | (">" | ">=" | "<" | "<=") term | "(" value query ")"
+//	range   = ("[" | "{") bound "TO" bound ("]" | "}")
+type luceneParser struct {
+	*parser
+}
+
+func newLuceneParser(input string) *luceneParser {
+	return &luceneParser{parser: newParser(input, parseOptions{})}
+}
+
+func (p *luceneParser) parse() (Expr, error) {
+	expr, err := p.parseQuery(p.parseField)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if !p.eof() {
+		return nil, p.fail(p.pos, "unexpected input", "'AND'", "'OR'", endOfInput)
+	}
+
+	return expr, nil
+}
+
+// parseQuery parses clauses up to the end of input or closing bracket and joins them with Lucene logic:
+// all required clauses must match, when there are no required clauses at least one optional clause must match,
+// none of prohibited clauses may match
+func (p *luceneParser) parseQuery(parsePrimary func() (Expr, error)) (Expr, error) {
+	var must, mustNot, should []Expr
+	for {
+		p.skipSpaces()
+		if p.eof() || p.peek() == ')' {
+			break
+		}
+
+		if len(must)+len(mustNot)+len(should) > 0 && p.acceptKeyword("or", "||") {
+			p.skipSpaces()
+			if p.eof() || p.peek() == ')' {
+				return nil, p.fail(p.pos, "missing clause after OR", expectedLuceneClause...)
+			}
+		}
+
+		occur, expr, err := p.parseClause(parsePrimary)
+		if err != nil {
+			return nil, err
+		}
+
+		switch occur {
+		case occurMust:
+			must = append(must, expr)
+		case occurMustNot:
+			mustNot = append(mustNot, expr)
+		default:
+			should = append(should, expr)
+		}
+	}
+
+	if len(must)+len(mustNot)+len(should) == 0 {
+		return nil, p.fail(p.pos, "empty query", expectedLuceneClause...)
+	}
+
+	res := must
+	if len(must) == 0 && len(should) > 0 {
+		res = append(res, joinExprs(LogicalOr, should))
+	}
+	for _, expr := range mustNot {
+		res = append(res, &NotExpr{Operand: expr})
+	}
+
+	return joinExprs(LogicalAnd, res), nil
+}
+
+func (p *luceneParser) parseClause(parsePrimary func() (Expr, error)) (luceneOccur, Expr, error) {
+	occur, first, err := p.parseUnary(parsePrimary)
+	if err != nil {
+		return occurShould, nil, err
+	}
+
+	operands := []Expr{applyOccur(occur, first)}
+	for p.acceptKeyword("and", "&&") {
+		nextOccur, next, err := p.parseUnary(parsePrimary)
+		if err != nil {
+			return occurShould, nil, err
+		}
+		operands = append(operands, applyOccur(nextOccur, next))
+	}
+
+	if len(operands) == 1 {
+		return occur, first, nil
+	}
+
+	return occurShould, &LogicalExpr{Operator: LogicalAnd, Operands: operands}, nil
+}
+
+func (p *luceneParser) parseUnary(parsePrimary func() (Expr, error)) (luceneOccur, Expr, error) {
+	p.skipSpaces()
+	if p.peek() == '+' {
+		p.pos++
+		expr, err := parsePrimary()
+		return occurMust, expr, err
+	}
+
+	isProhibited := p.peek() == '-'
+	if isProhibited {
+		p.pos++
+	} else {
+		isProhibited = p.acceptKeyword("not", "!")
+	}
+
+	if isProhibited {
+		occur, expr, err := p.parseUnary(parsePrimary)
+		if err != nil {
+			return occurShould, nil, err
+		}
+		return occurMustNot, applyOccur(occur, expr), nil
+	}
+
+	expr, err := parsePrimary()
+	return occurShould, expr, err
+}
+
+// applyOccur negates prohibited clause
+func applyOccur(occur luceneOccur, expr Expr) Expr {
+	if occur == occurMustNot {
+		return &NotExpr{Operand: expr}
+	}
+	return expr
+}
+
+func (p *luceneParser) parseGroup(parsePrimary func() (Expr, error)) (Expr, error) {
+	start := p.pos
+	p.pos++
+	p.depth++
+	expr, err := p.parseQuery(parsePrimary)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if p.peek() != ')' {
+		perr := p.fail(p.pos, "unclosed bracket", "')'")
+		perr.Msg += " opened at " + newParseError(p.input, start, "").position()
+		return nil, perr
+	}
+	p.pos++
+	p.depth--
+
+	return expr, nil
+}
+
+func (p *luceneParser) parseField() (Expr, error) {
+	p.skipSpaces()
+	if p.peek() == '(' {
+		return p.parseGroup(p.parseField)
+	}
+
+	start := p.pos
+	field, _, _ := p.readTerm(":")
+	if field == "" || p.peek() != ':' {
+		return nil, p.fail(start, "field is required", expectedLuceneClause...)
+	}
+	p.pos++
+
+	if field == luceneExistsField {
+		return p.parseExists()
+	}
+
+	return p.parseValue(field)
+}
+
+func (p *luceneParser) parseExists() (Expr, error) {
+	p.skipSpaces()
+	start := p.pos
+	field, _, _ := p.readTerm("")
+	if field == "" {
+		return nil, p.fail(start, "missing field of "+luceneExistsField, "field")
+	}
+
+	return &ExistsExpr{Path: field}, nil
+}
+
+func (p *luceneParser) parseValue(field string) (Expr, error) {
+	p.skipSpaces()
+	start := p.pos
+	// operator after space belongs to query, e.g. "status: AND x:1"
+	if start > 0 && isSpace(p.input[start-1]) &&
+		(p.isKeywordAt("and") || p.isKeywordAt("or") || p.isKeywordAt("not")) {
+		return nil, p.fail(start, "missing value of field '"+field+"'", "value")
+	}
+	switch p.peek() {
+	case '(':
+		return p.parseGroup(func() (Expr, error) {
+			return p.parseValue(field)
+		})
+	case '[', '{':
+		return p.parseRange(field)
+	case '"':
+		value, err := p.readQuoted('"')
+		if err != nil {
+			return nil, err
+		}
+		return &CompareExpr{Path: field, Operator: OpEq, Value: value}, nil
+	case '<', '>':
+		op := Operator(p.input[p.pos : p.pos+1])
+		p.pos++
+		if p.peek() == '=' {
+			op += "="
+			p.pos++
+		}
+
+		valueStart := p.pos
+		value, _, _ := p.readTerm("")
+		if value == "" {
+			return nil, p.fail(valueStart, "missing value of field '"+field+"'", "value")
+		}
+		return &CompareExpr{Path: field, Operator: op, Value: value}, nil
+	}
+
+	value, pattern, isWildcard := p.readTerm("")
+	switch {
+	case value == "":
+		return nil, p.fail(start, "missing value of field '"+field+"'", "value", "'\"'", "'['", "'{'", "'('")
+	case isWildcard && value == luceneWildcard:
+		return &ExistsExpr{Path: field}, nil
+	case isWildcard:
+		expr, err := newLikeExpr(FuncNone, field, OpLike, pattern)
+		if err != nil {
+			perr := p.fail(start, "invalid wildcard of field '"+field+"'")
+			perr.Err = err
+			return nil, perr
+		}
+		return expr, nil
+	default:
+		return &CompareExpr{Path: field, Operator: OpEq, Value: value}, nil
+	}
+}
+
+func (p *luceneParser) parseRange(field string) (Expr, error) {
+	start := p.pos
+	lowerOp := OpGte
+	if p.peek() == '{' {
+		lowerOp = OpGt
+	}
+	p.pos++
+
+	lower, err := p.readBound()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.acceptKeyword("to", "") {
+		return nil, p.fail(p.pos, "invalid range", "'TO'")
+	}
+
+	upper, err := p.readBound()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	upperOp := OpLte
+	switch p.peek() {
+	case ']':
+	case '}':
+		upperOp = OpLt
+	default:
+		perr := p.fail(p.pos, "unclosed range", "']'", "'}'")
+		perr.Msg += " opened at " + newParseError(p.input, start, "").position()
+		return nil, perr
+	}
+	p.pos++
+
+	var bounds []Expr
+	if lower != luceneWildcard {
+		bounds = append(bounds, &CompareExpr{Path: field, Operator: lowerOp, Value: lower})
+	}
+	if upper != luceneWildcard {
+		bounds = append(bounds, &CompareExpr{Path: field, Operator: upperOp, Value: upper})
+	}
+	if len(bounds) == 0 {
+		return &ExistsExpr{Path: field}, nil
+	}
+
+	return joinExprs(LogicalAnd, bounds), nil
+}
+
+// readBound reads bound of range, "*" means unbounded range
+func (p *luceneParser) readBound() (string, error) {
+	p.skipSpaces()
+	if p.peek() == '"' {
+		return p.readQuoted('"')
+	}
+
+	start := p.pos
+	value, _, _ := p.readTerm("]}")
+	if value == "" {
+		return "", p.fail(start, "missing bound of range", "value", "'*'")
+	}
+
+	return value, nil
+}
+
+// readTerm reads term up to space, bracket or one of stop chars. Backslash escapes next char.
+// Besides unescaped term it returns anchored regular expression built from wildcards (* and ?) if any present
+func (p *luceneParser) readTerm(stopChars string) (value string, pattern string, isWildcard bool) {
+	var res, re strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		if isSpace(c) || c == '(' || c == ')' || strings.IndexByte(stopChars, c) >= 0 {
+			break
+		}
+
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input):
+			_, size := utf8.DecodeRuneInString(p.input[p.pos+1:])
+			escaped := p.input[p.pos+1 : p.pos+1+size]
+			res.WriteString(escaped)
+			re.WriteString(regexp.QuoteMeta(escaped))
+			p.pos += 1 + size
+			continue
+		case c == '*':
+			isWildcard = true
+			re.WriteString(".*")
+		case c == '?':
+			isWildcard = true
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(p.input[p.pos : p.pos+1]))
+		}
+		res.WriteByte(c)
+		p.pos++
+	}
+
+	return res.String(), "^(?s:" + re.String() + ")$", isWildcard
+}
@@ -0,0 +1,252 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConditionFromLucene_SameAsParser(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		inputExpr string
+	}{
+		{
+			name:      "Field value",
+			query:     "status:500",
+			inputExpr: "status = 500",
+		},
+		{
+			name:      "Quoted phrase with escaped quote",
+			query:     `user.name:"John \"Bot\" Smith"`,
+			inputExpr: `user.name = 'John "Bot" Smith'`,
+		},
+		{
+			name:      "Kibana query",
+			query:     `status:500 AND path:/api/* AND NOT user.name:"bot"`,
+			inputExpr: `status = 500 and path ~ '^(?s:/api/.*)$' and not user.name = bot`,
+		},
+		{
+			name:      "Single char wildcard and escaped chars",
+			query:     `file:report-??.csv AND tag:a\*b\ c`,
+			inputExpr: `file ~ '^(?s:report-..\.csv)$' and tag = 'a*b c'`,
+		},
+		{
+			name:      "Default operator is or",
+			query:     "a:1 b:2 OR c:3",
+			inputExpr: "a = 1 or b = 2 or c = 3",
+		},
+		{
+			name:      "And has priority over or",
+			query:     "a:1 || b:2 && c:3",
+			inputExpr: "a = 1 or b = 2 and c = 3",
+		},
+		{
+			name:      "Required and prohibited clauses",
+			query:     "+a:1 b:2 -c:3",
+			inputExpr: "a = 1 and not c = 3",
+		},
+		{
+			name:      "Optional and prohibited clauses",
+			query:     "a:1 b:2 NOT c:3",
+			inputExpr: "(a = 1 or b = 2) and not c = 3",
+		},
+		{
+			name:      "Only prohibited clause",
+			query:     "-c:3",
+			inputExpr: "not c = 3",
+		},
+		{
+			name:      "Prohibited clause inside and",
+			query:     "a:1 AND -b:2 AND !c:3",
+			inputExpr: "a = 1 and not b = 2 and not c = 3",
+		},
+		{
+			name:      "Brackets",
+			query:     "(a:1 OR b:2) AND c:3",
+			inputExpr: "(a = 1 or b = 2) and c = 3",
+		},
+		{
+			name:      "Field group",
+			query:     "status:(500 OR 502 OR NOT 200)",
+			inputExpr: "(status = 500 or status = 502) and not status = 200",
+		},
+		{
+			name:      "Inclusive range",
+			query:     "age:[5 TO 10]",
+			inputExpr: "age >= 5 and age <= 10",
+		},
+		{
+			name:      "Exclusive and open range",
+			query:     `age:{5 TO *] AND date:{* TO "2020-01-01"}`,
+			inputExpr: "age > 5 and date < 2020-01-01",
+		},
+		{
+			name:      "Comparison",
+			query:     "status:>=500 AND took:<10",
+			inputExpr: "status >= 500 and took < 10",
+		},
+		{
+			name:      "Exists",
+			query:     "_exists_:error OR id:*",
+			inputExpr: "exists(error) or exists(id)",
+		},
+		{
+			name:      "Value with colon",
+			query:     "time:12:30",
+			inputExpr: "time = '12:30'",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			parsed, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			condition, err := filter.NewConditionFromLucene(testCase.query)
+			if assert.NoError(t, err) {
+				assert.Equal(t, parsed.Expr(), condition.Expr())
+			}
+		})
+	}
+}
+
+func TestNewConditionFromLucene_Evaluation(t *testing.T) {
+	elem := []byte(`{"id": 1, "status": 503, "path": "/api/users", "user": {"name": "bot"}, "tags": ["a", "b"]}`)
+
+	cases := []struct {
+		name         string
+		query        string
+		expectedIsOk bool
+	}{
+		{
+			name:         "Kibana query. Not ok",
+			query:        `status:[500 TO 599] AND path:/api/* AND NOT user.name:"bot"`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "Kibana query. Ok",
+			query:        `status:[500 TO 599] AND path:/api/* AND NOT user.name:"admin"`,
+			expectedIsOk: true,
+		},
+		{
+			name:         "Wildcard is anchored. Not ok",
+			query:        "path:/users*",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Wildcard of number. Not ok",
+			query:        "status:5*",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Exclusive range. Not ok",
+			query:        "status:{500 TO 503}",
+			expectedIsOk: false,
+		},
+		{
+			name:         "Array element. Ok",
+			query:        "tags:b",
+			expectedIsOk: true,
+		},
+		{
+			name:         "Absent field. Not ok",
+			query:        "_exists_:error",
+			expectedIsOk: false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromLucene(testCase.query)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, actualIsOk, err := filter.ProcessElem(*condition, elem)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedIsOk, actualIsOk)
+		})
+	}
+}
+
+func TestNewConditionFromLucene_Negative(t *testing.T) {
+	cases := []struct {
+		name           string
+		query          string
+		expectedOffset int
+		expectedMsg    string
+	}{
+		{
+			name:           "Empty query",
+			query:          " ",
+			expectedOffset: 1,
+			expectedMsg:    "empty query",
+		},
+		{
+			name:           "Term without field",
+			query:          "status:500 error",
+			expectedOffset: 11,
+			expectedMsg:    "field is required",
+		},
+		{
+			name:           "Missing value",
+			query:          "status: AND a:1",
+			expectedOffset: 8,
+			expectedMsg:    "missing value of field 'status'",
+		},
+		{
+			name:           "Missing clause after or",
+			query:          "a:1 OR",
+			expectedOffset: 6,
+			expectedMsg:    "missing clause after OR",
+		},
+		{
+			name:           "Unclosed bracket",
+			query:          "(a:1 OR b:2",
+			expectedOffset: 11,
+			expectedMsg:    "unclosed bracket opened at 1:1",
+		},
+		{
+			name:           "Range without to",
+			query:          "age:[1 10]",
+			expectedOffset: 7,
+			expectedMsg:    "invalid range",
+		},
+		{
+			name:           "Unclosed range",
+			query:          "age:[1 TO 10",
+			expectedOffset: 12,
+			expectedMsg:    "unclosed range opened at 1:5",
+		},
+		{
+			name:           "Unterminated phrase",
+			query:          `name:"John`,
+			expectedOffset: 5,
+			expectedMsg:    "unterminated quoted value",
+		},
+		{
+			name:           "Unexpected closing bracket",
+			query:          "a:1)",
+			expectedOffset: 3,
+			expectedMsg:    "unexpected input",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromLucene(testCase.query)
+			assert.Nil(t, condition)
+
+			perr, ok := err.(*filter.ParseError)
+			if assert.True(t, ok, "expected *ParseError, got %T", err) {
+				assert.Equal(t, testCase.expectedOffset, perr.Offset)
+				assert.Equal(t, testCase.expectedMsg, perr.Msg)
+			}
+		})
+	}
+}
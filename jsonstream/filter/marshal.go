@@ -0,0 +1,401 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidExpr appears when expression tree can't be represented as text or decoded from JSON
+var ErrInvalidExpr = errors.New("invalid expression tree")
+
+var bareValueRegexp = regexp.MustCompile(`^[\p{L}\p{N}_.@:/+\-]+$`)
+
+// Expression types used in JSON representation of condition
+const (
+	jsonTypeCompare  = "compare"
+	jsonTypeExists   = "exists"
+	jsonTypeAny      = "any"
+	jsonTypeJSONPath = "jsonpath"
+	jsonTypeTrue     = "true"
+	jsonTypeFalse    = "false"
+	jsonTypeNot      = "not"
+)
+
+// String returns canonical text representation of condition. Parsing of it gives the same condition,
+// values of typed comparisons (see CompareExpr) are written as json literals: json("5"), json(5)
+func (c Condition) String() string {
+	var res strings.Builder
+	writeExpr(&res, c.expr)
+	return res.String()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (c Condition) MarshalText() ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if c.expr == nil {
+		return []byte{}, nil
+	}
+
+	if err := validateExpr(c.expr); err != nil {
+		return nil, err
+	}
+
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (c *Condition) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*c = Condition{}
+		return nil
+	}
+
+	parsed, err := NewConditionFromStr(string(text))
+	if err != nil {
+		return err
+	}
+
+	*c = *parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. Condition is encoded as expression tree
+func (c Condition) MarshalJSON() ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if c.expr == nil {
+		return []byte("null"), nil
+	}
+
+	if err := validateExpr(c.expr); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(newExprJSON(c.expr))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *Condition) UnmarshalJSON(data []byte) error {
+	var node *exprJSON
+	if err := json.Unmarshal(data, &node); err != nil {
+		return errors.Wrap(err, "decode condition error")
+	}
+
+	if node == nil {
+		*c = Condition{}
+		return nil
+	}
+
+	expr, err := node.expr()
+	if err != nil {
+		return err
+	}
+
+	if err := validateExpr(expr); err != nil {
+		return err
+	}
+
+	*c = Condition{expr: expr}
+	return nil
+}
+
+func writeExpr(res *strings.Builder, expr Expr) {
+	switch e := expr.(type) {
+	case *CompareExpr:
+		if e.Func != FuncNone {
+			res.WriteString(e.Func.String() + "(" + e.Path + ")")
+		} else {
+			res.WriteString(e.Path)
+		}
+		res.WriteString(" " + e.Operator.String() + " ")
+		if e.Type != TypeAny {
+			res.WriteString(typedFunc + "(" + typedLiteral(e) + ")")
+		} else {
+			res.WriteString(quoteValue(e.Value))
+		}
+	case *ExistsExpr:
+		res.WriteString(existsFunc + "(" + e.Path + ")")
+	case *AnyExpr:
+		res.WriteString(anyFunc + "(" + e.Path + ", ")
+		writeExpr(res, e.Operand)
+		res.WriteString(")")
+	case *JSONPathExpr:
+		res.WriteString(jsonPathFunc + "(" + quoteString(e.Query) + ")")
+	case *ConstExpr:
+		res.WriteString(strconv.FormatBool(e.Value))
+	case *NotExpr:
+		res.WriteString("not ")
+		writeOperand(res, e.Operand, func(operand *LogicalExpr) bool { return true })
+	case *LogicalExpr:
+		for i, operand := range e.Operands {
+			if i > 0 {
+				res.WriteString(" " + e.Operator.String() + " ")
+			}
+			// same operator is bracketed to keep structure of tree, "and" has priority over "or"
+			writeOperand(res, operand, func(operand *LogicalExpr) bool {
+				return operand.Operator == e.Operator || e.Operator == LogicalAnd
+			})
+		}
+	}
+}
+
+func writeOperand(res *strings.Builder, operand Expr, needBrackets func(operand *LogicalExpr) bool) {
+	if logical, ok := operand.(*LogicalExpr); ok && needBrackets(logical) {
+		res.WriteString("(")
+		writeExpr(res, operand)
+		res.WriteString(")")
+		return
+	}
+
+	writeExpr(res, operand)
+}
+
+// quoteValue quotes value if it can't be read back as bare word
+func quoteValue(value string) string {
+	lower := strings.ToLower(value)
+	if bareValueRegexp.MatchString(value) && lower != "and" && lower != "or" {
+		return value
+	}
+
+	return quoteString(value)
+}
+
+// quoteString encloses value in quotes.
+// Backslash is escaped only if parser would treat it as escape: before quote, backslash or closing quote
+func quoteString(value string) string {
+	var res strings.Builder
+	res.WriteByte('\'')
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\'':
+			res.WriteString(`\'`)
+		case value[i] == '\\' && (i+1 == len(value) || value[i+1] == '\'' || value[i+1] == '\\'):
+			res.WriteString(`\\`)
+		default:
+			res.WriteByte(value[i])
+		}
+	}
+	res.WriteByte('\'')
+
+	return res.String()
+}
+
+// typedLiteral formats value of typed comparison as json literal, invalid values are written as is
+func typedLiteral(expr *CompareExpr) string {
+	switch expr.Type {
+	case TypeNumber:
+		if num, err := strconv.ParseFloat(expr.Value, 64); err == nil && !json.Valid([]byte(expr.Value)) {
+			return strconv.FormatFloat(num, 'g', -1, 64)
+		}
+		return expr.Value
+	case TypeBool:
+		if val, err := strconv.ParseBool(expr.Value); err == nil {
+			return strconv.FormatBool(val)
+		}
+		return expr.Value
+	case TypeNull:
+		return expr.Value
+	default:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(expr.Value); err != nil {
+			return quoteString(expr.Value)
+		}
+		return string(bytes.TrimSpace(buf.Bytes()))
+	}
+}
+
+// validateExpr checks that expression tree can be represented as text
+func validateExpr(expr Expr) error {
+	switch e := expr.(type) {
+	case nil:
+		return errors.Wrap(ErrInvalidExpr, "missing operand")
+	case *CompareExpr:
+		return validateCompare(e)
+	case *ExistsExpr:
+		return validatePath(e.Path, &ExistsExpr{Path: e.Path})
+	case *AnyExpr:
+		if err := validatePath(e.Path, &AnyExpr{Path: e.Path, Operand: &ConstExpr{Value: true}}); err != nil {
+			return err
+		}
+		return validateExpr(e.Operand)
+	case *JSONPathExpr:
+		if _, err := e.query(); err != nil {
+			return errors.Wrap(ErrInvalidExpr, err.Error())
+		}
+		return nil
+	case *ConstExpr:
+		return nil
+	case *NotExpr:
+		return validateExpr(e.Operand)
+	case *LogicalExpr:
+		return validateLogical(e)
+	default:
+		return errors.Wrapf(ErrInvalidExpr, "unknown expression %T", expr)
+	}
+}
+
+func validateCompare(expr *CompareExpr) error {
+	if newOperator(expr.Operator.String()) == OpUnknown {
+		return errors.Wrapf(ErrInvalidOperator, "found operator %s", expr.Operator.String())
+	}
+
+	if _, ok := valueFunctions[expr.Func.String()]; expr.Func != FuncNone && !ok {
+		return errors.Wrapf(ErrInvalidExpr, "unknown function '%s'", expr.Func.String())
+	}
+
+	if err := expr.validateType(); err != nil {
+		return errors.Wrap(ErrInvalidExpr, err.Error())
+	}
+
+	if isLikeOperator(expr.Operator) {
+		if _, err := expr.regexp(); err != nil {
+			return errors.Wrap(ErrInvalidExpr, err.Error())
+		}
+	}
+
+	return validatePath(expr.Path, &CompareExpr{Func: expr.Func, Path: expr.Path, Operator: OpEq, Value: "x"})
+}
+
+func validateLogical(expr *LogicalExpr) error {
+	if expr.Operator != LogicalAnd && expr.Operator != LogicalOr {
+		return errors.Wrapf(ErrInvalidExpr, "unknown logical operator '%s'", expr.Operator.String())
+	}
+	if len(expr.Operands) < 2 {
+		return errors.Wrapf(ErrInvalidExpr, "logical expression '%s' needs at least 2 operands", expr.Operator.String())
+	}
+
+	for _, operand := range expr.Operands {
+		if err := validateExpr(operand); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validatePath checks that path of probe expression is read back by parser as is
+func validatePath(path string, probe Expr) error {
+	var text strings.Builder
+	writeExpr(&text, probe)
+
+	parsed, err := newParser(text.String(), parseOptions{}).parse()
+	if err != nil || !reflect.DeepEqual(parsed, probe) {
+		return errors.Wrapf(ErrInvalidExpr, "path '%s' can't be represented as text", path)
+	}
+
+	return nil
+}
+
+// exprJSON is JSON representation of expression tree node
+type exprJSON struct {
+	Type      string      `json:"type"`
+	Func      string      `json:"func,omitempty"`
+	Path      string      `json:"path,omitempty"`
+	Operator  string      `json:"operator,omitempty"`
+	Value     *string     `json:"value,omitempty"`
+	ValueType string      `json:"value_type,omitempty"`
+	Query     string      `json:"query,omitempty"`
+	Operands  []*exprJSON `json:"operands,omitempty"`
+	Operand   *exprJSON   `json:"operand,omitempty"`
+}
+
+func newExprJSON(expr Expr) *exprJSON {
+	switch e := expr.(type) {
+	case *CompareExpr:
+		value := e.Value
+		return &exprJSON{
+			Type:      jsonTypeCompare,
+			Func:      e.Func.String(),
+			Path:      e.Path,
+			Operator:  e.Operator.String(),
+			Value:     &value,
+			ValueType: e.Type.String(),
+		}
+	case *ExistsExpr:
+		return &exprJSON{Type: jsonTypeExists, Path: e.Path}
+	case *AnyExpr:
+		return &exprJSON{Type: jsonTypeAny, Path: e.Path, Operand: newExprJSON(e.Operand)}
+	case *JSONPathExpr:
+		return &exprJSON{Type: jsonTypeJSONPath, Query: e.Query}
+	case *ConstExpr:
+		if e.Value {
+			return &exprJSON{Type: jsonTypeTrue}
+		}
+		return &exprJSON{Type: jsonTypeFalse}
+	case *NotExpr:
+		return &exprJSON{Type: jsonTypeNot, Operand: newExprJSON(e.Operand)}
+	case *LogicalExpr:
+		res := &exprJSON{Type: e.Operator.String(), Operands: make([]*exprJSON, 0, len(e.Operands))}
+		for _, operand := range e.Operands {
+			res.Operands = append(res.Operands, newExprJSON(operand))
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+func (n *exprJSON) expr() (Expr, error) {
+	if n == nil {
+		return nil, errors.Wrap(ErrInvalidExpr, "missing operand")
+	}
+
+	switch n.Type {
+	case jsonTypeCompare:
+		cmp := &CompareExpr{Func: Function(n.Func), Path: n.Path, Operator: Operator(n.Operator), Type: ValueType(n.ValueType)}
+		if n.Value != nil {
+			cmp.Value = *n.Value
+		}
+		if isLikeOperator(cmp.Operator) {
+			// invalid pattern is reported by validation of expression
+			cmp.re, _ = regexp.Compile(cmp.Value)
+		}
+		return cmp, nil
+	case jsonTypeExists:
+		return &ExistsExpr{Path: n.Path}, nil
+	case jsonTypeAny:
+		operand, err := n.Operand.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &AnyExpr{Path: n.Path, Operand: operand}, nil
+	case jsonTypeJSONPath:
+		// invalid query is reported by validation of expression
+		expr := &JSONPathExpr{Query: n.Query}
+		expr.compiled, _ = compileJSONPath(n.Query)
+		return expr, nil
+	case jsonTypeTrue, jsonTypeFalse:
+		return &ConstExpr{Value: n.Type == jsonTypeTrue}, nil
+	case jsonTypeNot:
+		operand, err := n.Operand.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Operand: operand}, nil
+	case LogicalAnd.String(), LogicalOr.String():
+		logical := &LogicalExpr{Operator: LogicalOperator(n.Type)}
+		for _, operandJSON := range n.Operands {
+			operand, err := operandJSON.expr()
+			if err != nil {
+				return nil, err
+			}
+			logical.Operands = append(logical.Operands, operand)
+		}
+		return logical, nil
+	default:
+		return nil, errors.Wrapf(ErrInvalidExpr, "unknown expression type '%s'", n.Type)
+	}
+}
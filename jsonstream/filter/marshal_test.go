@@ -0,0 +1,250 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondition_String(t *testing.T) {
+	cases := []struct {
+		name         string
+		inputExpr    string
+		expectedText string
+	}{
+		{
+			name:         "Simple comparison",
+			inputExpr:    "attr     =   value",
+			expectedText: "attr = value",
+		},
+		{
+			name:         "Value with spaces and quotes",
+			inputExpr:    `name = "O'Brien \\ Jr."`,
+			expectedText: `name = 'O\'Brien \ Jr.'`,
+		},
+		{
+			name:         "Empty value",
+			inputExpr:    "attr =",
+			expectedText: "attr = ''",
+		},
+		{
+			name:         "Keyword as value",
+			inputExpr:    "attr = 'AND'",
+			expectedText: "attr = 'AND'",
+		},
+		{
+			name:         "Backslashes",
+			inputExpr:    `attr = 'a\\\b\\'`,
+			expectedText: `attr = 'a\\\b\\'`,
+		},
+		{
+			name:         "Dollar value",
+			inputExpr:    "price = $5",
+			expectedText: "price = '$5'",
+		},
+		{
+			name:         "Logical operators",
+			inputExpr:    "a = 1 && (b = 2 || c = 3) && NOT exists(d) and LEN(e) > 2",
+			expectedText: "a = 1 and (b = 2 or c = 3) and not exists(d) and len(e) > 2",
+		},
+		{
+			name:         "Priority brackets are omitted",
+			inputExpr:    "(a = 1 and b = 2) or c = 3",
+			expectedText: "a = 1 and b = 2 or c = 3",
+		},
+		{
+			name:         "Nested same operator keeps brackets",
+			inputExpr:    "a = 1 or (b = 2 or c = 3)",
+			expectedText: "a = 1 or (b = 2 or c = 3)",
+		},
+		{
+			name:         "Not of compound",
+			inputExpr:    "not (a = 1 or b = 2)",
+			expectedText: "not (a = 1 or b = 2)",
+		},
+		{
+			name:         "Any and constants",
+			inputExpr:    "ANY( children , age > 5 and (name = Pit or true) ) or FALSE",
+			expectedText: "any(children, age > 5 and (name = Pit or true)) or false",
+		},
+		{
+			name:         "Regular expression",
+			inputExpr:    `email ~ "@gmail\.com$"`,
+			expectedText: `email ~ '@gmail\.com$'`,
+		},
+		{
+			name:         "JSONPath query",
+			inputExpr:    `JSONPATH( "$.children[?@.name == 'Pit']" ) and id = 3`,
+			expectedText: `jsonpath('$.children[?@.name == \'Pit\']') and id = 3`,
+		},
+		{
+			name:         "Typed values",
+			inputExpr:    `code = JSON( "5" ) and (lower(name) ~ json("o'brien \"jr\"") or age > json(1e3)) and parent = json(null)`,
+			expectedText: `code = json("5") and (lower(name) ~ json("o'brien \"jr\"") or age > json(1e3)) and parent = json(null)`,
+		},
+		{
+			name:         "Unicode multiword path",
+			inputExpr:    "attr1.Некий атрибут.attr3 = 'Некое значение'",
+			expectedText: "attr1.Некий атрибут.attr3 = 'Некое значение'",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, testCase.expectedText, condition.String())
+
+			reparsed, err := filter.NewConditionFromStr(condition.String())
+			if assert.NoError(t, err) {
+				assert.Equal(t, condition.Expr(), reparsed.Expr())
+			}
+		})
+	}
+}
+
+func TestCondition_JSONRoundTrip(t *testing.T) {
+	condition, err := filter.NewConditionFromStr("job.company = 'Some firm' and (len(emails) > 1 or not exists(error))")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err := json.Marshal(condition)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.JSONEq(t, `{
+		"type": "and",
+		"operands": [
+			{"type": "compare", "path": "job.company", "operator": "=", "value": "Some firm"},
+			{"type": "or", "operands": [
+				{"type": "compare", "func": "len", "path": "emails", "operator": ">", "value": "1"},
+				{"type": "not", "operand": {"type": "exists", "path": "error"}}
+			]}
+		]
+	}`, string(data))
+
+	var decoded filter.Condition
+	if assert.NoError(t, json.Unmarshal(data, &decoded)) {
+		assert.Equal(t, condition.Expr(), decoded.Expr())
+	}
+}
+
+func TestCondition_TextRoundTrip(t *testing.T) {
+	type rule struct {
+		Name      string           `json:"name"`
+		Condition filter.Condition `json:"condition"`
+	}
+
+	var decoded map[string]filter.Condition
+	err := json.Unmarshal([]byte(`{"slow": {"type": "compare", "path": "latency", "operator": ">", "value": "1000"}}`), &decoded)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	text, err := decoded["slow"].MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "latency > 1000", string(text))
+
+	var restored rule
+	if assert.NoError(t, restored.Condition.UnmarshalText(text)) {
+		assert.Equal(t, decoded["slow"].Expr(), restored.Condition.Expr())
+	}
+}
+
+func TestCondition_TypedJSONRoundTrip(t *testing.T) {
+	condition, err := filter.NewConditionFromMongo([]byte(`{"age": {"$gt": 5}, "parent": null}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err := json.Marshal(condition)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "and",
+		"operands": [
+			{"type": "compare", "path": "age", "operator": ">", "value": "5", "value_type": "number"},
+			{"type": "compare", "path": "parent", "operator": "=", "value": "null", "value_type": "null"}
+		]
+	}`, string(data))
+
+	var decoded filter.Condition
+	if assert.NoError(t, json.Unmarshal(data, &decoded)) {
+		assert.Equal(t, condition.Expr(), decoded.Expr())
+	}
+
+	// text writes values of typed comparisons as json literals
+	text, err := condition.MarshalText()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "age > json(5) and parent = json(null)", string(text))
+	}
+
+	var restored filter.Condition
+	if assert.NoError(t, restored.UnmarshalText(text)) {
+		assert.Equal(t, condition.Expr(), restored.Expr())
+	}
+}
+
+func TestCondition_UnmarshalJSON_Negative(t *testing.T) {
+	cases := []struct {
+		name          string
+		input         string
+		expectedCause error
+	}{
+		{
+			name:          "Unknown type",
+			input:         `{"type": "xor", "operands": []}`,
+			expectedCause: filter.ErrInvalidExpr,
+		},
+		{
+			name:          "Unknown operator",
+			input:         `{"type": "compare", "path": "a", "operator": "==", "value": "1"}`,
+			expectedCause: filter.ErrInvalidOperator,
+		},
+		{
+			name:          "Not representable path",
+			input:         `{"type": "compare", "path": "a = b", "operator": "=", "value": "1"}`,
+			expectedCause: filter.ErrInvalidExpr,
+		},
+		{
+			name:          "Single operand",
+			input:         `{"type": "or", "operands": [{"type": "exists", "path": "a"}]}`,
+			expectedCause: filter.ErrInvalidExpr,
+		},
+		{
+			name:          "Invalid JSONPath query",
+			input:         `{"type": "jsonpath", "query": "$.a["}`,
+			expectedCause: filter.ErrInvalidExpr,
+		},
+		{
+			name:          "Number type of not number",
+			input:         `{"type": "compare", "path": "a", "operator": "=", "value": "x", "value_type": "number"}`,
+			expectedCause: filter.ErrInvalidExpr,
+		},
+		{
+			name:          "Null with ordering operator",
+			input:         `{"type": "compare", "path": "a", "operator": ">", "value": "null", "value_type": "null"}`,
+			expectedCause: filter.ErrInvalidExpr,
+		},
+		{
+			name:          "Missing operand of not",
+			input:         `{"type": "not"}`,
+			expectedCause: filter.ErrInvalidExpr,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var decoded filter.Condition
+			err := json.Unmarshal([]byte(testCase.input), &decoded)
+			assert.Equal(t, testCase.expectedCause, errors.Cause(err))
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Record is a record of stream satisfying program
+type Record struct {
+	// Raw is a content of record without delimiter
+	Raw []byte
+	// Line is a number of record in stream starting from 1
+	Line int64
+	// Offset is a position of the first byte of record in stream
+	Offset int64
+
+	decoded *decodedRecord
+}
+
+// decodedRecord is a value of record decoded on the first request, it's shared by copies of record
+type decodedRecord struct {
+	once sync.Once
+	val  interface{}
+	err  error
+}
+
+func newRecord(raw []byte, line, offset int64) Record {
+	return Record{
+		Raw:     append([]byte(nil), raw...),
+		Line:    line,
+		Offset:  offset,
+		decoded: &decodedRecord{},
+	}
+}
+
+// Value decodes record the same way as encoding/json does, record is decoded once
+func (r Record) Value() (interface{}, error) {
+	if r.decoded == nil {
+		return decodeRecord(r.Raw)
+	}
+
+	r.decoded.once.Do(func() {
+		r.decoded.val, r.decoded.err = decodeRecord(r.Raw)
+	})
+	return r.decoded.val, r.decoded.err
+}
+
+func decodeRecord(raw []byte) (interface{}, error) {
+	var val interface{}
+	if err := json.Unmarshal(raw, &val); err != nil {
+		return nil, errors.Wrap(err, "parse json error")
+	}
+	return val, nil
+}
+
+// Records returns iterator over all records of reader, it's a framing of stream used by Stream and Matches.
+// Raw content of record is valid until the next record is requested. Iteration is over after the first error,
+// too large records are skipped by OversizeSkip policy, malformed ones by ErrorSkip policy.
+// Cancellation of context is checked between records
+func Records(ctx context.Context, r io.Reader, opts ...StreamOption) iter.Seq2[Record, error] {
+	config := newStreamConfig(opts)
+	return func(yield func(Record, error) bool) {
+		if err := config.validate(); err != nil {
+			yield(Record{}, err)
+			return
+		}
+		config.records(ctx, r, yield)
+	}
+}
+
+func (c *streamConfig) records(ctx context.Context, r io.Reader, yield func(Record, error) bool) {
+	reader := newRecordSource(r, streamLineSize, c)
+	for {
+		if err := ctx.Err(); err != nil {
+			yield(Record{}, err)
+			return
+		}
+
+		raw, err := reader.next()
+		if err != nil && err == io.EOF {
+			return
+		}
+		num, start := reader.position()
+		if err != nil && isRecordError(err) {
+			if errors.Cause(err) == ErrRecordTooLarge && c.oversizePolicy == OversizeSkip {
+				continue
+			}
+			if errors.Cause(err) == ErrMalformedValue && c.errorPolicy == ErrorSkip {
+				continue
+			}
+			err = errors.Wrapf(err, "read record %d error", num)
+		}
+
+		record := Record{Raw: raw, Line: num, Offset: start, decoded: &decodedRecord{}}
+		if !yield(record, err) || err != nil {
+			return
+		}
+	}
+}
+
+// Matches returns iterator over records of reader satisfying program. Records are read on demand,
+// so stopping of iteration stops reading. Iteration is over after the first error unless errors are skipped
+// (see WithErrorPolicy), options of workers, annotations and explanations aren't supported.
+// Cancellation of context is checked between records
+func Matches(ctx context.Context, r io.Reader, prog *Program, opts ...StreamOption) iter.Seq2[Record, error] {
+	config := newStreamConfig(opts)
+	return func(yield func(Record, error) bool) {
+		if err := config.validateMatches(); err != nil {
+			yield(Record{}, err)
+			return
+		}
+
+		config.records(ctx, r, func(record Record, err error) bool {
+			if err != nil {
+				yield(newRecord(record.Raw, record.Line, record.Offset), err)
+				return false
+			}
+
+			isOk, err := prog.Match(record.Raw)
+			if err != nil {
+				if config.errorPolicy == ErrorSkip {
+					return true
+				}
+				yield(newRecord(record.Raw, record.Line, record.Offset), errors.Wrapf(err, "process record %d error", record.Line))
+				return false
+			}
+
+			return !isOk || yield(newRecord(record.Raw, record.Line, record.Offset), nil)
+		})
+	}
+}
+
+// MatchesChan sends records of reader satisfying program (see Matches) to channel which is closed
+// when stream is over. Error stopping stream is sent to error channel. Consumer which stops receiving
+// before the end of stream must cancel context, so goroutine reading stream is stopped without sending
+// the rest of records. Read blocked by reader isn't interrupted
+func MatchesChan(ctx context.Context, r io.Reader, prog *Program, opts ...StreamOption) (<-chan Record, <-chan error) {
+	records := make(chan Record)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(records)
+
+		for record, err := range Matches(ctx, r, prog, opts...) {
+			if err != nil {
+				sendErr(ctx, errs, err)
+				return
+			}
+
+			select {
+			case records <- record:
+			case <-ctx.Done():
+				sendErr(ctx, errs, ctx.Err())
+				return
+			}
+		}
+	}()
+
+	return records, errs
+}
+
+// sendErr sends error to channel unless channel is full and context is cancelled
+func sendErr(ctx context.Context, errs chan<- error, err error) {
+	select {
+	case errs <- err:
+		return
+	default:
+	}
+
+	select {
+	case errs <- err:
+	case <-ctx.Done():
+	}
+}
+
+func (c *streamConfig) validateMatches() error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	if c.workers > 1 || c.unordered || c.annotateField != "" || c.explain != "" {
+		return errors.New("workers, annotations and explanations aren't supported by iteration over records")
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package filter_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMatch struct {
+	raw    string
+	line   int64
+	offset int64
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []filter.StreamOption
+		expected []testMatch
+		err      string
+	}{
+		{
+			name:  "lines",
+			input: "{\"name\": \"John\"}\r\n{\"name\": \"Alex\"}\n{\"name\": \"John\", \"id\": 2}",
+			expected: []testMatch{
+				{raw: "{\"name\": \"John\"}", line: 1, offset: 0},
+				{raw: "{\"name\": \"John\", \"id\": 2}", line: 3, offset: 35},
+			},
+		},
+		{
+			name:     "error stops iteration",
+			input:    "{\"name\": \"John\"}\n{\"name\":\n{\"name\": \"John\"}\n",
+			expected: []testMatch{{raw: "{\"name\": \"John\"}", line: 1}, {raw: "{\"name\":", line: 2, offset: 17}},
+			err:      "process record 2 error: parse json error: unexpected end of JSON input",
+		},
+		{
+			name:  "errors are skipped",
+			input: "{\"name\":\n" + strings.Repeat("x", 100) + "\n{\"name\": \"John\"}\n",
+			opts: []filter.StreamOption{
+				filter.WithErrorPolicy(filter.ErrorSkip), filter.WithMaxRecordSize(50), filter.WithOversizePolicy(filter.OversizeSkip),
+			},
+			expected: []testMatch{
+				{raw: "{\"name\": \"John\"}", line: 3, offset: 110},
+			},
+		},
+		{
+			name:  "delimiter",
+			input: "{\"name\": \"Alex\"}\x00{\"name\":\n\"John\"}",
+			opts:  []filter.StreamOption{filter.WithDelimiter(0)},
+			expected: []testMatch{
+				{raw: "{\"name\":\n\"John\"}", line: 2, offset: 17},
+			},
+		},
+		{
+			name:     "too large record",
+			input:    "{\"name\": \"John\"}\n" + strings.Repeat("x", 100) + "\n{\"name\": \"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithErrorPolicy(filter.ErrorSkip), filter.WithMaxRecordSize(50)},
+			expected: []testMatch{{raw: "{\"name\": \"John\"}", line: 1}, {line: 2, offset: 17}},
+			err:      "read record 2 error: maximum size is 50 bytes: record is too large",
+		},
+		{
+			name:     "unsupported option",
+			input:    "{\"name\": \"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithWorkers(2)},
+			expected: []testMatch{{}},
+			err:      "workers, annotations and explanations aren't supported by iteration over records",
+		},
+	}
+
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var matches []testMatch
+			var lastErr error
+			for record, err := range filter.Matches(context.Background(), strings.NewReader(tt.input), program, tt.opts...) {
+				matches = append(matches, testMatch{raw: string(record.Raw), line: record.Line, offset: record.Offset})
+				lastErr = err
+			}
+			assert.Equal(t, tt.expected, matches)
+			if tt.err != "" {
+				assert.EqualError(t, lastErr, tt.err)
+			} else {
+				assert.NoError(t, lastErr)
+			}
+		})
+	}
+}
+
+func TestRecords(t *testing.T) {
+	input := "{\"id\": 1}\r\n" + strings.Repeat("x", 5000) + "\n\n" + strings.Repeat("y", 10000) + "\n{\"id\": 2}"
+	var matches []testMatch
+	for record, err := range filter.Records(context.Background(), strings.NewReader(input),
+		filter.WithMaxRecordSize(8000), filter.WithOversizePolicy(filter.OversizeSkip)) {
+		assert.NoError(t, err)
+		matches = append(matches, testMatch{raw: string(record.Raw), line: record.Line, offset: record.Offset})
+	}
+	assert.Equal(t, []testMatch{
+		{raw: "{\"id\": 1}", line: 1},
+		{raw: strings.Repeat("x", 5000), line: 2, offset: 11},
+		{raw: "", line: 3, offset: 5012},
+		{raw: "{\"id\": 2}", line: 5, offset: 15014},
+	}, matches)
+
+	for _, err := range filter.Records(context.Background(), strings.NewReader(input), filter.WithOversizePolicy("x")) {
+		assert.EqualError(t, err, "unknown oversize policy 'x'")
+	}
+}
+
+func TestMatches_Stop(t *testing.T) {
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		_, _ = w.Write([]byte("{\"name\": \"John\", \"id\": 1}\n"))
+		_, _ = w.Write([]byte("{\"name\": \"John\", \"id\": 2}\n"))
+	}()
+
+	for record, err := range filter.Matches(context.Background(), r, program) {
+		assert.NoError(t, err)
+		val, err := record.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"name": "John", "id": 1.0}, val)
+		break
+	}
+
+	// the second record is left in pipe
+	buf := make([]byte, 100)
+	n, err := r.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"name\": \"John\", \"id\": 2}\n", string(buf[:n]))
+}
+
+func TestMatchesChan(t *testing.T) {
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	records, errs := filter.MatchesChan(context.Background(),
+		strings.NewReader("{\"name\": \"John\"}\n{\"name\": \"Alex\"}\n{\"name\"\n"), program)
+	var raws []string
+	for record := range records {
+		raws = append(raws, string(record.Raw))
+	}
+	assert.Equal(t, []string{"{\"name\": \"John\"}"}, raws)
+	assert.EqualError(t, <-errs, "process record 3 error: parse json error: unexpected end of JSON input")
+
+	// consumer stops receiving by cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	records, errs = filter.MatchesChan(ctx, strings.NewReader(strings.Repeat("{\"name\": \"John\"}\n", 100)), program)
+	<-records
+	cancel()
+	for range records {
+	}
+	assert.Equal(t, context.Canceled, <-errs)
+}
+
+func TestMatchesChan_StoppedConsumer(t *testing.T) {
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// consumer takes one record and cancels context without draining of channel
+	ctx, cancel := context.WithCancel(context.Background())
+	records, errs := filter.MatchesChan(ctx, strings.NewReader(strings.Repeat("{\"name\": \"John\"}\n", 100)), program)
+	<-records
+	cancel()
+
+	select {
+	case err := <-errs:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("goroutine of channel is blocked by sending")
+	}
+	_, ok := <-errs
+	assert.False(t, ok)
+	_, ok = <-records
+	assert.False(t, ok)
+}
@@ -0,0 +1,356 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidQuery appears when query document can't be converted to condition
+var ErrInvalidQuery = errors.New("invalid query document")
+
+// mongoObject is JSON object keeping order of its fields
+type mongoObject []mongoField
+
+type mongoField struct {
+	key   string
+	value interface{}
+}
+
+// NewConditionFromMongo builds condition from MongoDB-style query document, e.g.
+// {"age": {"$gt": 5}, "status": {"$in": ["a", "b"]}, "$or": [...]}.
+// Supported operators: $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists, $regex (with $options),
+// $and, $or, $not, $elemMatch
+func NewConditionFromMongo(doc []byte) (*Condition, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+
+	decoded, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode query document error")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.Wrap(ErrInvalidQuery, "unexpected data after query document")
+	}
+
+	obj, ok := decoded.(mongoObject)
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidQuery, "query document must be an object, passed %s", jsonTypeName(decoded))
+	}
+
+	expr, err := mongoDocExpr(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Condition{expr: expr}, nil
+}
+
+// decodeOrdered decodes JSON value, objects are decoded to mongoObject to keep order of fields
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	if delim == '[' {
+		res := []interface{}{}
+		for dec.More() {
+			elem, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, elem)
+		}
+		_, err = dec.Token()
+		return res, err
+	}
+
+	res := mongoObject{}
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := decodeOrdered(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		key, _ := keyTok.(string)
+		res = append(res, mongoField{key: key, value: value})
+	}
+	_, err = dec.Token()
+	return res, err
+}
+
+// mongoDocExpr converts query document: fields conditions and logical operators joined by "and"
+func mongoDocExpr(obj mongoObject) (Expr, error) {
+	operands := make([]Expr, 0, len(obj))
+	for _, field := range obj {
+		var operand Expr
+		var err error
+		switch field.key {
+		case "$and":
+			operand, err = mongoLogicalExpr(LogicalAnd, field)
+		case "$or":
+			operand, err = mongoLogicalExpr(LogicalOr, field)
+		default:
+			if strings.HasPrefix(field.key, "$") {
+				return nil, errors.Wrapf(ErrInvalidQuery, "unsupported top level operator '%s'", field.key)
+			}
+			operand, err = mongoFieldExpr(field.key, field.value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	return joinExprs(LogicalAnd, operands), nil
+}
+
+func mongoLogicalExpr(op LogicalOperator, field mongoField) (Expr, error) {
+	docs, ok := field.value.([]interface{})
+	if !ok || len(docs) == 0 {
+		return nil, errors.Wrapf(ErrInvalidQuery, "%s must be a non-empty array of documents", field.key)
+	}
+
+	operands := make([]Expr, 0, len(docs))
+	for i, doc := range docs {
+		obj, ok := doc.(mongoObject)
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidQuery, "%s[%d] must be a document, passed %s", field.key, i, jsonTypeName(doc))
+		}
+
+		operand, err := mongoDocExpr(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s[%d]", field.key, i)
+		}
+		operands = append(operands, operand)
+	}
+
+	return joinExprs(op, operands), nil
+}
+
+// mongoFieldExpr converts condition of field: value to compare with or document of operators
+func mongoFieldExpr(path string, value interface{}) (Expr, error) {
+	obj, isObject := value.(mongoObject)
+	if !isObject || len(obj) == 0 || !strings.HasPrefix(obj[0].key, "$") {
+		cond, err := mongoCompare(path, OpEq, value)
+		return cond, errors.Wrapf(err, "field '%s'", path)
+	}
+
+	operands := make([]Expr, 0, len(obj))
+	for _, field := range obj {
+		operand, err := mongoOperatorExpr(path, field, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field '%s'", path)
+		}
+		if operand != nil {
+			operands = append(operands, operand)
+		}
+	}
+
+	return joinExprs(LogicalAnd, operands), nil
+}
+
+var mongoComparisons = map[string]Operator{
+	"$eq":  OpEq,
+	"$gt":  OpGt,
+	"$gte": OpGte,
+	"$lt":  OpLt,
+	"$lte": OpLte,
+}
+
+// mongoOperatorExpr converts one operator of field, siblings are used to find $options of $regex
+func mongoOperatorExpr(path string, field mongoField, siblings mongoObject) (Expr, error) {
+	if op, ok := mongoComparisons[field.key]; ok {
+		return mongoCompare(path, op, field.value)
+	}
+
+	switch field.key {
+	case "$ne":
+		eq, err := mongoCompare(path, OpEq, field.value)
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Operand: eq}, nil
+	case "$in":
+		return mongoIn(path, field.value)
+	case "$nin":
+		in, err := mongoIn(path, field.value)
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Operand: in}, nil
+	case "$exists":
+		return mongoExists(path, field.value)
+	case "$regex":
+		return mongoRegex(path, field.value, siblings)
+	case "$options":
+		// is handled with $regex
+		return nil, nil
+	case "$not":
+		if _, ok := field.value.(mongoObject); !ok {
+			return nil, errors.Wrapf(ErrInvalidQuery, "$not must be a document of operators, passed %s", jsonTypeName(field.value))
+		}
+		operand, err := mongoFieldExpr(path, field.value)
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Operand: operand}, nil
+	case "$elemMatch":
+		return mongoElemMatch(path, field.value)
+	default:
+		return nil, errors.Wrapf(ErrInvalidQuery, "unsupported operator '%s'", field.key)
+	}
+}
+
+// mongoCompare builds typed comparison: values of other types don't match, null matches missing value too
+func mongoCompare(path string, op Operator, value interface{}) (Expr, error) {
+	var valueType ValueType
+	switch value.(type) {
+	case string:
+		valueType = TypeString
+	case json.Number:
+		valueType = TypeNumber
+	case bool:
+		valueType = TypeBool
+	case nil:
+		if op != OpEq {
+			return nil, errors.Wrapf(ErrInvalidQuery, "null is supported only by $eq, $ne, $in and $nin, passed %s", op.String())
+		}
+		return &CompareExpr{Path: path, Operator: OpEq, Value: "null", Type: TypeNull}, nil
+	default:
+		return nil, errors.Wrapf(ErrInvalidQuery, "comparison with %s isn't supported", jsonTypeName(value))
+	}
+
+	cond := Path(path).compare(op, value)
+	if cond.err != nil {
+		return nil, cond.err
+	}
+
+	expr := cond.expr.(*CompareExpr)
+	expr.Type = valueType
+	return expr, nil
+}
+
+func mongoIn(path string, value interface{}) (Expr, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidQuery, "$in and $nin must be an array, passed %s", jsonTypeName(value))
+	}
+
+	operands := make([]Expr, 0, len(values))
+	for _, val := range values {
+		operand, err := mongoCompare(path, OpEq, val)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	if len(operands) == 0 {
+		return &ConstExpr{Value: false}, nil
+	}
+
+	return joinExprs(LogicalOr, operands), nil
+}
+
+func mongoExists(path string, value interface{}) (Expr, error) {
+	var exists bool
+	switch v := value.(type) {
+	case bool:
+		exists = v
+	case json.Number:
+		exists = v.String() != "0"
+	default:
+		return nil, errors.Wrapf(ErrInvalidQuery, "$exists must be a boolean, passed %s", jsonTypeName(value))
+	}
+
+	if exists {
+		return &ExistsExpr{Path: path}, nil
+	}
+
+	return &NotExpr{Operand: &ExistsExpr{Path: path}}, nil
+}
+
+func mongoRegex(path string, value interface{}, siblings mongoObject) (Expr, error) {
+	pattern, ok := value.(string)
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidQuery, "$regex must be a string, passed %s", jsonTypeName(value))
+	}
+
+	for _, field := range siblings {
+		if field.key != "$options" {
+			continue
+		}
+
+		options, ok := field.value.(string)
+		if !ok || strings.Trim(options, "ims") != "" {
+			return nil, errors.Wrapf(ErrInvalidQuery, "unsupported $options '%v', allowed flags are i, m, s", field.value)
+		}
+		if options != "" {
+			pattern = "(?" + options + ")" + pattern
+		}
+	}
+
+	cond := Path(path).Match(pattern)
+	if cond.err != nil {
+		return nil, cond.err
+	}
+
+	return cond.expr, nil
+}
+
+// mongoElemMatch converts $elemMatch with document (conditions of element fields) or operators (conditions of element itself)
+func mongoElemMatch(path string, value interface{}) (Expr, error) {
+	obj, ok := value.(mongoObject)
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidQuery, "$elemMatch must be a document, passed %s", jsonTypeName(value))
+	}
+
+	var operand Expr
+	var err error
+	if len(obj) > 0 && strings.HasPrefix(obj[0].key, "$") && obj[0].key != "$and" && obj[0].key != "$or" {
+		operand, err = mongoFieldExpr(selfPath, obj)
+	} else {
+		operand, err = mongoDocExpr(obj)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "$elemMatch")
+	}
+
+	return &AnyExpr{Path: path, Operand: operand}, nil
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case mongoObject:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,259 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConditionFromMongo_SameAsParser(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		inputExpr string
+	}{
+		{
+			name:      "Implicit equality",
+			query:     `{"job.company": "Some firm"}`,
+			inputExpr: `job.company = json("Some firm")`,
+		},
+		{
+			name:      "Fields are joined by and in order",
+			query:     `{"b": 1, "a": true, "c": {"$gt": 5, "$lte": 10.5}}`,
+			inputExpr: "b = json(1) and a = json(true) and (c > json(5) and c <= json(10.5))",
+		},
+		{
+			name:      "In and not in",
+			query:     `{"status": {"$in": ["a", "b"]}, "code": {"$nin": [500, 502]}}`,
+			inputExpr: `(status = json("a") or status = json("b")) and not (code = json(500) or code = json(502))`,
+		},
+		{
+			name:      "Not equal and exists",
+			query:     `{"name": {"$ne": "Ann"}, "error": {"$exists": false}, "id": {"$exists": true}}`,
+			inputExpr: `not name = json("Ann") and not exists(error) and exists(id)`,
+		},
+		{
+			name:      "Logical operators",
+			query:     `{"$or": [{"age": {"$lt": 5}}, {"$and": [{"a": "x"}, {"b": "y"}]}], "c": {"$not": {"$eq": 1}}}`,
+			inputExpr: `(age < json(5) or a = json("x") and b = json("y")) and not c = json(1)`,
+		},
+		{
+			name:      "Regex with options",
+			query:     `{"email": {"$regex": "@gmail\\.com$", "$options": "i"}}`,
+			inputExpr: `email ~ '(?i)@gmail\.com$'`,
+		},
+		{
+			name:      "Elem match of documents",
+			query:     `{"children": {"$elemMatch": {"age": {"$gt": 5}, "name": "Pit"}}}`,
+			inputExpr: `any(children, age > json(5) and name = json("Pit"))`,
+		},
+		{
+			name:      "Elem match of values",
+			query:     `{"scores": {"$elemMatch": {"$gte": 80, "$lt": 85}}}`,
+			inputExpr: "any(scores, @ >= json(80) and @ < json(85))",
+		},
+		{
+			name:      "Empty document",
+			query:     `{}`,
+			inputExpr: "true",
+		},
+		{
+			name:      "Empty in",
+			query:     `{"a": {"$in": []}}`,
+			inputExpr: "false",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			parsed, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			// comparisons of query document are typed, their values are json literals
+			condition, err := filter.NewConditionFromMongo([]byte(testCase.query))
+			if assert.NoError(t, err) {
+				assert.Equal(t, parsed.Expr(), condition.Expr())
+				assert.Equal(t, parsed.String(), condition.String())
+			}
+		})
+	}
+}
+
+func TestNewConditionFromMongo_Evaluation(t *testing.T) {
+	elem := []byte(`{"id": 1, "emails": ["john@Gmail.com"], "children": [{"name": "Alex", "age": 10}, {"name": "Pit", "age": 5}],` +
+		` "scores": [70, 82], "code": "5", "parent": null}`)
+
+	cases := []struct {
+		name         string
+		query        string
+		expectedIsOk bool
+	}{
+		{
+			name:         "Elem match. Not ok",
+			query:        `{"children": {"$elemMatch": {"age": {"$gt": 5}, "name": "Pit"}}}`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "Elem match. Ok",
+			query:        `{"children": {"$elemMatch": {"age": {"$lte": 5}, "name": "Pit"}}}`,
+			expectedIsOk: true,
+		},
+		{
+			name:         "Elem match of values. Ok",
+			query:        `{"scores": {"$elemMatch": {"$gte": 80, "$lt": 85}}}`,
+			expectedIsOk: true,
+		},
+		{
+			name:         "Elem match of not array. Not ok",
+			query:        `{"id": {"$elemMatch": {"$gte": 0}}}`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "Regex. Ok",
+			query:        `{"emails": {"$regex": "@gmail\\.com$", "$options": "i"}}`,
+			expectedIsOk: true,
+		},
+		{
+			name:         "Regex of number. Not ok",
+			query:        `{"id": {"$regex": "1"}}`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "Not in of array. Not ok",
+			query:        `{"children.name": {"$nin": ["Pit", "Jane"]}}`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "Not equal of absent field. Ok",
+			query:        `{"absent": {"$ne": 1}}`,
+			expectedIsOk: true,
+		},
+		{
+			name:         "Number is compared as number. Ok",
+			query:        `{"id": 1.0, "scores": {"$gt": 80}}`,
+			expectedIsOk: true,
+		},
+		{
+			name:         "Number doesn't match string. Not ok",
+			query:        `{"code": 5}`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "String doesn't match number. Not ok",
+			query:        `{"id": {"$gte": "1"}}`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "Boolean doesn't match number. Not ok",
+			query:        `{"id": true}`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "Null matches null. Ok",
+			query:        `{"parent": null}`,
+			expectedIsOk: true,
+		},
+		{
+			name:         "Null matches absent field. Ok",
+			query:        `{"absent": null}`,
+			expectedIsOk: true,
+		},
+		{
+			name:         "Null doesn't match value. Not ok",
+			query:        `{"id": {"$in": [null, "1"]}}`,
+			expectedIsOk: false,
+		},
+		{
+			name:         "Not equal to null of value. Ok",
+			query:        `{"id": {"$ne": null}, "parent": {"$exists": true}}`,
+			expectedIsOk: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromMongo([]byte(testCase.query))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, actualIsOk, err := filter.ProcessElem(*condition, elem)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedIsOk, actualIsOk)
+
+			// text form keeps types of comparisons, so parsed text gives the same result
+			reparsed, err := filter.NewConditionFromStr(condition.String())
+			if assert.NoError(t, err) {
+				_, reparsedIsOk, err := filter.ProcessElem(*reparsed, elem)
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedIsOk, reparsedIsOk, condition.String())
+			}
+		})
+	}
+}
+
+func TestNewConditionFromMongo_Negative(t *testing.T) {
+	cases := []struct {
+		name          string
+		query         string
+		expectedCause error
+	}{
+		{
+			name:          "Not an object",
+			query:         `[{"a": 1}]`,
+			expectedCause: filter.ErrInvalidQuery,
+		},
+		{
+			name:          "Unknown operator",
+			query:         `{"a": {"$size": 1}}`,
+			expectedCause: filter.ErrInvalidQuery,
+		},
+		{
+			name:          "Unknown top level operator",
+			query:         `{"$nor": [{"a": 1}]}`,
+			expectedCause: filter.ErrInvalidQuery,
+		},
+		{
+			name:          "Null with ordering operator",
+			query:         `{"a": {"$gt": null}}`,
+			expectedCause: filter.ErrInvalidQuery,
+		},
+		{
+			name:          "Embedded document equality",
+			query:         `{"a": {"b": 1}}`,
+			expectedCause: filter.ErrInvalidQuery,
+		},
+		{
+			name:          "Boolean with ordering operator",
+			query:         `{"a": {"$gt": true}}`,
+			expectedCause: filter.ErrUnsupportedOperator,
+		},
+		{
+			name:          "Empty or",
+			query:         `{"$or": []}`,
+			expectedCause: filter.ErrInvalidQuery,
+		},
+		{
+			name:          "Unsupported regex options",
+			query:         `{"a": {"$regex": "x", "$options": "x"}}`,
+			expectedCause: filter.ErrInvalidQuery,
+		},
+		{
+			name:          "Trailing data",
+			query:         `{"a": 1} {"b": 2}`,
+			expectedCause: filter.ErrInvalidQuery,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			condition, err := filter.NewConditionFromMongo([]byte(testCase.query))
+			assert.Nil(t, condition)
+			assert.Equal(t, testCase.expectedCause, errors.Cause(err))
+		})
+	}
+}
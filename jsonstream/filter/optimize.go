@@ -0,0 +1,363 @@
+package filter
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Warning describes sub-expression of condition which is likely a mistake
+type Warning struct {
+	Expr    string `json:"expr"`
+	Message string `json:"message"`
+}
+
+// String formats warning as "expression: message"
+func (w Warning) String() string {
+	return w.Expr + ": " + w.Message
+}
+
+// Optimize simplifies condition to speed up its evaluation: folds constants, removes double negations,
+// duplicated operands and "exists" checks implied by other operands, and orders operands of "and" and "or"
+// from cheap to expensive ones. Warnings report sub-expressions which can never be satisfied.
+// Optimized condition gives the same results, but errors of operands which are not evaluated anymore are lost
+func Optimize(cond Condition) (Condition, []Warning) {
+	if cond.err != nil || cond.expr == nil {
+		return cond, nil
+	}
+
+	var warnings []Warning
+	expr := optimizeExpr(cond.expr, &warnings)
+	if c, ok := expr.(*ConstExpr); ok && !c.Value {
+		warnings = append(warnings, Warning{Expr: exprString(cond.expr), Message: "condition can never be satisfied"})
+	}
+
+	return Condition{expr: expr}, warnings
+}
+
+func optimizeExpr(expr Expr, warnings *[]Warning) Expr {
+	switch e := expr.(type) {
+	case *AnyExpr:
+		operand := optimizeExpr(e.Operand, warnings)
+		if c, ok := operand.(*ConstExpr); ok && !c.Value {
+			return operand
+		}
+		return &AnyExpr{Path: e.Path, Operand: operand}
+	case *NotExpr:
+		switch operand := optimizeExpr(e.Operand, warnings).(type) {
+		case *NotExpr:
+			return operand.Operand
+		case *ConstExpr:
+			return &ConstExpr{Value: !operand.Value}
+		default:
+			return &NotExpr{Operand: operand}
+		}
+	case *LogicalExpr:
+		return optimizeLogical(e, warnings)
+	default:
+		return expr
+	}
+}
+
+func optimizeLogical(e *LogicalExpr, warnings *[]Warning) Expr {
+	isAnd := e.Operator == LogicalAnd
+
+	var operands []Expr
+	seen := map[string]bool{}
+	var add func(operand Expr) bool
+	add = func(operand Expr) bool {
+		switch o := operand.(type) {
+		case *ConstExpr:
+			// neutral constant is dropped, absorbing one decides result
+			return o.Value == isAnd
+		case *LogicalExpr:
+			if o.Operator == e.Operator {
+				for _, nested := range o.Operands {
+					if !add(nested) {
+						return false
+					}
+				}
+				return true
+			}
+		}
+
+		if key := exprString(operand); !seen[key] {
+			seen[key] = true
+			operands = append(operands, operand)
+		}
+		return true
+	}
+
+	for _, operand := range e.Operands {
+		if !add(optimizeExpr(operand, warnings)) {
+			return &ConstExpr{Value: !isAnd}
+		}
+	}
+
+	if isAnd {
+		operands = removeImpliedExists(operands)
+		*warnings = append(*warnings, contradictions(operands)...)
+	}
+
+	sort.SliceStable(operands, func(i, j int) bool {
+		return exprCost(operands[i]) < exprCost(operands[j])
+	})
+
+	return joinExprs(e.Operator, operands)
+}
+
+// removeImpliedExists removes "exists" operands of "and" for paths checked by other operands,
+// comparisons (except ones with null) and any() are never satisfied by missing values
+func removeImpliedExists(operands []Expr) []Expr {
+	checked := map[string]bool{}
+	for _, operand := range operands {
+		switch o := operand.(type) {
+		case *CompareExpr:
+			checked[o.Path] = checked[o.Path] || o.Type != TypeNull
+		case *AnyExpr:
+			checked[o.Path] = true
+		}
+	}
+
+	res := operands[:0]
+	for _, operand := range operands {
+		if o, ok := operand.(*ExistsExpr); ok && checked[o.Path] {
+			continue
+		}
+		res = append(res, operand)
+	}
+	return res
+}
+
+// exprCost estimates relative cost of expression evaluation
+func exprCost(expr Expr) int {
+	switch e := expr.(type) {
+	case *ConstExpr:
+		return 0
+	case *ExistsExpr:
+		return pathCost(e.Path)
+	case *CompareExpr:
+		cost := pathCost(e.Path) + 1
+		switch {
+		case isLikeOperator(e.Operator):
+			cost += 10
+		case e.Func != FuncNone:
+			cost += 4
+		case e.Operator != OpEq && e.Operator != OpNotEq:
+			cost++
+		}
+		return cost
+	case *AnyExpr:
+		return pathCost(e.Path) + 4*exprCost(e.Operand)
+	case *JSONPathExpr:
+		return 20
+	case *NotExpr:
+		return exprCost(e.Operand)
+	case *LogicalExpr:
+		cost := 0
+		for _, operand := range e.Operands {
+			cost += exprCost(operand)
+		}
+		return cost
+	default:
+		return 0
+	}
+}
+
+// pathCost is count of steps of path lookup
+func pathCost(path string) int {
+	return strings.Count(path, ".") + 1
+}
+
+// contradictions finds operands of "and" which can't be satisfied together
+func contradictions(operands []Expr) []Warning {
+	var res []Warning
+
+	strs := make(map[string]bool, len(operands))
+	for _, operand := range operands {
+		strs[exprString(operand)] = true
+	}
+	for _, operand := range operands {
+		if o, ok := operand.(*NotExpr); ok && strs[exprString(o.Operand)] {
+			res = append(res, Warning{
+				Expr:    exprString(o.Operand) + " and " + exprString(o),
+				Message: "expression and its negation can never be satisfied together",
+			})
+		}
+	}
+
+	// comparisons are grouped by function and path in order of their first occurrence
+	var keys []string
+	groups := map[string][]*CompareExpr{}
+	for _, operand := range operands {
+		if o, ok := operand.(*CompareExpr); ok && !isLikeOperator(o.Operator) && o.Type != TypeNull {
+			key := o.Func.String() + "(" + o.Path + ")"
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], o)
+		}
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 || satisfiable(group) {
+			continue
+		}
+
+		exprs := make([]Expr, 0, len(group))
+		for _, compare := range group {
+			exprs = append(exprs, compare)
+		}
+		message := "comparisons can never be satisfied together"
+		if group[0].Func != FuncLen {
+			message += " by single value, only by array of values"
+		}
+		res = append(res, Warning{Expr: exprString(joinExprs(LogicalAnd, exprs)), Message: message})
+	}
+
+	return res
+}
+
+// satisfiable checks that some string, number or boolean value satisfies all comparisons of the same value.
+// Result of len() is always a number
+func satisfiable(group []*CompareExpr) bool {
+	numbers := make([]float64, 0, len(group))
+	strs := make([]string, 0, len(group))
+	bools := make([]bool, 0, len(group))
+	for _, compare := range group {
+		strs = append(strs, compare.Value)
+		if num, err := strconv.ParseFloat(compare.Value, 64); err == nil {
+			numbers = append(numbers, num)
+		}
+		if boolean, err := strconv.ParseBool(compare.Value); err == nil {
+			bools = append(bools, boolean)
+		}
+	}
+
+	if len(numbers) == len(group) && satisfiableNumbers(group, numbers) {
+		return true
+	}
+	if group[0].Func == FuncLen {
+		return false
+	}
+
+	if satisfiableRange(group, func(i, j int) int { return strings.Compare(strs[i], strs[j]) }) {
+		return true
+	}
+
+	return len(bools) == len(group) && satisfiableBools(group, bools)
+}
+
+func satisfiableNumbers(group []*CompareExpr, numbers []float64) bool {
+	for i, num := range numbers {
+		// NaN is not equal, less or greater than any number
+		if math.IsNaN(num) && group[i].Operator != OpNotEq {
+			return false
+		}
+	}
+
+	return satisfiableRange(group, func(i, j int) int {
+		switch {
+		case numbers[i] < numbers[j]:
+			return -1
+		case numbers[i] > numbers[j]:
+			return 1
+		default:
+			return 0
+		}
+	})
+}
+
+// satisfiableRange checks that comparisons with ordered values (accessed by index of comparison) intersect.
+// Values are supposed to be dense, so only equal bounds of range are checked for strictness
+func satisfiableRange(group []*CompareExpr, compare func(i, j int) int) bool {
+	lower, upper, eq := -1, -1, -1
+	for i, c := range group {
+		switch c.Operator {
+		case OpEq:
+			if eq >= 0 && compare(i, eq) != 0 {
+				return false
+			}
+			eq = i
+		case OpGt, OpGte:
+			if lower < 0 || compare(i, lower) > 0 || (compare(i, lower) == 0 && c.Operator == OpGt) {
+				lower = i
+			}
+		case OpLt, OpLte:
+			if upper < 0 || compare(i, upper) < 0 || (compare(i, upper) == 0 && c.Operator == OpLt) {
+				upper = i
+			}
+		}
+	}
+
+	// the only possible value is equal one or both inclusive bounds
+	only := eq
+	if lower >= 0 && upper >= 0 {
+		switch order := compare(lower, upper); {
+		case order > 0:
+			return false
+		case order == 0 && (group[lower].Operator == OpGt || group[upper].Operator == OpLt):
+			return false
+		case order == 0 && only < 0:
+			only = lower
+		}
+	}
+
+	if only < 0 {
+		return true
+	}
+
+	for i, c := range group {
+		order := compare(only, i)
+		switch c.Operator {
+		case OpEq:
+			if order != 0 {
+				return false
+			}
+		case OpNotEq:
+			if order == 0 {
+				return false
+			}
+		case OpGt:
+			if order <= 0 {
+				return false
+			}
+		case OpGte:
+			if order < 0 {
+				return false
+			}
+		case OpLt:
+			if order >= 0 {
+				return false
+			}
+		case OpLte:
+			if order > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// satisfiableBools checks comparisons of boolean value, they support only equality
+func satisfiableBools(group []*CompareExpr, bools []bool) bool {
+	for _, candidate := range []bool{false, true} {
+		ok := true
+		for i, c := range group {
+			switch c.Operator {
+			case OpEq:
+				ok = ok && bools[i] == candidate
+			case OpNotEq:
+				ok = ok && bools[i] != candidate
+			default:
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
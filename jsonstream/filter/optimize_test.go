@@ -0,0 +1,148 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimize(t *testing.T) {
+	cases := []struct {
+		name             string
+		inputExpr        string
+		expectedExpr     string
+		expectedWarnings []string
+	}{
+		{
+			name:         "Constants are folded",
+			inputExpr:    "a = 1 and true and (b = 2 or false)",
+			expectedExpr: "a = 1 and b = 2",
+		},
+		{
+			name:         "Absorbing constant decides result",
+			inputExpr:    "a = 1 or not false",
+			expectedExpr: "true",
+		},
+		{
+			name:         "Double negation is removed",
+			inputExpr:    "not not a = 1 and not (not (not b = 2))",
+			expectedExpr: "a = 1 and not b = 2",
+		},
+		{
+			name:         "Operands are ordered by cost",
+			inputExpr:    `name ~ "^J" and lower(job.company) = firm and children.age > 5 and id = 1 and jsonpath('$.x')`,
+			expectedExpr: `id = 1 and children.age > 5 and lower(job.company) = firm and name ~ '^J' and jsonpath('$.x')`,
+		},
+		{
+			name:         "Nested operands are flattened and duplicates removed",
+			inputExpr:    "(a = 1 and (b = 2 and a = 1)) and (c = 3 or (c = 3 or d = 4))",
+			expectedExpr: "a = 1 and b = 2 and (c = 3 or d = 4)",
+		},
+		{
+			name:         "Implied exists is removed",
+			inputExpr:    "exists(a) and a > 1 and exists(b) and exists(c) and any(c, @ = 1)",
+			expectedExpr: "exists(b) and a > 1 and any(c, @ = 1)",
+		},
+		{
+			name:         "Operand of any is optimized",
+			inputExpr:    "any(children, not not age > 5 and false)",
+			expectedExpr: "false",
+			expectedWarnings: []string{
+				"any(children, not not age > 5 and false): condition can never be satisfied",
+			},
+		},
+		{
+			name:         "Contradicting ranges",
+			inputExpr:    "a > 5 and a < 3",
+			expectedExpr: "a > 5 and a < 3",
+			expectedWarnings: []string{
+				"a > 5 and a < 3: comparisons can never be satisfied together by single value, only by array of values",
+			},
+		},
+		{
+			name:         "Contradicting equalities",
+			inputExpr:    "id = 1 and (status = new and status = done or len(tags) >= 3 and len(tags) < 3)",
+			expectedExpr: "id = 1 and (status = new and status = done or len(tags) >= 3 and len(tags) < 3)",
+			expectedWarnings: []string{
+				"status = new and status = done: comparisons can never be satisfied together by single value, only by array of values",
+				"len(tags) >= 3 and len(tags) < 3: comparisons can never be satisfied together",
+			},
+		},
+		{
+			name:             "Strings between numbers are satisfiable",
+			inputExpr:        "a > 10 and a < 9",
+			expectedExpr:     "a > 10 and a < 9",
+			expectedWarnings: nil,
+		},
+		{
+			name:         "Expression and its negation",
+			inputExpr:    "a = 1 and b = 2 and not a = 1",
+			expectedExpr: "a = 1 and b = 2 and not a = 1",
+			expectedWarnings: []string{
+				"a = 1 and not a = 1: expression and its negation can never be satisfied together",
+			},
+		},
+		{
+			name:         "Satisfiable comparisons",
+			inputExpr:    "a >= 5 and a <= 5 and a != 6 and b = true and b != false and c = 1 and c = 1.0",
+			expectedExpr: "a != 6 and b = true and b != false and c = 1 and c = 1.0 and a >= 5 and a <= 5",
+		},
+		{
+			name:         "Excluded only value",
+			inputExpr:    "a >= 5 and a <= 5 and a != 5",
+			expectedExpr: "a != 5 and a >= 5 and a <= 5",
+			expectedWarnings: []string{
+				"a >= 5 and a <= 5 and a != 5: comparisons can never be satisfied together by single value, only by array of values",
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cond, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			optimized, warnings := filter.Optimize(*cond)
+			assert.Equal(t, testCase.expectedExpr, optimized.String())
+
+			var actualWarnings []string
+			for _, warning := range warnings {
+				actualWarnings = append(actualWarnings, warning.String())
+			}
+			assert.Equal(t, testCase.expectedWarnings, actualWarnings)
+		})
+	}
+}
+
+func TestOptimize_SameResults(t *testing.T) {
+	elems := []string{
+		`{"a": 1, "b": "x", "c": [1, 5], "d": {"e": true}}`,
+		`{"a": 7, "b": "y", "c": [], "d": {"e": false}}`,
+		`{"b": "x", "c": [2]}`,
+	}
+	exprs := []string{
+		"not not a = 1 and (b = x or false)",
+		"exists(c) and any(c, @ > 3) or d.e = true and exists(d)",
+		"(a > 0 and a > 0) and not (b = y or b = y)",
+		`b ~ "x|y" and len(c) >= 1 and a < 5`,
+	}
+
+	for _, expr := range exprs {
+		cond, err := filter.NewConditionFromStr(expr)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		optimized, _ := filter.Optimize(*cond)
+
+		for _, elem := range elems {
+			_, expected, err := filter.ProcessElem(*cond, []byte(elem))
+			assert.NoError(t, err)
+			_, actual, err := filter.ProcessElem(optimized, []byte(elem))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual, "%s on %s", expr, elem)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const endOfInput = "end of input"
+
+// ParseError describes position and reason of condition parsing failure
+type ParseError struct {
+	// Input is the whole parsed condition
+	Input string
+	// Offset is a byte offset of the error in input
+	Offset int
+	// Line and Column (both starts from 1, column is counted in runes) of the error in input
+	Line   int
+	Column int
+	// Msg is a short description of the error
+	Msg string
+	// Found is a part of input the parser failed on
+	Found string
+	// Expected lists tokens that are allowed at error position
+	Expected []string
+	// Suggestions lists known tokens that are similar to found one
+	Suggestions []string
+	// Err is a cause of the error if any (ErrInvalidOperator, ErrUnboundVariable, etc.)
+	Err error
+}
+
+func newParseError(input string, offset int, msg string) *ParseError {
+	line := 1 + strings.Count(input[:offset], "\n")
+	lineStart := strings.LastIndexByte(input[:offset], '\n') + 1
+
+	return &ParseError{
+		Input:  input,
+		Offset: offset,
+		Line:   line,
+		Column: 1 + utf8.RuneCountInString(input[lineStart:offset]),
+		Msg:    msg,
+	}
+}
+
+// Error builds single line description of the error
+func (e *ParseError) Error() string {
+	var res strings.Builder
+	fmt.Fprintf(&res, "invalid expression at %s: %s", e.position(), e.Msg)
+
+	if e.Found != "" && !strings.Contains(e.Msg, e.Found) {
+		fmt.Fprintf(&res, ", found '%s'", e.Found)
+	}
+	if len(e.Expected) > 0 {
+		fmt.Fprintf(&res, ", expected %s", strings.Join(e.Expected, " or "))
+	}
+	if len(e.Suggestions) > 0 {
+		fmt.Fprintf(&res, "; did you mean '%s'?", strings.Join(e.Suggestions, "' or '"))
+	}
+
+	return res.String()
+}
+
+func (e *ParseError) position() string {
+	return fmt.Sprintf("%d:%d", e.Line, e.Column)
+}
+
+// Cause returns underlying error to be compatible with errors.Cause
+func (e *ParseError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns underlying error to be compatible with errors.Is and errors.As
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Snippet returns line of input with the error underlined by carets
+func (e *ParseError) Snippet() string {
+	lineStart := strings.LastIndexByte(e.Input[:e.Offset], '\n') + 1
+	lineEnd := strings.IndexByte(e.Input[e.Offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(e.Input)
+	} else {
+		lineEnd += e.Offset
+	}
+
+	// keep tabs of original line to align carets under the error
+	padding := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, e.Input[lineStart:e.Offset])
+
+	width := 1
+	if e.Found != "" && e.Found != endOfInput {
+		width = utf8.RuneCountInString(e.Found)
+	}
+
+	prefix := fmt.Sprintf("%d | ", e.Line)
+	return prefix + e.Input[lineStart:lineEnd] + "\n" +
+		strings.Repeat(" ", len(prefix)-2) + "| " + padding + strings.Repeat("^", width)
+}
+
+// suggest returns candidates that are close enough to word to be its misspelling
+func suggest(word string, candidates []string) []string {
+	maxDistance := 2
+	if utf8.RuneCountInString(word) <= 3 {
+		maxDistance = 1
+	}
+
+	var res []string
+	for _, candidate := range candidates {
+		if candidate != word && levenshtein(strings.ToLower(word), candidate) <= maxDistance {
+			res = append(res, candidate)
+		}
+	}
+
+	return res
+}
+
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(br)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
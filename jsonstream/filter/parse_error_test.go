@@ -0,0 +1,130 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConditionFromStr_ParseError(t *testing.T) {
+	cases := []struct {
+		name                string
+		inputExpr           string
+		vars                map[string]interface{}
+		expectedOffset      int
+		expectedLine        int
+		expectedColumn      int
+		expectedFound       string
+		expectedSuggestions []string
+		expectedCause       error
+	}{
+		{
+			name:                "Misspelled operator",
+			inputExpr:           "age => 5",
+			expectedOffset:      4,
+			expectedLine:        1,
+			expectedColumn:      5,
+			expectedFound:       "=>",
+			expectedSuggestions: []string{">="},
+			expectedCause:       filter.ErrInvalidOperator,
+		},
+		{
+			name:                "Misspelled function",
+			inputExpr:           "service = api and lowwer(tags) = red",
+			expectedOffset:      18,
+			expectedLine:        1,
+			expectedColumn:      19,
+			expectedFound:       "lowwer",
+			expectedSuggestions: []string{"lower"},
+		},
+		{
+			name:                "Unbound variable",
+			inputExpr:           "latency > $treshold",
+			vars:                map[string]interface{}{"threshold": 250},
+			expectedOffset:      10,
+			expectedLine:        1,
+			expectedColumn:      11,
+			expectedFound:       "$treshold",
+			expectedSuggestions: []string{"$threshold"},
+			expectedCause:       filter.ErrUnboundVariable,
+		},
+		{
+			name:           "Multiline with unicode",
+			inputExpr:      "имя = 'Иван'\nand (возраст > 5",
+			expectedOffset: len("имя = 'Иван'\nand (возраст > 5"),
+			expectedLine:   2,
+			expectedColumn: 17,
+			expectedFound:  "end of input",
+		},
+		{
+			name:           "Typed value of unsupported operator",
+			inputExpr:      "enabled > json(true)",
+			expectedOffset: 10,
+			expectedLine:   1,
+			expectedColumn: 11,
+			expectedFound:  "json(true)",
+			expectedCause:  filter.ErrUnsupportedOperator,
+		},
+		{
+			name:           "Typed value of array",
+			inputExpr:      "tags = json([1, 2])",
+			expectedOffset: 12,
+			expectedLine:   1,
+			expectedColumn: 13,
+			expectedFound:  "[1,",
+			expectedCause:  filter.ErrInvalidExpr,
+		},
+		{
+			name:           "Invalid json literal",
+			inputExpr:      "name = json(John)",
+			expectedOffset: 12,
+			expectedLine:   1,
+			expectedColumn: 13,
+			expectedFound:  "John)",
+			expectedCause:  filter.ErrInvalidExpr,
+		},
+		{
+			name:           "Missing operator",
+			inputExpr:      "(attr) = 1",
+			expectedOffset: 5,
+			expectedLine:   1,
+			expectedColumn: 6,
+			expectedFound:  ")",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := filter.NewConditionFromStr(testCase.inputExpr, filter.WithVars(testCase.vars))
+			perr, ok := err.(*filter.ParseError)
+			if !assert.True(t, ok, "error isn't ParseError: %v", err) {
+				return
+			}
+
+			assert.Equal(t, testCase.expectedOffset, perr.Offset)
+			assert.Equal(t, testCase.expectedLine, perr.Line)
+			assert.Equal(t, testCase.expectedColumn, perr.Column)
+			assert.Equal(t, testCase.expectedFound, perr.Found)
+			assert.Equal(t, testCase.expectedSuggestions, perr.Suggestions)
+			if testCase.expectedCause != nil {
+				assert.Equal(t, testCase.expectedCause, errors.Cause(err))
+			}
+		})
+	}
+}
+
+func TestParseError_Snippet(t *testing.T) {
+	_, err := filter.NewConditionFromStr("status = 500 and\n\tlatency => 100")
+	perr, ok := err.(*filter.ParseError)
+	if !assert.True(t, ok, "error isn't ParseError: %v", err) {
+		return
+	}
+
+	assert.Equal(t, "2 | \tlatency => 100\n  | \t        ^^", perr.Snippet())
+	assert.Equal(t,
+		"invalid expression at 2:10: unknown operator '=>', expected '=' or '!=' or '<' or '<=' or '>' or '>=' or '~' or '!~'; "+
+			"did you mean '>='?",
+		perr.Error())
+}
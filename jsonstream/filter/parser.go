@@ -0,0 +1,649 @@
+package filter
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	operatorChars = "=!<>~"
+	existsFunc    = "exists"
+	anyFunc       = "any"
+	jsonPathFunc  = "jsonpath"
+	typedFunc     = "json"
+	selfPath      = "@"
+)
+
+var (
+	valueFunctions = map[string]Function{
+		FuncLen.String():   FuncLen,
+		FuncLower.String(): FuncLower,
+		FuncUpper.String(): FuncUpper,
+	}
+
+	knownFunctions = []string{existsFunc, anyFunc, jsonPathFunc, FuncLen.String(), FuncLower.String(), FuncUpper.String()}
+
+	// operatorMisspellings maps operators of other languages to their analogues
+	operatorMisspellings = map[string]Operator{
+		"==":  OpEq,
+		"===": OpEq,
+		"<>":  OpNotEq,
+		"=!":  OpNotEq,
+		"!==": OpNotEq,
+		"=>":  OpGte,
+		"=<":  OpLte,
+		"=~":  OpLike,
+		"~=":  OpLike,
+		"~!":  OpNotLike,
+	}
+
+	expectedOperand = []string{"path", "function", "'('", "'not'"}
+)
+
+// parser builds expression tree from string representation of condition.
+//
+// Grammar:
+//
+//	expr       = and {("or" | "||") and}
+//	and        = unary {("and" | "&&") unary}
+//	unary      = "not" unary | "(" expr ")" | "true" | "false" | "exists(" path ")" | "any(" path "," expr ")" | comparison
+//	comparison = (path | function "(" path ")") operator value
+//	value      = quoted string | $variable | "json(" json literal ")" | bare word(s)
+type parser struct {
+	input string
+	pos   int
+	depth int
+	opts  parseOptions
+}
+
+func newParser(input string, opts parseOptions) *parser {
+	return &parser{input: input, opts: opts}
+}
+
+func (p *parser) parse() (Expr, error) {
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if !p.eof() {
+		return nil, p.fail(p.pos, "unexpected input", "'and'", "'or'", endOfInput)
+	}
+
+	return expr, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	return p.parseLogical(LogicalOr, "||", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	return p.parseLogical(LogicalAnd, "&&", p.parseUnary)
+}
+
+func (p *parser) parseLogical(op LogicalOperator, symbol string, parseOperand func() (Expr, error)) (Expr, error) {
+	first, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Expr{first}
+	for p.acceptKeyword(op.String(), symbol) {
+		next, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+
+	return &LogicalExpr{Operator: op, Operands: operands}, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.acceptKeyword("not", "") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Operand: operand}, nil
+	}
+
+	if value, ok := p.acceptConst(); ok {
+		return &ConstExpr{Value: value}, nil
+	}
+
+	p.skipSpaces()
+	if name, ok := p.peekCall(); ok {
+		return p.parseCall(name)
+	}
+
+	if p.peek() != '(' {
+		return p.parseComparison()
+	}
+
+	start := p.pos
+	p.pos++
+	p.depth++
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if p.peek() != ')' {
+		perr := p.fail(p.pos, "unclosed bracket", "')'")
+		perr.Msg += " opened at " + newParseError(p.input, start, "").position()
+		return nil, perr
+	}
+	p.pos++
+	p.depth--
+
+	return expr, nil
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	path, err := p.readPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.finishComparison(FuncNone, path)
+}
+
+func (p *parser) finishComparison(fn Function, path string) (Expr, error) {
+	op, err := p.readOperator()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	valueStart := p.pos
+	value, typ, err := p.readValue(fn, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if isLikeOperator(op) {
+		expr, err := newLikeExpr(fn, path, op, value)
+		if err != nil {
+			perr := p.fail(valueStart, "invalid regular expression")
+			perr.Err = err
+			return nil, perr
+		}
+		expr.Type = typ
+		if err := p.checkTyped(expr, valueStart); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	expr := &CompareExpr{Func: fn, Path: path, Operator: op, Value: value, Type: typ}
+	if err := p.checkTyped(expr, valueStart); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// checkTyped checks that typed value may be compared by operator
+func (p *parser) checkTyped(expr *CompareExpr, valueStart int) error {
+	if err := expr.validateType(); err != nil {
+		perr := p.fail(valueStart, err.Error())
+		perr.Err = err
+		return perr
+	}
+	return nil
+}
+
+// parseCall parses function call: predicates exists(path), any(path, expr), jsonpath(query)
+// or comparison of transformed value
+func (p *parser) parseCall(name string) (Expr, error) {
+	start := p.pos
+	fn, isValueFunc := valueFunctions[strings.ToLower(name)]
+	isAny := strings.EqualFold(name, anyFunc)
+	isJSONPath := strings.EqualFold(name, jsonPathFunc)
+	if !isValueFunc && !isAny && !isJSONPath && !strings.EqualFold(name, existsFunc) {
+		perr := p.fail(start, "unknown function '"+name+"'")
+		perr.Found = name
+		perr.Suggestions = suggest(name, knownFunctions)
+		return nil, perr
+	}
+
+	p.pos = strings.IndexByte(p.input[p.pos:], '(') + p.pos + 1
+	if isAny {
+		return p.parseAny()
+	}
+	if isJSONPath {
+		return p.parseJSONPath()
+	}
+
+	path, err := p.readArgPath(")")
+	if err != nil {
+		return nil, err
+	}
+
+	if !isValueFunc {
+		return &ExistsExpr{Path: path}, nil
+	}
+
+	return p.finishComparison(fn, path)
+}
+
+func (p *parser) parseAny() (Expr, error) {
+	path, err := p.readArgPath(",")
+	if err != nil {
+		return nil, err
+	}
+
+	p.depth++
+	operand, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if p.peek() != ')' {
+		return nil, p.fail(p.pos, "unclosed bracket of function call", "')'")
+	}
+	p.pos++
+	p.depth--
+
+	return &AnyExpr{Path: path, Operand: operand}, nil
+}
+
+func (p *parser) parseJSONPath() (Expr, error) {
+	p.skipSpaces()
+	start := p.pos
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		return nil, p.fail(p.pos, "expected quoted JSONPath query as argument of function", "quoted query")
+	}
+
+	query, err := p.readQuoted(quote)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := newJSONPathExpr(query)
+	if err != nil {
+		perr := p.fail(start, "invalid JSONPath query")
+		perr.Err = err
+		// position inside of query is kept when it is written without escapes
+		if queryErr, ok := err.(*ParseError); ok && p.input[start+1:p.pos-1] == query {
+			perr = p.fail(start+1+queryErr.Offset, "invalid JSONPath query: "+queryErr.Msg, queryErr.Expected...)
+			perr.Suggestions = queryErr.Suggestions
+			perr.Err = err
+		}
+		return nil, perr
+	}
+
+	p.skipSpaces()
+	if p.peek() != ')' {
+		return nil, p.fail(p.pos, "unclosed bracket of function call", "')'")
+	}
+	p.pos++
+
+	return expr, nil
+}
+
+// readPath reads left operand of comparison: everything up to operator, quoted parts may contain any symbols
+func (p *parser) readPath() (string, error) {
+	p.skipSpaces()
+	start := p.pos
+	if err := p.skipPath(operatorChars); err != nil {
+		return "", err
+	}
+
+	path := strings.TrimSpace(p.input[start:p.pos])
+	switch {
+	case path == "" && p.eof():
+		return "", p.fail(p.pos, "expected condition", expectedOperand...)
+	case path == "":
+		return "", p.fail(p.pos, "empty path", expectedOperand...)
+	case p.eof() || p.peek() == ')':
+		return "", p.fail(p.pos, "expected operator after path '"+path+"'", operatorsList()...)
+	}
+
+	return path, nil
+}
+
+// readArgPath reads path passed to function up to terminator (closing bracket or comma)
+func (p *parser) readArgPath(terminator string) (string, error) {
+	p.skipSpaces()
+	start := p.pos
+	if err := p.skipPath(terminator); err != nil {
+		return "", err
+	}
+
+	path := strings.TrimSpace(p.input[start:p.pos])
+	if path == "" {
+		return "", p.fail(p.pos, "expected path as argument of function", "path")
+	}
+	if p.eof() || p.input[p.pos:p.pos+1] != terminator {
+		return "", p.fail(p.pos, "unexpected end of function arguments", "'"+terminator+"'")
+	}
+	p.pos++
+
+	return path, nil
+}
+
+// skipPath moves position to one of stop symbols, closing bracket or end of input skipping quoted parts
+func (p *parser) skipPath(stopChars string) error {
+	for !p.eof() && !strings.ContainsRune(stopChars, rune(p.peek())) && p.peek() != ')' {
+		c := p.peek()
+		if c != '\'' && c != '"' {
+			p.pos++
+			continue
+		}
+
+		end := strings.IndexByte(p.input[p.pos+1:], c)
+		if end < 0 {
+			perr := p.fail(p.pos, "unterminated quote in path", "'"+string(c)+"'")
+			perr.Found = string(c)
+			return perr
+		}
+		p.pos += end + 2
+	}
+
+	return nil
+}
+
+func (p *parser) readOperator() (Operator, error) {
+	p.skipSpaces()
+	start := p.pos
+	for !p.eof() && strings.ContainsRune(operatorChars, rune(p.peek())) {
+		p.pos++
+	}
+
+	opStr := p.input[start:p.pos]
+	op := newOperator(opStr)
+	if op != OpUnknown {
+		return op, nil
+	}
+
+	perr := p.fail(start, "unknown operator '"+opStr+"'", operatorsList()...)
+	perr.Found = opStr
+	perr.Err = errors.Wrapf(ErrInvalidOperator, "found operator %s", opStr)
+	if suggestion, ok := operatorMisspellings[opStr]; ok {
+		perr.Suggestions = []string{suggestion.String()}
+	}
+
+	return OpUnknown, perr
+}
+
+// readValue reads value of comparison, only values of variables and json literals are typed
+func (p *parser) readValue(fn Function, op Operator) (string, ValueType, error) {
+	p.skipSpaces()
+	if name, ok := p.peekCall(); ok && strings.EqualFold(name, typedFunc) {
+		return p.readTyped()
+	}
+
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		value, err := p.readQuoted(c)
+		return value, TypeAny, err
+	case c == '$' && p.pos+1 < len(p.input) && isIdentStart(rune(p.input[p.pos+1])):
+		return p.readVariable(fn, op)
+	default:
+		return p.readBare(), TypeAny, nil
+	}
+}
+
+func (p *parser) readVariable(fn Function, op Operator) (string, ValueType, error) {
+	start := p.pos
+	p.pos++
+	name := p.readIdent()
+
+	res, typ, err := bindVariable(name, fn, op, p.opts.vars)
+	if err != nil {
+		perr := p.fail(start, err.Error())
+		perr.Found = "$" + name
+		perr.Err = err
+		if errors.Cause(err) == ErrUnboundVariable {
+			perr.Suggestions = suggestVariables(name, p.opts.vars)
+		}
+		return "", TypeAny, perr
+	}
+
+	// regular expressions match only strings, so their variables aren't typed
+	if isLikeOperator(op) {
+		typ = TypeAny
+	}
+	return res, typ, nil
+}
+
+// readTyped reads json literal of typed value: json("5") is a string, json(5) is a number,
+// json(true) is a boolean and json(null) is null
+func (p *parser) readTyped() (string, ValueType, error) {
+	start := p.pos
+	p.readIdent()
+	p.skipSpaces()
+	p.pos++
+	p.skipSpaces()
+
+	literalStart := p.pos
+	dec := json.NewDecoder(strings.NewReader(p.input[p.pos:]))
+	dec.UseNumber()
+	var literal interface{}
+	if err := dec.Decode(&literal); err != nil {
+		perr := p.fail(literalStart, "invalid json literal", "string", "number", "'true'", "'false'", "'null'")
+		perr.Err = errors.Wrap(ErrInvalidExpr, err.Error())
+		return "", TypeAny, perr
+	}
+	p.pos += int(dec.InputOffset())
+
+	p.skipSpaces()
+	if p.peek() != ')' {
+		perr := p.fail(p.pos, "unclosed bracket", "')'")
+		perr.Msg += " opened at " + newParseError(p.input, start, "").position()
+		return "", TypeAny, perr
+	}
+	p.pos++
+
+	switch v := literal.(type) {
+	case string:
+		return v, TypeString, nil
+	case json.Number:
+		return v.String(), TypeNumber, nil
+	case bool:
+		return strconv.FormatBool(v), TypeBool, nil
+	case nil:
+		return "null", TypeNull, nil
+	default:
+		perr := p.fail(literalStart, "json literal of object or array can't be compared",
+			"string", "number", "'true'", "'false'", "'null'")
+		perr.Err = errors.Wrap(ErrInvalidExpr, "json literal of object or array")
+		return "", TypeAny, perr
+	}
+}
+
+// readQuoted reads value enclosed in quotes. Backslash escapes the quote and itself
+func (p *parser) readQuoted(quote byte) (string, error) {
+	var res strings.Builder
+	for i := p.pos + 1; i < len(p.input); i++ {
+		c := p.input[i]
+		switch {
+		case c == quote:
+			p.pos = i + 1
+			return res.String(), nil
+		case c == '\\' && i+1 < len(p.input) && (p.input[i+1] == quote || p.input[i+1] == '\\'):
+			i++
+			res.WriteByte(p.input[i])
+		default:
+			res.WriteByte(c)
+		}
+	}
+
+	perr := p.fail(p.pos, "unterminated quoted value", "'"+string(quote)+"'")
+	perr.Found = string(quote)
+	return "", perr
+}
+
+// readBare reads unquoted value up to the end of comparison: logical operator, closing bracket or end of input
+func (p *parser) readBare() string {
+	start := p.pos
+	for !p.eof() {
+		if p.peek() == ')' && p.depth > 0 {
+			break
+		}
+		if strings.HasPrefix(p.input[p.pos:], "&&") || strings.HasPrefix(p.input[p.pos:], "||") {
+			break
+		}
+		if p.pos > start && isSpace(p.input[p.pos-1]) && (p.isKeywordAt("and") || p.isKeywordAt("or")) {
+			break
+		}
+		p.pos++
+	}
+
+	return strings.TrimFunc(p.input[start:p.pos], func(c rune) bool {
+		return unicode.IsSpace(c) || c == '\'' || c == '"'
+	})
+}
+
+func (p *parser) readIdent() string {
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !isIdentStart(r) && !unicode.IsDigit(r) {
+			break
+		}
+		p.pos += size
+	}
+
+	return p.input[start:p.pos]
+}
+
+// acceptConst consumes true or false keyword if it is a whole operand
+func (p *parser) acceptConst() (value bool, ok bool) {
+	start := p.pos
+	p.skipSpaces()
+	keywordStart := p.pos
+	for _, keyword := range []string{"true", "false"} {
+		p.pos = keywordStart
+		end := p.pos + len(keyword)
+		if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], keyword) {
+			continue
+		}
+
+		p.pos = end
+		p.skipSpaces()
+		if p.eof() || p.peek() == ')' || p.isKeywordAt("and") || p.isKeywordAt("or") ||
+			strings.HasPrefix(p.input[p.pos:], "&&") || strings.HasPrefix(p.input[p.pos:], "||") {
+			return keyword == "true", true
+		}
+	}
+
+	p.pos = start
+	return false, false
+}
+
+// peekCall checks that identifier followed by opening bracket is placed at current position
+func (p *parser) peekCall() (string, bool) {
+	start := p.pos
+	name := p.readIdent()
+	p.skipSpaces()
+	isCall := name != "" && p.peek() == '('
+	p.pos = start
+
+	return name, isCall
+}
+
+// acceptKeyword skips spaces and consumes keyword (case insensitive) or its symbolic form if any present
+func (p *parser) acceptKeyword(keyword, symbol string) bool {
+	p.skipSpaces()
+	if symbol != "" && strings.HasPrefix(p.input[p.pos:], symbol) {
+		p.pos += len(symbol)
+		return true
+	}
+
+	if p.isKeywordAt(keyword) {
+		p.pos += len(keyword)
+		return true
+	}
+
+	return false
+}
+
+// isKeywordAt checks that keyword is placed at current position and followed by space, bracket or end of input
+func (p *parser) isKeywordAt(keyword string) bool {
+	end := p.pos + len(keyword)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], keyword) {
+		return false
+	}
+
+	return end == len(p.input) || isSpace(p.input[end]) || p.input[end] == '('
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && isSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+// fail builds error at offset, found token is a word placed at offset
+func (p *parser) fail(offset int, msg string, expected ...string) *ParseError {
+	perr := newParseError(p.input, offset, msg)
+	perr.Expected = expected
+	perr.Found = endOfInput
+	if offset < len(p.input) {
+		end := strings.IndexFunc(p.input[offset:], unicode.IsSpace)
+		if end < 0 {
+			end = len(p.input) - offset
+		}
+		perr.Found = p.input[offset : offset+end]
+	}
+
+	return perr
+}
+
+func operatorsList() []string {
+	res := make([]string, 0, len(validOperators))
+	for _, op := range validOperators {
+		res = append(res, "'"+op.String()+"'")
+	}
+	return res
+}
+
+func suggestVariables(name string, vars map[string]interface{}) []string {
+	names := make([]string, 0, len(vars))
+	for varName := range vars {
+		names = append(names, varName)
+	}
+
+	res := suggest(name, names)
+	for i := range res {
+		res[i] = "$" + res[i]
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// prefilter rejects elements which lack literals required by condition without parsing them.
+// Literals are searched in raw bytes, so elements with escape sequences are never rejected:
+// any character of json string may be escaped
+type prefilter struct {
+	// every clause must be satisfied, clause is satisfied by any of its literals found in element
+	clauses [][][]byte
+}
+
+// newPrefilter derives literals from expression, it returns nil if nothing is required
+func newPrefilter(expr Expr) *prefilter {
+	var clauses [][][]byte
+	seen := map[string]bool{}
+	for _, clause := range requiredLiterals(expr) {
+		key := strings.Join(clause, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		literals := make([][]byte, 0, len(clause))
+		for _, literal := range clause {
+			literals = append(literals, []byte(literal))
+		}
+		clauses = append(clauses, literals)
+	}
+
+	if len(clauses) == 0 {
+		return nil
+	}
+	return &prefilter{clauses: clauses}
+}
+
+// rejects checks that element is valid json without some of required literals, invalid elements aren't rejected
+// to be reported by evaluation
+func (p *prefilter) rejects(elem []byte) bool {
+	if p.mayMatch(elem) {
+		return false
+	}
+	_, valid := validJSON(elem)
+	return valid
+}
+
+// mayMatch checks that element has all required literals
+func (p *prefilter) mayMatch(elem []byte) bool {
+	if p == nil || bytes.IndexByte(elem, '\\') >= 0 {
+		return true
+	}
+
+	for _, clause := range p.clauses {
+		found := false
+		for _, literal := range clause {
+			if bytes.Contains(elem, literal) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// requiredLiterals returns clauses of literals which are found in raw element satisfying expression
+func requiredLiterals(expr Expr) [][]string {
+	switch e := expr.(type) {
+	case *CompareExpr:
+		if e.Type == TypeNull {
+			// null is satisfied by missing value, so nothing is required
+			return nil
+		}
+		clauses := keyLiterals(e.Path)
+		if e.Func != FuncNone {
+			return clauses
+		}
+
+		switch e.Operator {
+		case OpEq:
+			if clause := equalityLiterals(e.Value); clause != nil {
+				clauses = append(clauses, clause)
+			}
+		case OpLike:
+			// every match of regular expression starts with its literal prefix, only strings are matched
+			re, err := e.regexp()
+			if err != nil {
+				return clauses
+			}
+			if prefix, _ := re.LiteralPrefix(); isRawLiteral(prefix) {
+				clauses = append(clauses, []string{prefix})
+			}
+		}
+		return clauses
+	case *ExistsExpr:
+		return keyLiterals(e.Path)
+	case *AnyExpr:
+		return append(keyLiterals(e.Path), requiredLiterals(e.Operand)...)
+	case *LogicalExpr:
+		if e.Operator == LogicalAnd {
+			var clauses [][]string
+			for _, operand := range e.Operands {
+				clauses = append(clauses, requiredLiterals(operand)...)
+			}
+			return clauses
+		}
+
+		// satisfied operand of "or" gives one of literals of its most selective clause
+		var alternatives []string
+		for _, operand := range e.Operands {
+			best := mostSelective(requiredLiterals(operand))
+			if best == nil {
+				return nil
+			}
+			alternatives = append(alternatives, best...)
+		}
+		return [][]string{alternatives}
+	default:
+		return nil
+	}
+}
+
+// keyLiterals returns quoted keys of path, value found by path exists only if all of them are present
+func keyLiterals(path string) [][]string {
+	if path == selfPath {
+		return nil
+	}
+
+	var clauses [][]string
+	for _, key := range strings.Split(path, ".") {
+		if isRawLiteral(key) {
+			clauses = append(clauses, []string{`"` + key + `"`})
+		}
+	}
+	return clauses
+}
+
+// equalityLiterals returns alternative forms of values equal to value of condition: quoted string and
+// boolean literal. Numbers have many forms (1, 1.0, 1e0), so values equal to number are not restricted
+func equalityLiterals(value string) []string {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return nil
+	}
+	if !isRawLiteral(value) {
+		return nil
+	}
+
+	clause := []string{`"` + value + `"`}
+	if boolean, err := strconv.ParseBool(value); err == nil {
+		clause = append(clause, strconv.FormatBool(boolean))
+	}
+	return clause
+}
+
+// isRawLiteral checks that literal is written in element as is. Invalid UTF-8 is decoded
+// to replacement character, so literals containing it may be absent in raw element
+func isRawLiteral(literal string) bool {
+	return literal != "" && !strings.ContainsRune(literal, '\uFFFD')
+}
+
+// mostSelective chooses clause with the longest shortest literal
+func mostSelective(clauses [][]string) []string {
+	var best []string
+	bestLen := 0
+	for _, clause := range clauses {
+		shortest := len(clause[0])
+		for _, literal := range clause[1:] {
+			if len(literal) < shortest {
+				shortest = len(literal)
+			}
+		}
+		if shortest > bestLen {
+			best, bestLen = clause, shortest
+		}
+	}
+	return best
+}
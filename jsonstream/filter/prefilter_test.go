@@ -0,0 +1,151 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithPrefilter_SameResults checks elements evaluated without errors, errors are checked by TestWithPrefilter_Errors
+func TestWithPrefilter_SameResults(t *testing.T) {
+	elems := []string{
+		`{"id": 1, "name": "John", "flag": true, "job": {"company": "Some firm"}, "tags": ["new", "hot"]}`,
+		`{"id": 1.0, "name": "John", "flag": "t", "job": {"company": "Some firm"}}`,
+		`{"id": 1e0, "job": {"company": "Some firm"}, "tags": "new"}`,
+		`{"id": 2, "name": "J` + "\xff" + `ohn", "flag": false, "job": {"company": "Other"}}`,
+		`{"name": "Johnny", "children": [{"name": "Alex", "toys": ["car"]}, {"name": "Pit"}]}`,
+		`{"name": "john", "job": "Some firm", "flag": "true"}`,
+		`[{"name": "John"}, {"id": 1}]`,
+		`{"job": {"comp\u0061ny": "Some\u0020firm"}, "name": "Jo\u0068n", "tags": ["n\u0065w"]}`,
+		`{"name": "J\ufffdohn", "flag": "\u0074"}`,
+	}
+	conds := []filter.Condition{
+		filter.Path("job.company").Eq("Some firm"),
+		filter.Path("name").Eq("John"),
+		filter.Path("name").Eq("J\uFFFDohn"),
+		filter.Path("id").Eq(1),
+		filter.Path("flag").Eq("t"),
+		filter.Path("flag").Eq(true),
+		filter.Path("name").Match("^Jo"),
+		filter.Path("name").Match("(?i)JOHN"),
+		filter.Path("name").Match("hn|hnny"),
+		filter.Path("name").Lower().Eq("john"),
+		filter.Path("tags").Eq("new").Or(filter.Path("job").Eq("Some firm")),
+		filter.Path("tags").Eq("new").Or(filter.Path("id").Gt(1)),
+		filter.Path("children").Any(filter.Path("name").Eq("Alex").And(filter.Path("toys").Any(filter.Path("@").Eq("car")))),
+		filter.Path("job.company").Exists(),
+		filter.Not(filter.Path("name").Eq("John")),
+		filter.Path("name").NotEq("John"),
+		filter.Path("name").In("John", "Johnny"),
+	}
+
+	for _, cond := range conds {
+		exact, err := filter.Compile(cond)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		prefiltered, err := filter.Compile(cond, filter.WithPrefilter())
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		for _, elem := range elems {
+			expected, err := exact.Match([]byte(elem))
+			if err != nil {
+				continue
+			}
+
+			actual, err := prefiltered.Match([]byte(elem))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual, "%s on %s", cond.String(), elem)
+
+			result, err := prefiltered.MatchCaptures([]byte(elem))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, result.IsOk, "%s on %s", cond.String(), elem)
+		}
+	}
+}
+
+func TestWithPrefilter_Rejected(t *testing.T) {
+	cond := filter.Path("job.company").Eq("Some firm")
+	prefiltered, err := filter.Compile(cond, filter.WithPrefilter())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// element without required literals isn't decoded
+	isOk, err := prefiltered.Match([]byte(`{"job": {"company": "Other"}}`))
+	assert.NoError(t, err)
+	assert.False(t, isOk)
+
+	// invalid element without required literals gives error as without prefilter
+	_, err = prefiltered.Match([]byte(`{"job": {"company": "Other"},`))
+	assert.Error(t, err)
+
+	_, err = prefiltered.MatchCaptures([]byte(`{"job": {"company": "Other"},`))
+	assert.Error(t, err)
+
+	_, err = prefiltered.Match([]byte(`{"job": {"company": "Some firm"},`))
+	assert.Error(t, err)
+
+	// element with escape sequences is always parsed
+	_, err = prefiltered.Match([]byte(`{"job": {"company": "Other\n"},`))
+	assert.Error(t, err)
+}
+
+func TestWithPrefilter_Errors(t *testing.T) {
+	cases := []struct {
+		name              string
+		elem              string
+		expectedErr       bool
+		expectedPrefilter bool
+	}{
+		{
+			name:              "Invalid json",
+			elem:              `{"a": "x",`,
+			expectedErr:       true,
+			expectedPrefilter: true,
+		},
+		{
+			name:              "Number compared with word",
+			elem:              `{"a": 5}`,
+			expectedErr:       true,
+			expectedPrefilter: false,
+		},
+		{
+			name:              "Object compared with word",
+			elem:              `{"a": {"b": 1}}`,
+			expectedErr:       true,
+			expectedPrefilter: false,
+		},
+		{
+			name:              "Number compared with word, literal is present",
+			elem:              `{"a": 5, "b": "abc"}`,
+			expectedErr:       true,
+			expectedPrefilter: true,
+		},
+	}
+
+	cond := filter.Path("a").Eq("abc")
+	exact, err := filter.Compile(cond)
+	if !assert.NoError(t, err) {
+		return
+	}
+	prefiltered, err := filter.Compile(cond, filter.WithPrefilter())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := exact.Match([]byte(testCase.elem))
+			assert.Equal(t, testCase.expectedErr, err != nil)
+
+			// comparisons of rejected elements aren't evaluated, so they give no errors
+			isOk, err := prefiltered.Match([]byte(testCase.elem))
+			assert.Equal(t, testCase.expectedPrefilter, err != nil)
+			assert.False(t, isOk)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Program is a condition checked and prepared to match many elements
+type Program struct {
+	expr      Expr
+	prefilter *prefilter
+	backend   Backend
+}
+
+// CompileOption customizes compilation of condition
+type CompileOption func(p *Program)
+
+// WithPrefilter makes program reject elements without decoding them if they lack literals required by condition,
+// e.g. quoted keys of paths and values of equalities. It changes error semantics: rejected elements are only
+// validated, so invalid json gives the same errors, but comparisons which fail on values of other types
+// (a = abc on {"a": 5}, an object compared with a string) give false without error for them
+func WithPrefilter() CompileOption {
+	return func(p *Program) {
+		p.prefilter = newPrefilter(p.expr)
+	}
+}
+
+// WithBackend sets representation of elements which condition is evaluated over, lazy backend is used by default
+func WithBackend(backend Backend) CompileOption {
+	return func(p *Program) {
+		p.backend = backend
+	}
+}
+
+// Compile checks condition and prepares regular expressions and JSONPath queries used by it
+func Compile(cond Condition, opts ...CompileOption) (*Program, error) {
+	if cond.err != nil {
+		return nil, errors.Wrap(cond.err, "invalid condition")
+	}
+	if cond.expr == nil {
+		return nil, errors.New("empty condition")
+	}
+
+	if err := prepareExpr(cond.expr); err != nil {
+		return nil, err
+	}
+
+	program := &Program{expr: cond.expr, backend: BackendLazy}
+	for _, opt := range opts {
+		opt(program)
+	}
+	if program.backend != BackendLazy && program.backend != BackendJSON && program.backend != BackendDecoded {
+		return nil, errors.Errorf("unknown backend '%s'", program.backend.String())
+	}
+	return program, nil
+}
+
+// Condition returns condition compiled to program
+func (p *Program) Condition() Condition {
+	return Condition{expr: p.expr}
+}
+
+// Match solves accordance of stream element to condition
+func (p *Program) Match(elem []byte) (bool, error) {
+	if p.prefilter.rejects(elem) {
+		return false, nil
+	}
+
+	return matchElem(elem, p.expr, p.backend)
+}
+
+// MatchDecoded solves accordance of already decoded element to condition. Element must consist of values
+// encoding/json decodes to interface{}: map[string]interface{}, []interface{}, string, float64, bool and nil.
+// Backend of program doesn't matter, decoded element is evaluated like by decoded backend
+func (p *Program) MatchDecoded(val interface{}) (bool, error) {
+	isOk, err := checkExpr(decodedDoc{data: val}, p.expr)
+	return isOk, errors.Wrap(err, "error check path")
+}
+
+// prepareExpr compiles patterns of expression tree, so errors in them are found before processing of stream
+func prepareExpr(expr Expr) error {
+	switch e := expr.(type) {
+	case nil:
+		return errors.Wrap(ErrInvalidExpr, "missing operand")
+	case *CompareExpr:
+		if newOperator(e.Operator.String()) == OpUnknown {
+			return errors.Wrapf(ErrInvalidOperator, "found operator %s", e.Operator.String())
+		}
+		if _, ok := valueFunctions[e.Func.String()]; e.Func != FuncNone && !ok {
+			return errors.Wrapf(ErrInvalidExpr, "unknown function '%s'", e.Func.String())
+		}
+		if err := e.validateType(); err != nil {
+			return errors.Wrap(ErrInvalidExpr, err.Error())
+		}
+		if isLikeOperator(e.Operator) {
+			_, err := e.regexp()
+			return err
+		}
+	case *AnyExpr:
+		return prepareExpr(e.Operand)
+	case *JSONPathExpr:
+		_, err := e.query()
+		return errors.Wrap(err, "invalid JSONPath query")
+	case *NotExpr:
+		return prepareExpr(e.Operand)
+	case *LogicalExpr:
+		if e.Operator != LogicalAnd && e.Operator != LogicalOr {
+			return errors.Wrapf(ErrInvalidExpr, "unknown logical operator '%s'", e.Operator.String())
+		}
+		for _, operand := range e.Operands {
+			if err := prepareExpr(operand); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
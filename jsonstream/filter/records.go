@@ -0,0 +1,177 @@
+package filter
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrRecordTooLarge represents record exceeding maximum size of record
+	ErrRecordTooLarge = errors.New("record is too large")
+
+	// ErrMalformedValue represents broken structure of json value which stream can't be split by
+	ErrMalformedValue = errors.New("malformed json value")
+)
+
+// recordSource splits stream into records, record is valid until the next call of next
+type recordSource interface {
+	next() ([]byte, error)
+	// position returns number of the last read record starting from 1 and offset of its first byte
+	position() (int64, int64)
+	// buffered returns count of bytes which are read from stream but not split yet
+	buffered() int
+}
+
+// newRecordSource splits stream by input format of config
+func newRecordSource(r io.Reader, bufSize int, config *streamConfig) recordSource {
+	reader := bufio.NewReaderSize(r, bufSize)
+	format := config.inputFormat
+	if format == InputAuto {
+		format = detectInputFormat(reader)
+	}
+
+	switch format {
+	case InputArray:
+		return newArrayReader(reader, config.maxRecordSize, config.inputPath)
+	case InputConcat:
+		return newValueReader(reader, config.maxRecordSize)
+	case InputJSONSeq:
+		return newSeqReader(reader, config.maxRecordSize)
+	default:
+		return newRecordReader(reader, bufSize, config.delimiter, config.maxRecordSize)
+	}
+}
+
+// detectInputFormat peeks the first value of stream: json text sequence starts with record separator,
+// other streams are split by structure of values
+func detectInputFormat(reader *bufio.Reader) InputFormat {
+	// bytes are peeked as soon as they are available, so the first value isn't waited for longer than needed
+	for n := 1; n <= reader.Size(); n = reader.Buffered() + 1 {
+		data, err := reader.Peek(n)
+		for _, c := range data {
+			switch {
+			case c == recordSeparator:
+				return InputJSONSeq
+			case !isSpace(c):
+				return InputConcat
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return InputConcat
+}
+
+// isRecordError checks that error concerns only the last read record and stream may be split further
+func isRecordError(err error) bool {
+	cause := errors.Cause(err)
+	return cause == ErrRecordTooLarge || cause == ErrMalformedValue
+}
+
+// recordReader splits stream into records by delimiter. Carriage return before line feed delimiter
+// is dropped, the last record may lack delimiter
+type recordReader struct {
+	reader    *bufio.Reader
+	delimiter byte
+	// maxSize limits size of record without delimiter, zero means unlimited size
+	maxSize int
+	buf     []byte
+	// num is a number of the last read record starting from 1
+	num int64
+	// offset is a count of bytes read from stream, start is an offset of the last read record
+	offset int64
+	start  int64
+}
+
+func newRecordReader(r io.Reader, bufSize int, delimiter byte, maxSize int) *recordReader {
+	return &recordReader{reader: bufio.NewReaderSize(r, bufSize), delimiter: delimiter, maxSize: maxSize}
+}
+
+func (r *recordReader) position() (int64, int64) {
+	return r.num, r.start
+}
+
+func (r *recordReader) buffered() int {
+	return r.reader.Buffered()
+}
+
+// next returns the next record, it's valid until the next call. Records larger than maximum size are read
+// up to delimiter and give ErrRecordTooLarge, so the next record is read correctly
+func (r *recordReader) next() ([]byte, error) {
+	r.buf = r.buf[:0]
+	r.start = r.offset
+	tooLarge := false
+	for {
+		chunk, err := r.reader.ReadSlice(r.delimiter)
+		r.offset += int64(len(chunk))
+		if err == nil && len(r.buf) == 0 && !tooLarge {
+			// record fits into buffer of reader, it's not copied
+			return r.record(chunk, false)
+		}
+
+		if !tooLarge {
+			r.buf = append(r.buf, chunk...)
+			// two bytes are reserved for CR LF
+			if r.maxSize > 0 && len(r.buf) > r.maxSize+2 {
+				tooLarge = true
+				r.buf = r.buf[:0]
+			}
+		}
+
+		switch {
+		case err == nil:
+			return r.record(r.buf, tooLarge)
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF:
+			if len(r.buf) == 0 && !tooLarge {
+				return nil, io.EOF
+			}
+			return r.record(r.buf, tooLarge)
+		default:
+			return nil, errors.Wrap(err, "read record error")
+		}
+	}
+}
+
+func (r *recordReader) record(data []byte, tooLarge bool) ([]byte, error) {
+	r.num++
+	if n := len(data); n > 0 && data[n-1] == r.delimiter {
+		data = data[:n-1]
+		if n--; r.delimiter == '\n' && n > 0 && data[n-1] == '\r' {
+			data = data[:n-1]
+		}
+	}
+
+	if tooLarge || (r.maxSize > 0 && len(data) > r.maxSize) {
+		return nil, errors.Wrapf(ErrRecordTooLarge, "maximum size is %d bytes", r.maxSize)
+	}
+	return data, nil
+}
+
+// RecordWriter writes records in output format of options (see WithOutputFormat and WithDelimiter),
+// writes aren't buffered
+type RecordWriter struct {
+	w      io.Writer
+	config streamConfig
+	buf    []byte
+}
+
+// NewRecordWriter constructs RecordWriter, options other than output format and delimiter are ignored
+func NewRecordWriter(w io.Writer, opts ...StreamOption) (*RecordWriter, error) {
+	config := newStreamConfig(opts)
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &RecordWriter{w: w, config: config}, nil
+}
+
+// Write writes one record
+func (w *RecordWriter) Write(record []byte) error {
+	w.buf = w.config.appendRecord(w.buf[:0], record)
+	_, err := w.w.Write(w.buf)
+	return errors.Wrap(err, "write record error")
+}
@@ -0,0 +1,32 @@
+package filter
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to compile '%s' as regular expression", pattern)
+	}
+	return re, nil
+}
+
+// newLikeExpr builds comparison of path with regular expression, pattern is compiled once for all elements
+func newLikeExpr(fn Function, path string, op Operator, pattern string) (*CompareExpr, error) {
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &CompareExpr{Func: fn, Path: path, Operator: op, Value: pattern, re: re}, nil
+}
+
+// regexp returns pattern of like operator compiled by builder or parser of expression
+func (e *CompareExpr) regexp() (*regexp.Regexp, error) {
+	if e.re != nil {
+		return e.re, nil
+	}
+	return compileRegexp(e.Value)
+}
@@ -0,0 +1,299 @@
+package filter
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of values in equality index, value of condition is indexed as every kind it can be compared with
+const (
+	eqKindString = iota
+	eqKindNumber
+	eqKindBool
+	eqKindObject
+)
+
+// Rule is a named condition of RuleSet
+type Rule struct {
+	ID        string
+	Condition Condition
+}
+
+// RuleSet matches element with many conditions parsing it once. Rules requiring equality of path to value
+// are indexed by path and value, so only rules which may be satisfied by values of element are evaluated.
+// Rules are also indexed by kinds of values their comparisons fail on, so results and errors are the same
+// as of rules evaluated one by one
+type RuleSet struct {
+	ids   []string
+	exprs []Expr
+	// index maps required value of path to rules, paths are distinct paths of index
+	index map[eqKey][]int
+	paths []string
+	// failures maps path and kind of value to rules comparison of which fails on values of the kind
+	failures map[eqKey][]int
+	// scanned are rules which can't be indexed, they are evaluated for every element
+	scanned []int
+}
+
+type eqKey struct {
+	path  string
+	kind  int
+	value string
+}
+
+// NewRuleSet compiles rules, identifiers of rules must be unique
+func NewRuleSet(rules []Rule) (*RuleSet, error) {
+	set := &RuleSet{index: map[eqKey][]int{}, failures: map[eqKey][]int{}}
+	known := make(map[string]bool, len(rules))
+	paths := map[string]bool{}
+	addPath := func(path string) {
+		if !paths[path] {
+			paths[path] = true
+			set.paths = append(set.paths, path)
+		}
+	}
+
+	for i, rule := range rules {
+		if known[rule.ID] {
+			return nil, errors.Errorf("duplicate rule '%s'", rule.ID)
+		}
+		known[rule.ID] = true
+
+		program, err := Compile(rule.Condition)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rule '%s'", rule.ID)
+		}
+		set.ids = append(set.ids, rule.ID)
+		set.exprs = append(set.exprs, program.expr)
+
+		predicates := requiredEqualities(program.expr)
+		failures, predictable := failureKeys(program.expr)
+		if len(predicates) == 0 || !predictable {
+			set.scanned = append(set.scanned, i)
+			continue
+		}
+
+		for _, predicate := range predicates {
+			for _, key := range literalKeys(predicate.Path, predicate.Value) {
+				set.index[key] = append(set.index[key], i)
+			}
+			addPath(predicate.Path)
+		}
+		for _, key := range failures {
+			set.failures[key] = append(set.failures[key], i)
+			addPath(key.path)
+		}
+	}
+
+	return set, nil
+}
+
+// Len returns count of rules
+func (s *RuleSet) Len() int {
+	return len(s.ids)
+}
+
+// Match returns identifiers of all rules satisfied by element in order of rules
+func (s *RuleSet) Match(elem []byte) ([]string, error) {
+	return s.match(elem, false)
+}
+
+// MatchFirst returns identifier of the first rule satisfied by element
+func (s *RuleSet) MatchFirst(elem []byte) (string, bool, error) {
+	ids, err := s.match(elem, true)
+	if err != nil || len(ids) == 0 {
+		return "", false, err
+	}
+	return ids[0], true, nil
+}
+
+func (s *RuleSet) match(elem []byte, first bool) ([]string, error) {
+	var data interface{}
+	if err := json.Unmarshal(elem, &data); err != nil {
+		return nil, errors.Wrap(err, "parse json error")
+	}
+
+	var res []string
+	for _, i := range s.candidates(data) {
+		isOk, err := checkExpr(decodedDoc{data: data}, s.exprs[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "error check rule '%s'", s.ids[i])
+		}
+
+		if isOk {
+			res = append(res, s.ids[i])
+			if first {
+				break
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// candidates returns sorted indexes of rules which may be satisfied by element or fail on it: not indexed rules,
+// rules required values of which are found in element and rules failing on kinds of values found in element
+func (s *RuleSet) candidates(data interface{}) []int {
+	res := append([]int{}, s.scanned...)
+	for _, path := range s.paths {
+		val, _ := lookupDecoded(data, path)
+		forEachLeaf(val, func(val interface{}) {
+			if key, ok := valueKey(path, val); ok {
+				res = append(res, s.index[key]...)
+			}
+			if kind, ok := valueKind(val); ok {
+				res = append(res, s.failures[eqKey{path: path, kind: kind}]...)
+			}
+		})
+	}
+
+	if len(res) == len(s.scanned) {
+		return res
+	}
+
+	sort.Ints(res)
+	unique := res[:1]
+	for _, i := range res[1:] {
+		if i != unique[len(unique)-1] {
+			unique = append(unique, i)
+		}
+	}
+	return unique
+}
+
+// requiredEqualities returns comparisons one of which holds for every element satisfying expression.
+// Empty result means that expression can't be indexed
+func requiredEqualities(expr Expr) []*CompareExpr {
+	switch e := expr.(type) {
+	case *CompareExpr:
+		// equality with null is satisfied by missing value, it can't be found by index
+		if e.Func == FuncNone && e.Operator == OpEq && e.Type != TypeNull {
+			return []*CompareExpr{e}
+		}
+	case *LogicalExpr:
+		if e.Operator == LogicalAnd {
+			for _, operand := range e.Operands {
+				if res := requiredEqualities(operand); len(res) > 0 {
+					return res
+				}
+			}
+			return nil
+		}
+
+		var res []*CompareExpr
+		for _, operand := range e.Operands {
+			operandRes := requiredEqualities(operand)
+			if len(operandRes) == 0 {
+				return nil
+			}
+			res = append(res, operandRes...)
+		}
+		return res
+	}
+
+	return nil
+}
+
+// failureKeys returns paths and kinds of values comparisons of expression fail on like chechkValue does.
+// False result means that failures of expression can't be predicted by values of paths
+func failureKeys(expr Expr) ([]eqKey, bool) {
+	switch e := expr.(type) {
+	case *CompareExpr:
+		if e.Func != FuncNone {
+			return nil, false
+		}
+
+		keys := []eqKey{{path: e.Path, kind: eqKindObject}}
+		if isLikeOperator(e.Operator) {
+			return keys, true
+		}
+		if _, err := strconv.ParseFloat(e.Value, 64); err != nil && e.accepts(TypeNumber) {
+			keys = append(keys, eqKey{path: e.Path, kind: eqKindNumber})
+		}
+		_, err := strconv.ParseBool(e.Value)
+		if (err != nil || (e.Operator != OpEq && e.Operator != OpNotEq)) && e.accepts(TypeBool) {
+			keys = append(keys, eqKey{path: e.Path, kind: eqKindBool})
+		}
+		return keys, true
+	case *ExistsExpr, *ConstExpr:
+		return nil, true
+	case *NotExpr:
+		return failureKeys(e.Operand)
+	case *LogicalExpr:
+		var res []eqKey
+		for _, operand := range e.Operands {
+			keys, ok := failureKeys(operand)
+			if !ok {
+				return nil, false
+			}
+			res = append(res, keys...)
+		}
+		return res, true
+	}
+
+	return nil, false
+}
+
+// literalKeys returns keys of values equal to value of condition like chechkValue compares them
+func literalKeys(path string, value string) []eqKey {
+	keys := []eqKey{{path: path, kind: eqKindString, value: value}}
+	if num, err := strconv.ParseFloat(value, 64); err == nil {
+		keys = append(keys, numberKey(path, num))
+	}
+	if boolean, err := strconv.ParseBool(value); err == nil {
+		keys = append(keys, eqKey{path: path, kind: eqKindBool, value: strconv.FormatBool(boolean)})
+	}
+	return keys
+}
+
+func valueKey(path string, val interface{}) (eqKey, bool) {
+	switch v := val.(type) {
+	case string:
+		return eqKey{path: path, kind: eqKindString, value: v}, true
+	case float64:
+		return numberKey(path, v), true
+	case bool:
+		return eqKey{path: path, kind: eqKindBool, value: strconv.FormatBool(v)}, true
+	default:
+		return eqKey{}, false
+	}
+}
+
+func valueKind(val interface{}) (int, bool) {
+	switch val.(type) {
+	case string:
+		return eqKindString, true
+	case float64:
+		return eqKindNumber, true
+	case bool:
+		return eqKindBool, true
+	case map[string]interface{}:
+		return eqKindObject, true
+	default:
+		return 0, false
+	}
+}
+
+func numberKey(path string, num float64) eqKey {
+	// negative zero equals to zero
+	if num == 0 {
+		num = 0
+	}
+	return eqKey{path: path, kind: eqKindNumber, value: strconv.FormatFloat(num, 'g', -1, 64)}
+}
+
+// forEachLeaf calls fn for value or every element of array like chechkValue looks through nested arrays
+func forEachLeaf(val interface{}, fn func(val interface{})) {
+	elems, isArray := val.([]interface{})
+	if !isArray {
+		fn(val)
+		return
+	}
+
+	for _, elem := range elems {
+		forEachLeaf(elem, fn)
+	}
+}
@@ -0,0 +1,201 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRules(t *testing.T, conditions ...string) []filter.Rule {
+	var rules []filter.Rule
+	for i := 0; i+1 < len(conditions); i += 2 {
+		cond, err := filter.NewConditionFromStr(conditions[i+1])
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		rules = append(rules, filter.Rule{ID: conditions[i], Condition: *cond})
+	}
+	return rules
+}
+
+func TestRuleSet_Match(t *testing.T) {
+	set, err := filter.NewRuleSet(newRules(t,
+		"errors", "level = error",
+		"slow", "latency > 1000",
+		"errors-or-warnings", "level = error or level = warn",
+		"slow-api", "service = api and latency >= 500",
+		"numeric-code", "code = 500",
+		"flag", "debug = true",
+		"tag", "tags = urgent",
+		"not-info", "not level = info",
+	))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 8, set.Len())
+
+	cases := []struct {
+		name        string
+		elem        string
+		expectedIDs []string
+	}{
+		{
+			name:        "Indexed and scanned rules",
+			elem:        `{"level": "error", "latency": 1200, "service": "api"}`,
+			expectedIDs: []string{"errors", "slow", "errors-or-warnings", "slow-api", "not-info"},
+		},
+		{
+			name:        "One of alternatives",
+			elem:        `{"level": "warn", "latency": 10}`,
+			expectedIDs: []string{"errors-or-warnings", "not-info"},
+		},
+		{
+			name:        "Indexed value isn't enough",
+			elem:        `{"level": "info", "service": "api", "latency": 100}`,
+			expectedIDs: nil,
+		},
+		{
+			name:        "Values of different types",
+			elem:        `{"level": "info", "code": 5e2, "debug": true}`,
+			expectedIDs: []string{"numeric-code", "flag"},
+		},
+		{
+			name:        "String value equal to number literal",
+			elem:        `{"level": "info", "code": "500"}`,
+			expectedIDs: []string{"numeric-code"},
+		},
+		{
+			name:        "Elements of arrays",
+			elem:        `{"level": ["info", "error"], "tags": [["minor"], ["urgent"]]}`,
+			expectedIDs: []string{"errors", "errors-or-warnings", "tag"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ids, err := set.Match([]byte(testCase.elem))
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedIDs, ids)
+
+			for _, rule := range newRules(t,
+				"errors", "level = error",
+				"slow", "latency > 1000",
+				"errors-or-warnings", "level = error or level = warn",
+				"slow-api", "service = api and latency >= 500",
+				"numeric-code", "code = 500",
+				"flag", "debug = true",
+				"tag", "tags = urgent",
+				"not-info", "not level = info",
+			) {
+				_, isOk, err := filter.ProcessElem(rule.Condition, []byte(testCase.elem))
+				assert.NoError(t, err)
+				assert.Equal(t, isOk, contains(ids, rule.ID), rule.ID)
+			}
+
+			id, found, err := set.MatchFirst([]byte(testCase.elem))
+			assert.NoError(t, err)
+			if len(testCase.expectedIDs) > 0 {
+				assert.True(t, found)
+				assert.Equal(t, testCase.expectedIDs[0], id)
+			} else {
+				assert.False(t, found)
+			}
+		})
+	}
+}
+
+func TestRuleSet_Errors(t *testing.T) {
+	_, err := filter.NewRuleSet(newRules(t, "a", "id = 1", "a", "id = 2"))
+	assert.EqualError(t, err, "duplicate rule 'a'")
+
+	_, err = filter.NewRuleSet([]filter.Rule{{ID: "a", Condition: filter.Path("name").Match("(")}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid rule 'a'")
+
+	set, err := filter.NewRuleSet(newRules(t, "a", "id = 1", "b", "flag != yes"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = set.Match([]byte(`{"id": 1, "flag": true}`))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error check rule 'b'")
+
+	_, err = set.Match([]byte(`{"id": 1,`))
+	assert.Error(t, err)
+}
+
+func TestRuleSet_SameAsPrograms(t *testing.T) {
+	rules := newRules(t,
+		"errors", "level = error",
+		"code", "code = 500 and level = error",
+		"debug", "debug = true or level = debug",
+		"typed", "level = json(\"error\")",
+		"typed-code", "code = json(500)",
+		"like", "level = error and service ~ api",
+		"not", "level = warn and not latency > 10",
+		"scanned", "latency > 1000",
+		"any", "level = error and any(tags, @ = urgent)",
+	)
+	set, err := filter.NewRuleSet(rules)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	elems := []string{
+		`{"level": "error", "code": 500}`,
+		`{"level": "error", "code": "abc"}`,
+		`{"level": 5}`,
+		`{"level": true}`,
+		`{"level": {"name": "error"}}`,
+		`{"level": ["debug", 5]}`,
+		`{"level": "warn", "latency": true}`,
+		`{"level": "warn", "latency": 5}`,
+		`{"code": true, "debug": "yes"}`,
+		`{"level": "error", "service": {"name": "api"}}`,
+		`{"level": "error", "tags": ["urgent", 5]}`,
+		`{"latency": "slow"}`,
+		`{}`,
+	}
+	for _, elem := range elems {
+		t.Run(elem, func(t *testing.T) {
+			var expectedIDs []string
+			var expectedErr error
+			var failedID string
+			for _, rule := range rules {
+				program, err := filter.Compile(rule.Condition, filter.WithBackend(filter.BackendDecoded))
+				if !assert.NoError(t, err) {
+					return
+				}
+				isOk, err := program.Match([]byte(elem))
+				if err != nil {
+					expectedIDs, expectedErr, failedID = nil, err, rule.ID
+					break
+				}
+				if isOk {
+					expectedIDs = append(expectedIDs, rule.ID)
+				}
+			}
+
+			ids, err := set.Match([]byte(elem))
+			assert.Equal(t, expectedIDs, ids)
+			if expectedErr == nil {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "error check rule '"+failedID+"'")
+				assert.Equal(t, errors.Cause(expectedErr), errors.Cause(err))
+			}
+		})
+	}
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxNestingDepth is a limit of nested arrays and objects, the same as encoding/json has
+const maxNestingDepth = 10000
+
+// validJSON checks element the same way as encoding/json does when it decodes element to interface{}:
+// syntax, nesting depth and range of numbers. It returns value of element without surrounding spaces
+// and doesn't allocate memory
+func validJSON(data []byte) ([]byte, bool) {
+	start := skipSpaces(data, 0)
+	end, ok := validValue(data, start, 0)
+	if !ok || skipSpaces(data, end) != len(data) {
+		return nil, false
+	}
+	return data[start:end], true
+}
+
+// validValue checks value starting at position i and returns position after it
+func validValue(data []byte, i int, depth int) (int, bool) {
+	if i >= len(data) {
+		return i, false
+	}
+
+	switch c := data[i]; {
+	case c == '{':
+		return validContainer(data, i, depth, '}')
+	case c == '[':
+		return validContainer(data, i, depth, ']')
+	case c == '"':
+		return validString(data, i)
+	case c == '-' || (c >= '0' && c <= '9'):
+		return validNumber(data, i)
+	case c == 't':
+		return validLiteral(data, i, "true")
+	case c == 'f':
+		return validLiteral(data, i, "false")
+	case c == 'n':
+		return validLiteral(data, i, "null")
+	default:
+		return i, false
+	}
+}
+
+func validContainer(data []byte, i int, depth int, closing byte) (int, bool) {
+	if depth++; depth > maxNestingDepth {
+		return i, false
+	}
+
+	i = skipSpaces(data, i+1)
+	if i < len(data) && data[i] == closing {
+		return i + 1, true
+	}
+
+	for {
+		var ok bool
+		if closing == '}' {
+			if i >= len(data) || data[i] != '"' {
+				return i, false
+			}
+			if i, ok = validString(data, i); !ok {
+				return i, false
+			}
+			if i = skipSpaces(data, i); i >= len(data) || data[i] != ':' {
+				return i, false
+			}
+			i = skipSpaces(data, i+1)
+		}
+
+		if i, ok = validValue(data, i, depth); !ok {
+			return i, false
+		}
+
+		if i = skipSpaces(data, i); i >= len(data) {
+			return i, false
+		}
+		switch data[i] {
+		case ',':
+			i = skipSpaces(data, i+1)
+		case closing:
+			return i + 1, true
+		default:
+			return i, false
+		}
+	}
+}
+
+func validString(data []byte, i int) (int, bool) {
+	for i++; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			return i + 1, true
+		case c < 0x20:
+			return i, false
+		case c == '\\':
+			if i++; i >= len(data) {
+				return i, false
+			}
+			switch data[i] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				if i+4 >= len(data) {
+					return i, false
+				}
+				for _, h := range data[i+1 : i+5] {
+					if !isHex(h) {
+						return i, false
+					}
+				}
+				i += 4
+			default:
+				return i, false
+			}
+		}
+	}
+	return i, false
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// validNumber checks grammar of number, numbers which may be out of range of float64 are parsed
+func validNumber(data []byte, i int) (int, bool) {
+	start := i
+	if data[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i < len(data) && data[i] == '0':
+		i++
+	case i < len(data) && data[i] >= '1' && data[i] <= '9':
+		i = skipDigits(data, i)
+	default:
+		return i, false
+	}
+
+	if i < len(data) && data[i] == '.' {
+		if i++; i >= len(data) || !isDigit(data[i]) {
+			return i, false
+		}
+		i = skipDigits(data, i)
+	}
+
+	exponent := false
+	if i < len(data) && (data[i] == 'e' || data[i] == 'E') {
+		exponent = true
+		if i++; i < len(data) && (data[i] == '+' || data[i] == '-') {
+			i++
+		}
+		if i >= len(data) || !isDigit(data[i]) {
+			return i, false
+		}
+		i = skipDigits(data, i)
+	}
+
+	// the largest float64 has 309 digits
+	if exponent || i-start > 300 {
+		if _, err := strconv.ParseFloat(string(data[start:i]), 64); err != nil {
+			return i, false
+		}
+	}
+
+	return i, true
+}
+
+func skipDigits(data []byte, i int) int {
+	for i < len(data) && isDigit(data[i]) {
+		i++
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func validLiteral(data []byte, i int, literal string) (int, bool) {
+	if len(data)-i < len(literal) || string(data[i:i+len(literal)]) != literal {
+		return i, false
+	}
+	return i + len(literal), true
+}
+
+func skipSpaces(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipValue returns position after value of valid element starting at position i
+func skipValue(data []byte, i int) int {
+	switch data[i] {
+	case '"':
+		return skipString(data, i)
+	case '{', '[':
+		depth := 0
+		for {
+			switch data[i] {
+			case '"':
+				i = skipString(data, i)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+	default:
+		for i < len(data) {
+			switch data[i] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return i
+			}
+			i++
+		}
+		return i
+	}
+}
+
+// skipString returns position after string of valid element starting at position i
+func skipString(data []byte, i int) int {
+	for i++; ; {
+		end := bytes.IndexByte(data[i:], '"')
+		i += end
+		// quote is escaped by odd count of backslashes before it
+		backslashes := 0
+		for j := i - 1; data[j] == '\\'; j-- {
+			backslashes++
+		}
+		i++
+		if backslashes%2 == 0 {
+			return i
+		}
+	}
+}
+
+// nextMember returns key and value of object member starting at position i and position of the next member,
+// members of valid object are read from position after '{' until '}' is found there
+func nextMember(data []byte, i int) (key []byte, value []byte, next int) {
+	keyEnd := skipString(data, i)
+	valueStart := skipSpaces(data, skipSpaces(data, keyEnd)+1)
+	valueEnd := skipValue(data, valueStart)
+	return data[i+1 : keyEnd-1], data[valueStart:valueEnd], skipComma(data, valueEnd)
+}
+
+// nextElem returns element of array starting at position i and position of the next element,
+// elements of valid array are read from position after '[' until ']' is found there
+func nextElem(data []byte, i int) (elem []byte, next int) {
+	end := skipValue(data, i)
+	return data[i:end], skipComma(data, end)
+}
+
+// firstItem returns position of the first member of object or element of array starting at position i
+func firstItem(data []byte, i int) int {
+	return skipSpaces(data, i+1)
+}
+
+func skipComma(data []byte, i int) int {
+	if i = skipSpaces(data, i); data[i] == ',' {
+		i = skipSpaces(data, i+1)
+	}
+	return i
+}
+
+// keyEquals compares raw key of object with decoded one
+func keyEquals(raw []byte, key string) bool {
+	if bytes.IndexByte(raw, '\\') < 0 && utf8.Valid(raw) {
+		return string(raw) == key
+	}
+
+	// escape sequences are decoded and invalid UTF-8 is replaced like encoding/json does
+	var decoded string
+	return json.Unmarshal(quoteRaw(raw), &decoded) == nil && decoded == key
+}
+
+// quoteRaw restores raw string with quotes
+func quoteRaw(raw []byte) []byte {
+	quoted := make([]byte, 0, len(raw)+2)
+	return append(append(append(quoted, '"'), raw...), '"')
+}
@@ -0,0 +1,649 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorPolicy defines handling of records which give errors of parsing and evaluation
+type ErrorPolicy string
+
+// Available error policies
+const (
+	// ErrorStop stops processing of stream on the first error
+	ErrorStop = ErrorPolicy("stop")
+	// ErrorSkip drops records with errors
+	ErrorSkip = ErrorPolicy("skip")
+)
+
+// OversizePolicy defines handling of records which are larger than maximum size
+type OversizePolicy string
+
+// Available policies of too large records
+const (
+	// OversizeError stops processing of stream with ErrRecordTooLarge
+	OversizeError = OversizePolicy("error")
+	// OversizeSkip drops too large records without evaluation
+	OversizeSkip = OversizePolicy("skip")
+)
+
+// InputFormat defines how stream is split into records
+type InputFormat string
+
+// Available input formats
+const (
+	// InputNDJSON splits stream by delimiter of records, it's one json value per line by default
+	InputNDJSON = InputFormat("ndjson")
+	// InputConcat splits stream by structure of json values, they may be pretty-printed or follow each other
+	InputConcat = InputFormat("concat")
+	// InputJSONSeq splits json text sequence (RFC 7464) by record separators
+	InputJSONSeq = InputFormat("json-seq")
+	// InputArray splits array into its elements, array may be nested into objects (see WithInputPath)
+	InputArray = InputFormat("array")
+	// InputAuto detects json text sequence by record separator at the beginning, other streams are read as InputConcat
+	InputAuto = InputFormat("auto")
+)
+
+// InputFormats are available input formats
+var InputFormats = []InputFormat{InputNDJSON, InputConcat, InputJSONSeq, InputArray, InputAuto}
+
+// OutputFormat defines how records are written
+type OutputFormat string
+
+// Available output formats
+const (
+	// OutputNDJSON writes records followed by delimiter, it's one json value per line by default:
+	// records spanning several lines are compacted
+	OutputNDJSON = OutputFormat("ndjson")
+	// OutputJSONSeq writes json text sequence (RFC 7464): records are preceded by record separator
+	// and followed by line feed
+	OutputJSONSeq = OutputFormat("json-seq")
+)
+
+// ExplainFormat is a format of traces written instead of matched records
+type ExplainFormat string
+
+// Available formats of traces
+const (
+	// ExplainText writes record followed by indented tree of trace
+	ExplainText = ExplainFormat("text")
+	// ExplainJSON writes json object with record and trace per record
+	ExplainJSON = ExplainFormat("json")
+)
+
+const (
+	// streamBatchSize is a maximum count of records processed by worker at once
+	streamBatchSize = 1024
+	// streamReadSize is a size of buffer of reader used by workers, records available without waiting form batch
+	streamReadSize = 1 << 20
+	// streamLineSize is a size of buffer of reader without workers
+	streamLineSize = 64 << 10
+)
+
+// Stats counts records of stream
+type Stats struct {
+	// Read is a count of records read before processing is over
+	Read int64 `json:"read"`
+	// Matched is a count of records satisfying condition
+	Matched int64 `json:"matched"`
+	// Skipped is a count of records which are larger than maximum size and dropped by OversizeSkip policy
+	Skipped int64 `json:"skipped"`
+	// Errored is a count of records which gave errors of parsing or evaluation
+	Errored int64 `json:"errored"`
+}
+
+func (s *Stats) add(other Stats) {
+	s.Read += other.Read
+	s.Matched += other.Matched
+	s.Skipped += other.Skipped
+	s.Errored += other.Errored
+}
+
+// StreamOption customizes processing of stream
+type StreamOption func(s *streamConfig)
+
+type streamConfig struct {
+	errorPolicy    ErrorPolicy
+	maxRecordSize  int
+	oversizePolicy OversizePolicy
+	inputFormat    InputFormat
+	inputPath      string
+	outputFormat   OutputFormat
+	delimiter      byte
+	workers        int
+	unordered      bool
+	annotateField  string
+	explain        ExplainFormat
+}
+
+// WithErrorPolicy sets handling of records which give errors, processing is stopped by default
+func WithErrorPolicy(policy ErrorPolicy) StreamOption {
+	return func(s *streamConfig) {
+		s.errorPolicy = policy
+	}
+}
+
+// WithMaxRecordSize limits size of record in bytes without delimiter, larger records are handled
+// by oversize policy. Size is unlimited by default
+func WithMaxRecordSize(size int) StreamOption {
+	return func(s *streamConfig) {
+		s.maxRecordSize = size
+	}
+}
+
+// WithOversizePolicy sets handling of records larger than maximum size independently of error policy,
+// processing is stopped by default
+func WithOversizePolicy(policy OversizePolicy) StreamOption {
+	return func(s *streamConfig) {
+		s.oversizePolicy = policy
+	}
+}
+
+// WithInputFormat sets splitting of stream into records, it's InputNDJSON by default
+func WithInputFormat(format InputFormat) StreamOption {
+	return func(s *streamConfig) {
+		s.inputFormat = format
+	}
+}
+
+// WithInputPath sets keys of objects separated by dot which lead to array of InputArray format,
+// e.g. "data.items" for {"data": {"items": [...]}}. Stream is the array itself by default
+func WithInputPath(path string) StreamOption {
+	return func(s *streamConfig) {
+		s.inputPath = path
+	}
+}
+
+// WithOutputFormat sets format of written records, it's OutputNDJSON by default
+func WithOutputFormat(format OutputFormat) StreamOption {
+	return func(s *streamConfig) {
+		s.outputFormat = format
+	}
+}
+
+// WithDelimiter sets delimiter of records, it's a line feed by default. Matched records are written
+// followed by delimiter, it splits stream only in InputNDJSON format
+func WithDelimiter(delimiter byte) StreamOption {
+	return func(s *streamConfig) {
+		s.delimiter = delimiter
+	}
+}
+
+// WithWorkers sets count of goroutines processing records, records are read and processed by batches
+// if it's greater than 1. Outputs are written in order of records
+func WithWorkers(workers int) StreamOption {
+	return func(s *streamConfig) {
+		s.workers = workers
+	}
+}
+
+// WithUnordered makes workers write outputs of records as soon as they are ready, not in order of records
+func WithUnordered() StreamOption {
+	return func(s *streamConfig) {
+		s.unordered = true
+	}
+}
+
+// WithAnnotations adds values which satisfied condition (see Program.MatchCaptures) to matched json objects
+// as the last field
+func WithAnnotations(field string) StreamOption {
+	return func(s *streamConfig) {
+		s.annotateField = field
+	}
+}
+
+// WithExplain writes traces of condition (see Program.Explain) for every record instead of matched records
+func WithExplain(format ExplainFormat) StreamOption {
+	return func(s *streamConfig) {
+		s.explain = format
+	}
+}
+
+// Stream reads records of reader, writes records satisfying program to writer and counts records.
+// Cancellation of context is checked between records. With several workers stream stopped by error
+// or cancellation doesn't wait for blocked reading, reader is closed if it's io.Closer
+func Stream(ctx context.Context, r io.Reader, w io.Writer, prog *Program, opts ...StreamOption) (Stats, error) {
+	config := newStreamConfig(opts)
+	if err := config.validate(); err != nil {
+		return Stats{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	s := &streamer{streamConfig: config, prog: prog, w: w}
+	if config.workers == 1 {
+		return s.runSequential(ctx, newRecordSource(r, streamLineSize, &config))
+	}
+	return s.runParallel(ctx, r, newRecordSource(r, streamReadSize, &config))
+}
+
+func (f InputFormat) valid() bool {
+	for _, format := range InputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func newStreamConfig(opts []StreamOption) streamConfig {
+	config := streamConfig{errorPolicy: ErrorStop, oversizePolicy: OversizeError, inputFormat: InputNDJSON, outputFormat: OutputNDJSON, delimiter: '\n', workers: 1}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+func (c *streamConfig) validate() error {
+	switch {
+	case c.errorPolicy != ErrorStop && c.errorPolicy != ErrorSkip:
+		return errors.Errorf("unknown error policy '%s'", c.errorPolicy)
+	case c.oversizePolicy != OversizeError && c.oversizePolicy != OversizeSkip:
+		return errors.Errorf("unknown oversize policy '%s'", c.oversizePolicy)
+	case !c.inputFormat.valid():
+		return errors.Errorf("unknown input format '%s'", c.inputFormat)
+	case c.outputFormat != OutputNDJSON && c.outputFormat != OutputJSONSeq:
+		return errors.Errorf("unknown output format '%s'", c.outputFormat)
+	case c.explain == ExplainText && c.outputFormat == OutputJSONSeq:
+		return errors.New("text traces can't be written as json text sequence")
+	case c.inputPath != "" && c.inputFormat != InputArray:
+		return errors.Errorf("input path is used only by '%s' input format", InputArray)
+	case c.explain != "" && c.explain != ExplainText && c.explain != ExplainJSON:
+		return errors.Errorf("unknown explain format '%s'", c.explain)
+	case c.explain != "" && c.annotateField != "":
+		return errors.New("records can't be explained and annotated at once")
+	case c.maxRecordSize < 0:
+		return errors.New("maximum size of record can't be negative")
+	case c.workers < 1:
+		return errors.New("count of workers must be positive")
+	default:
+		return nil
+	}
+}
+
+// streamer processes records of stream
+type streamer struct {
+	streamConfig
+	prog *Program
+	w    io.Writer
+}
+
+func (s *streamer) runSequential(ctx context.Context, reader recordSource) (Stats, error) {
+	var stats Stats
+	var out []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		record, err := reader.next()
+		if err != nil && err == io.EOF {
+			return stats, nil
+		}
+		if err != nil && !isRecordError(err) {
+			return stats, err
+		}
+
+		num, _ := reader.position()
+		out, err = s.handle(num, record, err, out[:0], &stats)
+		if writeErr := s.write(out); writeErr != nil {
+			return stats, writeErr
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+}
+
+// handle processes record and appends its output, error is returned if it stops processing of stream.
+// Record is not evaluated if it's too large or malformed
+func (s *streamer) handle(num int64, record []byte, readErr error, out []byte, stats *Stats) ([]byte, error) {
+	stats.Read++
+	if readErr != nil {
+		if errors.Cause(readErr) == ErrRecordTooLarge && s.oversizePolicy == OversizeSkip {
+			stats.Skipped++
+			return out, nil
+		}
+		stats.Errored++
+		if errors.Cause(readErr) == ErrMalformedValue && s.errorPolicy == ErrorSkip {
+			return out, nil
+		}
+		return out, errors.Wrapf(readErr, "process record %d error", num)
+	}
+
+	if s.explain != "" {
+		trace := s.prog.Explain(record)
+		if trace.Verdict {
+			stats.Matched++
+		}
+		if trace.Error != "" {
+			stats.Errored++
+		}
+		return s.appendTrace(out, record, trace)
+	}
+
+	var isOk bool
+	var captures []Capture
+	var err error
+	if s.annotateField != "" {
+		var result MatchResult
+		result, err = s.prog.MatchCaptures(record)
+		isOk, captures = result.IsOk, result.Captures
+	} else {
+		isOk, err = s.prog.Match(record)
+	}
+
+	if err != nil {
+		stats.Errored++
+		if s.errorPolicy == ErrorSkip {
+			return out, nil
+		}
+		return out, errors.Wrapf(err, "process record %d error", num)
+	}
+	if !isOk {
+		return out, nil
+	}
+
+	stats.Matched++
+	if s.annotateField != "" {
+		annotated, err := annotateElem(record, s.annotateField, captures)
+		if err != nil {
+			return out, err
+		}
+		record = annotated
+	}
+	return s.appendRecord(out, record), nil
+}
+
+// appendRecord appends record in output format, records spanning several lines are compacted
+// when they are delimited by line feed
+func (c *streamConfig) appendRecord(out []byte, record []byte) []byte {
+	if c.outputFormat == OutputJSONSeq {
+		out = append(out, recordSeparator)
+		return append(append(out, record...), '\n')
+	}
+	if c.delimiter == '\n' {
+		return append(appendCompact(out, record), '\n')
+	}
+	return append(append(out, record...), c.delimiter)
+}
+
+// appendCompact appends json value without line breaks, invalid values are appended as is
+func appendCompact(out []byte, value []byte) []byte {
+	if bytes.IndexByte(value, '\n') < 0 {
+		return append(out, value...)
+	}
+
+	buf := bytes.NewBuffer(out)
+	if err := json.Compact(buf, value); err != nil {
+		return append(out, value...)
+	}
+	return buf.Bytes()
+}
+
+// appendTrace appends record with trace of condition, json format gives one object per record
+func (s *streamer) appendTrace(out []byte, record []byte, trace Trace) ([]byte, error) {
+	if s.explain == ExplainText {
+		return append(out, fmt.Sprintf("%s\n%s\n", record, trace)...), nil
+	}
+
+	var elem interface{} = string(record)
+	if json.Valid(record) {
+		elem = json.RawMessage(record)
+	}
+
+	if s.outputFormat == OutputJSONSeq {
+		out = append(out, recordSeparator)
+	}
+	buf := bytes.NewBuffer(out)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(struct {
+		Element interface{} `json:"element"`
+		Trace
+	}{Element: elem, Trace: trace})
+	return buf.Bytes(), errors.Wrap(err, "encode trace error")
+}
+
+// annotateElem adds captures as the last field of json object, elements of other types are kept as is
+func annotateElem(elem []byte, field string, captures []Capture) ([]byte, error) {
+	trimmed := bytes.TrimSpace(elem)
+	if len(trimmed) < 2 || trimmed[0] != '{' || trimmed[len(trimmed)-1] != '}' {
+		return elem, nil
+	}
+
+	if captures == nil {
+		captures = []Capture{}
+	}
+
+	key, err := json.Marshal(field)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode annotation error")
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(captures); err != nil {
+		return nil, errors.Wrap(err, "encode annotation error")
+	}
+
+	body := bytes.TrimSpace(trimmed[:len(trimmed)-1])
+	res := make([]byte, 0, len(body)+len(key)+buf.Len()+3)
+	res = append(res, body...)
+	if len(body) > 1 {
+		res = append(res, ',')
+	}
+	res = append(res, key...)
+	res = append(res, ':')
+	res = append(res, bytes.TrimSpace(buf.Bytes())...)
+	return append(res, '}'), nil
+}
+
+func (s *streamer) write(out []byte) error {
+	if len(out) == 0 {
+		return nil
+	}
+	_, err := s.w.Write(out)
+	return errors.Wrap(err, "write output error")
+}
+
+// recordBatch is a sequence of records processed by one worker, err stops processing of stream
+// after output of records before it
+type recordBatch struct {
+	seq     int
+	first   int64
+	records [][]byte
+	// readErr is an error of reading of the last record
+	readErr error
+	out     []byte
+	stats   Stats
+	err     error
+}
+
+// runParallel processes batches of records by workers. Count of batches in flight is limited,
+// so slow writer stops reading. Reader and workers are stopped before return, so reader isn't used after it
+// runParallel reads records of r by reader, stopped stream doesn't wait for blocked reading of r,
+// it closes r if it's io.Closer
+func (s *streamer) runParallel(ctx context.Context, r io.Reader, reader recordSource) (Stats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// token is taken for every read batch and returned after its output
+	tokens := make(chan struct{}, 2*s.workers)
+	batches := make(chan *recordBatch)
+	results := make(chan *recordBatch)
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		s.read(ctx, reader, batches, tokens)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx, batches, results)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	defer func() {
+		cancel()
+		select {
+		case <-readDone:
+		default:
+			// reading may be blocked by input, closing unblocks it
+			if closer, ok := r.(io.Closer); ok {
+				closer.Close()
+			}
+		}
+		wg.Wait()
+	}()
+
+	var stats Stats
+	// batches which outputs wait for outputs of previous ones
+	pending := map[int]*recordBatch{}
+	next := 0
+	for {
+		var b *recordBatch
+		select {
+		case b = <-results:
+		case <-ctx.Done():
+			return stats, ctx.Err()
+		}
+		if b == nil {
+			return stats, ctx.Err()
+		}
+
+		if s.unordered {
+			if err := s.writeBatch(b, tokens, &stats); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		pending[b.seq] = b
+		for b, ok := pending[next]; ok; b, ok = pending[next] {
+			delete(pending, next)
+			next++
+			if err := s.writeBatch(b, tokens, &stats); err != nil {
+				return stats, err
+			}
+		}
+	}
+}
+
+// read splits stream into batches of records available without waiting for input
+func (s *streamer) read(ctx context.Context, reader recordSource, batches chan<- *recordBatch, tokens chan struct{}) {
+	defer close(batches)
+
+	for seq := 0; ctx.Err() == nil; seq++ {
+		select {
+		case tokens <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		num, _ := reader.position()
+		b := &recordBatch{seq: seq, first: num + 1}
+		// records are copied to one buffer as reader reuses its own one
+		var buf []byte
+		var ends []int
+		eof := false
+		for len(ends) < streamBatchSize {
+			record, err := reader.next()
+			if err != nil && err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				b.readErr = err
+				break
+			}
+
+			buf = append(buf, record...)
+			ends = append(ends, len(buf))
+			if reader.buffered() == 0 {
+				break
+			}
+		}
+
+		start := 0
+		for _, end := range ends {
+			b.records = append(b.records, buf[start:end:end])
+			start = end
+		}
+
+		if len(ends) == 0 && b.readErr == nil {
+			return
+		}
+
+		select {
+		case batches <- b:
+		case <-ctx.Done():
+			return
+		}
+		// too large and malformed records are followed by the next records
+		if eof || (b.readErr != nil && !isRecordError(b.readErr)) {
+			return
+		}
+	}
+}
+
+// work processes batches until stream is over
+func (s *streamer) work(ctx context.Context, batches <-chan *recordBatch, results chan<- *recordBatch) {
+	for {
+		var b *recordBatch
+		select {
+		case b = <-batches:
+		case <-ctx.Done():
+			return
+		}
+		if b == nil {
+			return
+		}
+
+		for i, record := range b.records {
+			if b.out, b.err = s.handle(b.first+int64(i), record, nil, b.out, &b.stats); b.err != nil {
+				break
+			}
+		}
+		if b.err == nil && b.readErr != nil {
+			num := b.first + int64(len(b.records))
+			if isRecordError(b.readErr) {
+				b.out, b.err = s.handle(num, nil, b.readErr, b.out, &b.stats)
+			} else {
+				b.err = b.readErr
+			}
+		}
+		b.records = nil
+
+		select {
+		case results <- b:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeBatch writes outputs of batch and frees its place in pipeline
+func (s *streamer) writeBatch(b *recordBatch, tokens <-chan struct{}, stats *Stats) error {
+	<-tokens
+	stats.add(b.stats)
+	if err := s.write(b.out); err != nil {
+		return err
+	}
+	return b.err
+}
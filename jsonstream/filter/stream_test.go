@@ -0,0 +1,577 @@
+package filter_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	long := `{"name": "John", "bio": "` + strings.Repeat("x", 100000) + `"}`
+	tests := []struct {
+		name     string
+		input    string
+		opts     []filter.StreamOption
+		expected string
+		stats    filter.Stats
+		err      string
+	}{
+		{
+			name:     "lines",
+			input:    "{\"name\": \"John\"}\n{\"name\": \"Alex\"}\n{\"name\": \"John\", \"id\": 2}\n",
+			expected: "{\"name\": \"John\"}\n{\"name\": \"John\", \"id\": 2}\n",
+			stats:    filter.Stats{Read: 3, Matched: 2},
+		},
+		{
+			name:     "CRLF and the last line without line feed",
+			input:    "{\"name\": \"John\"}\r\n{\"name\": \"John\", \"id\": 2}",
+			expected: "{\"name\": \"John\"}\n{\"name\": \"John\", \"id\": 2}\n",
+			stats:    filter.Stats{Read: 2, Matched: 2},
+		},
+		{
+			name:     "long line",
+			input:    long + "\n{\"name\": \"John\"}\n",
+			expected: long + "\n{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 2},
+		},
+		{
+			name:     "delimiter",
+			input:    "{\"name\": \"John\"}\x00{\"name\": \"Alex\"}\x00{\"name\": \"John\",\n\"id\": 2}",
+			opts:     []filter.StreamOption{filter.WithDelimiter(0)},
+			expected: "{\"name\": \"John\"}\x00{\"name\": \"John\",\n\"id\": 2}\x00",
+			stats:    filter.Stats{Read: 3, Matched: 2},
+		},
+		{
+			name:     "error stops stream",
+			input:    "{\"name\": \"John\"}\n{\"name\":\n{\"name\": \"John\"}\n",
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1, Errored: 1},
+			err:      "process record 2 error: parse json error: unexpected end of JSON input",
+		},
+		{
+			name:     "errors are skipped",
+			input:    "{\"name\": \"John\"}\n{\"name\":\n\n{\"name\": \"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithErrorPolicy(filter.ErrorSkip)},
+			expected: "{\"name\": \"John\"}\n{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 4, Matched: 2, Errored: 2},
+		},
+		{
+			name:     "too large record stops stream",
+			input:    "{\"name\": \"John\"}\n" + long + "\n{\"name\": \"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithMaxRecordSize(1000)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1, Errored: 1},
+			err:      "process record 2 error: maximum size is 1000 bytes: record is too large",
+		},
+		{
+			name:     "too large records are skipped",
+			input:    long + "\n{\"name\": \"John\"}\n" + long,
+			opts:     []filter.StreamOption{filter.WithMaxRecordSize(1000), filter.WithOversizePolicy(filter.OversizeSkip)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 3, Matched: 1, Skipped: 2},
+		},
+		{
+			name:     "too large record stops stream with skipped errors",
+			input:    "{\"name\": \"John\"}\n" + long + "\n{\"name\": \"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithMaxRecordSize(1000), filter.WithErrorPolicy(filter.ErrorSkip)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1, Errored: 1},
+			err:      "process record 2 error: maximum size is 1000 bytes: record is too large",
+		},
+		{
+			name:  "unknown oversize policy",
+			input: "{}\n",
+			opts:  []filter.StreamOption{filter.WithOversizePolicy("truncate")},
+			err:   "unknown oversize policy 'truncate'",
+		},
+		{
+			name:     "record of maximum size",
+			input:    "{\"name\": \"John\"}\r\n",
+			opts:     []filter.StreamOption{filter.WithMaxRecordSize(16)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 1, Matched: 1},
+		},
+		{
+			name:     "annotations",
+			input:    "{\"name\": \"John\"}\n[\"John\"]\n",
+			opts:     []filter.StreamOption{filter.WithAnnotations("_m")},
+			expected: "{\"name\": \"John\",\"_m\":[{\"expr\":\"name = John\",\"path\":\"name\",\"value\":\"John\"}]}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1},
+		},
+		{
+			name:  "explain",
+			input: "{\"name\": \"John\"}\n{\"name\":\n",
+			opts:  []filter.StreamOption{filter.WithExplain(filter.ExplainJSON)},
+			expected: "{\"element\":{\"name\":\"John\"},\"verdict\":true,\"root\":{\"expr\":\"name = John\",\"result\":true," +
+				"\"path\":\"name\",\"found\":true,\"value\":\"John\",\"coercions\":[\"compared as strings\"]}}\n" +
+				"{\"element\":\"{\\\"name\\\":\",\"verdict\":false,\"error\":\"parse json error: unexpected end of JSON input\"}\n",
+			stats: filter.Stats{Read: 2, Matched: 1, Errored: 1},
+		},
+		{
+			name:  "invalid option",
+			input: "{}\n",
+			opts:  []filter.StreamOption{filter.WithWorkers(0)},
+			err:   "count of workers must be positive",
+		},
+		{
+			name:  "explained annotations",
+			input: "{}\n",
+			opts:  []filter.StreamOption{filter.WithExplain(filter.ExplainText), filter.WithAnnotations("_m")},
+			err:   "records can't be explained and annotated at once",
+		},
+	}
+
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s, %d workers", tt.name, workers), func(t *testing.T) {
+				var out bytes.Buffer
+				opts := append([]filter.StreamOption{filter.WithWorkers(workers)}, tt.opts...)
+				stats, err := filter.Stream(context.Background(), strings.NewReader(tt.input), &out, program, opts...)
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+				} else {
+					assert.NoError(t, err)
+				}
+				assert.Equal(t, tt.expected, out.String())
+				assert.Equal(t, tt.stats, stats)
+			})
+		}
+	}
+}
+
+func TestStream_Workers(t *testing.T) {
+	var input, expected strings.Builder
+	for i := 0; i < 10000; i++ {
+		line := fmt.Sprintf(`{"id": %d, "name": "%s"}`, i, []string{"John", "Alex", "Pit"}[i%3])
+		input.WriteString(line + "\n")
+		if i%3 == 0 {
+			expected.WriteString(line + "\n")
+		}
+	}
+
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var out bytes.Buffer
+	stats, err := filter.Stream(context.Background(), strings.NewReader(input.String()), &out, program, filter.WithWorkers(8))
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), out.String())
+	assert.Equal(t, filter.Stats{Read: 10000, Matched: 3334}, stats)
+
+	out.Reset()
+	stats, err = filter.Stream(context.Background(), strings.NewReader(input.String()), &out, program,
+		filter.WithWorkers(8), filter.WithUnordered())
+	assert.NoError(t, err)
+	assert.Equal(t, sortedLines(expected.String()), sortedLines(out.String()))
+	assert.Equal(t, filter.Stats{Read: 10000, Matched: 3334}, stats)
+}
+
+func TestStream_WorkersKeepOrderOfSmallBatches(t *testing.T) {
+	var input, expected strings.Builder
+	for i := 0; i < 2000; i++ {
+		// records of different size take different time, so batches are done out of order
+		line := fmt.Sprintf(`{"id": %d, "name": "John", "tail": "%s"}`, i, strings.Repeat("x", i%7*100))
+		input.WriteString(line + "\n")
+		expected.WriteString(line + "\n")
+	}
+
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// reader gives one byte at a time, so nearly every record is a batch of its own
+	var out bytes.Buffer
+	stats, err := filter.Stream(context.Background(), iotest.OneByteReader(strings.NewReader(input.String())), &out,
+		program, filter.WithWorkers(8))
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), out.String())
+	assert.Equal(t, filter.Stats{Read: 2000, Matched: 2000}, stats)
+}
+
+func TestStream_Cancel(t *testing.T) {
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, workers := range []int{1, 4} {
+		var out bytes.Buffer
+		_, err := filter.Stream(ctx, strings.NewReader("{\"name\": \"John\"}\n"), &out, program, filter.WithWorkers(workers))
+		assert.Equal(t, context.Canceled, err)
+		assert.Empty(t, out.String())
+	}
+}
+
+func TestStream_WriteErrorStopsReading(t *testing.T) {
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	writeErr := errors.New("write error")
+	for _, workers := range []int{1, 4} {
+		reader := &endlessReader{record: "{\"name\": \"John\"}\n"}
+		_, err := filter.Stream(context.Background(), reader, failingWriter{err: writeErr}, program,
+			filter.WithWorkers(workers))
+		assert.Equal(t, writeErr, errors.Cause(err))
+
+		// read being done at return may be finished, but the next ones aren't started
+		time.Sleep(10 * time.Millisecond)
+		reads := reader.reads.Load()
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, reads, reader.reads.Load())
+	}
+}
+
+func TestStream_BlockedReading(t *testing.T) {
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	writeErr := errors.New("write error")
+	tests := []struct {
+		name   string
+		cancel bool
+		err    error
+	}{
+		{name: "cancellation", cancel: true, err: context.Canceled},
+		{name: "write error", err: writeErr},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			// the first record is written to pipe, reading of the next one is blocked until pipe is closed
+			r, w := io.Pipe()
+			go w.Write([]byte("{\"name\": \"John\"}\n"))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var out io.Writer = &bytes.Buffer{}
+			if testCase.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			} else {
+				out = failingWriter{err: writeErr}
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := filter.Stream(ctx, r, out, program, filter.WithWorkers(4))
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				assert.Equal(t, testCase.err, errors.Cause(err))
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream waits for blocked reading")
+			}
+			_, err := r.Read(make([]byte, 1))
+			assert.Equal(t, io.ErrClosedPipe, err)
+		})
+	}
+}
+
+// endlessReader repeats record and counts reads
+type endlessReader struct {
+	record string
+	reads  atomic.Int64
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	r.reads.Add(1)
+	n := 0
+	for n+len(r.record) <= len(p) {
+		n += copy(p[n:], r.record)
+	}
+	return n, nil
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func sortedLines(s string) []string {
+	lines := strings.Split(s, "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+func TestStream_InputFormats(t *testing.T) {
+	pretty := "{\n  \"name\": \"John\",\n  \"children\": [\n    {\"name\": \"Alex\"}\n  ]\n}"
+	compact := "{\"name\":\"John\",\"children\":[{\"name\":\"Alex\"}]}"
+	tests := []struct {
+		name     string
+		input    string
+		opts     []filter.StreamOption
+		expected string
+		stats    filter.Stats
+		err      string
+	}{
+		{
+			name:     "pretty-printed values",
+			input:    pretty + "\n" + pretty + "\n",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputConcat)},
+			expected: compact + "\n" + compact + "\n",
+			stats:    filter.Stats{Read: 2, Matched: 2},
+		},
+		{
+			name:     "concatenated values",
+			input:    "{\"name\": \"John\"}{\"name\": \"Alex\"}[{\"name\": \"John\"}]\"John\" 12 true\"x\\\"}\"{\"name\":\"John\"}",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputConcat)},
+			expected: "{\"name\": \"John\"}\n[{\"name\": \"John\"}]\n{\"name\":\"John\"}\n",
+			stats:    filter.Stats{Read: 8, Matched: 3},
+		},
+		{
+			name:     "malformed value stops stream",
+			input:    "{\"name\": \"John\"}\n{\n  \"name\": [\"John\"}\n}\n{\"name\": \"John\"}",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputConcat)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1, Errored: 1},
+			err:      "process record 2 error: broken at offset 36: unexpected '}', expected ']': malformed json value",
+		},
+		{
+			name: "reading is resumed after malformed values",
+			input: "{\"name\": \"John\"}\n{\n  \"name\": [\"John\"}\n}\n{\"name\": \"John\", \"id\": 2}\n" +
+				"] {\"name\": \"Alex\"}\n{\"name\": \"John\n\", \"id\": 3}\n{\"name\": \"John\", \"id\": 4}\n{\"name\": \"John\",",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputConcat), filter.WithErrorPolicy(filter.ErrorSkip)},
+			expected: "{\"name\": \"John\"}\n{\"name\": \"John\", \"id\": 2}\n{\"name\": \"John\", \"id\": 4}\n",
+			stats:    filter.Stats{Read: 7, Matched: 3, Errored: 4},
+		},
+		{
+			name:     "too large values are skipped",
+			input:    "{\"name\": \"John\", \"bio\": \"" + strings.Repeat("x", 100000) + "\"} {\"name\": \"John\"}",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputConcat), filter.WithMaxRecordSize(1000), filter.WithOversizePolicy(filter.OversizeSkip)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1, Skipped: 1},
+		},
+		{
+			name:     "json text sequence",
+			input:    "\x1e{\"name\": \"John\"}\n\x1e\x1e{\n\"name\": \"Alex\"}\n\x1e {\"name\":\n\"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputJSONSeq)},
+			expected: "{\"name\": \"John\"}\n{\"name\":\"John\"}\n",
+			stats:    filter.Stats{Read: 3, Matched: 2},
+		},
+		{
+			name:     "json text sequence without the first record separator",
+			input:    "{\"name\": \"John\"}\n\x1e{\"name\": \"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputJSONSeq), filter.WithErrorPolicy(filter.ErrorSkip)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1, Errored: 1},
+		},
+		{
+			name:     "detected json text sequence",
+			input:    "\n\x1e{\"name\": \"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputAuto)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 1, Matched: 1},
+		},
+		{
+			name:     "detected concatenated values",
+			input:    "  " + pretty + "{\"name\": \"John\"}\n",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputAuto)},
+			expected: compact + "\n{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 2},
+		},
+		{
+			name:     "array",
+			input:    " [{\"name\": \"John\"}, \"John\",12, {\"name\": \"Alex\"},\n" + pretty + "\n,[{\"name\":\"John\"}]] {\"garbage",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputArray)},
+			expected: "{\"name\": \"John\"}\n" + compact + "\n[{\"name\":\"John\"}]\n",
+			stats:    filter.Stats{Read: 6, Matched: 3},
+		},
+		{
+			name:     "empty array",
+			input:    "[ ]",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputArray)},
+			expected: "",
+			stats:    filter.Stats{},
+		},
+		{
+			name: "nested array",
+			input: "{\"meta\": {\"items\": [{\"name\": \"Alex\"}]}, \"da\\u0074a\": {\"total\": 2, \"items\": [{\"name\": \"John\"}, " +
+				"{\"name\": \"Alex\", \"bio\": \"" + strings.Repeat("x", 100000) + "\"}, {\"name\": \"John\", \"id\": 2}]}}",
+			opts: []filter.StreamOption{
+				filter.WithInputFormat(filter.InputArray), filter.WithInputPath("data.items"),
+				filter.WithMaxRecordSize(1000), filter.WithOversizePolicy(filter.OversizeSkip),
+			},
+			expected: "{\"name\": \"John\"}\n{\"name\": \"John\", \"id\": 2}\n",
+			stats:    filter.Stats{Read: 3, Matched: 2, Skipped: 1},
+		},
+		{
+			name:  "missing path",
+			input: "{\"data\": {\"total\": 2}, \"items\": []}",
+			opts:  []filter.StreamOption{filter.WithInputFormat(filter.InputArray), filter.WithInputPath("data.items")},
+			err:   "path 'data.items' isn't found: key 'items' is missing",
+		},
+		{
+			name:  "not array",
+			input: "{\"items\": {}}",
+			opts:  []filter.StreamOption{filter.WithInputFormat(filter.InputArray), filter.WithInputPath("items")},
+			err:   "array isn't found: unexpected '{' at offset 10, expected one of '['",
+		},
+		{
+			name: "malformed elements of array are skipped",
+			input: "[{\"name\": \"John\"}, {\"name\": [\"John\"}, {\"name\": \"John\", \"id\": 2}, {\"name\": \"Jo\n\"}, " +
+				"{\"name\": \"John\", \"id\": 3}, , {\"name\": \"John\", \"id\": 4}, {\"a\": {\"b\": [1}}, {\"name\": \"John\", \"id\": 5}, {\"a\": 1]",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputArray), filter.WithErrorPolicy(filter.ErrorSkip)},
+			expected: "{\"name\": \"John\"}\n{\"name\": \"John\", \"id\": 2}\n{\"name\": \"John\", \"id\": 3}\n{\"name\": \"John\", \"id\": 4}\n{\"name\": \"John\", \"id\": 5}\n",
+			stats:    filter.Stats{Read: 10, Matched: 5, Errored: 5},
+		},
+		{
+			name:     "malformed element of array stops stream",
+			input:    "[{\"name\": \"John\"}, {\"name\": [\"John\"}, {\"name\": \"John\"}]",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputArray)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1, Errored: 1},
+			err:      "process record 2 error: element 2 of array: broken at offset 35: unexpected '}', expected ']': malformed json value",
+		},
+		{
+			name:     "broken array",
+			input:    "[{\"name\": \"John\"}, {\"name\": \"John\"} {\"name\": \"John\"}]",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputArray), filter.WithErrorPolicy(filter.ErrorSkip)},
+			expected: "{\"name\": \"John\"}\n{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 2},
+			err:      "unexpected '{' at offset 36, expected one of ',]'",
+		},
+		{
+			name:     "truncated array",
+			input:    "[{\"name\": \"John\"}, {\"name\": \"John\"",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputArray)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 1, Matched: 1},
+			err:      "array is broken, element 2: unexpected end of stream at offset 34: malformed json value",
+		},
+		{
+			name:  "input path of other format",
+			input: "{}\n",
+			opts:  []filter.StreamOption{filter.WithInputPath("items")},
+			err:   "input path is used only by 'array' input format",
+		},
+		{
+			name: "truncated json texts",
+			input: "\x1e{\"name\": \"John\"}\n\x1e{\"name\": \"Jo\x1e{\"name\": \"John\", \"id\": 2}\n\x1e\"John\"\x1e\"Jo\n" +
+				"\x1e12\x1e12\n\x1etru\x1e{\"name\": \"John\", \"id\": 3}",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputJSONSeq), filter.WithErrorPolicy(filter.ErrorSkip)},
+			expected: "{\"name\": \"John\"}\n{\"name\": \"John\", \"id\": 2}\n{\"name\": \"John\", \"id\": 3}\n",
+			stats:    filter.Stats{Read: 9, Matched: 3, Errored: 4},
+		},
+		{
+			name:     "truncated json text stops stream",
+			input:    "\x1e{\"name\": \"John\"}\n\x1e[{\"name\": \"John\"}\n\x1e{}\n",
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputJSONSeq)},
+			expected: "{\"name\": \"John\"}\n",
+			stats:    filter.Stats{Read: 2, Matched: 1, Errored: 1},
+			err:      "process record 2 error: json text is truncated: malformed json value",
+		},
+		{
+			name:     "json text sequence output",
+			input:    "{\"name\": \"John\"}\n{\"name\": \"Alex\"}\n{\"name\": \"John\", \"id\": 2}",
+			opts:     []filter.StreamOption{filter.WithOutputFormat(filter.OutputJSONSeq)},
+			expected: "\x1e{\"name\": \"John\"}\n\x1e{\"name\": \"John\", \"id\": 2}\n",
+			stats:    filter.Stats{Read: 3, Matched: 2},
+		},
+		{
+			name:     "pretty-printed values of json text sequence output",
+			input:    pretty + pretty,
+			opts:     []filter.StreamOption{filter.WithInputFormat(filter.InputConcat), filter.WithOutputFormat(filter.OutputJSONSeq)},
+			expected: "\x1e" + pretty + "\n\x1e" + pretty + "\n",
+			stats:    filter.Stats{Read: 2, Matched: 2},
+		},
+		{
+			name:  "json text sequence of traces",
+			input: "{\"name\": \"John\"}\n",
+			opts:  []filter.StreamOption{filter.WithOutputFormat(filter.OutputJSONSeq), filter.WithExplain(filter.ExplainJSON)},
+			expected: "\x1e{\"element\":{\"name\":\"John\"},\"verdict\":true,\"root\":{\"expr\":\"name = John\",\"result\":true," +
+				"\"path\":\"name\",\"found\":true,\"value\":\"John\",\"coercions\":[\"compared as strings\"]}}\n",
+			stats: filter.Stats{Read: 1, Matched: 1},
+		},
+		{
+			name:  "json text sequence of text traces",
+			input: "{}\n",
+			opts:  []filter.StreamOption{filter.WithOutputFormat(filter.OutputJSONSeq), filter.WithExplain(filter.ExplainText)},
+			err:   "text traces can't be written as json text sequence",
+		},
+		{
+			name:  "unknown output format",
+			input: "{}\n",
+			opts:  []filter.StreamOption{filter.WithOutputFormat("xml")},
+			err:   "unknown output format 'xml'",
+		},
+		{
+			name:  "unknown input format",
+			input: "{}\n",
+			opts:  []filter.StreamOption{filter.WithInputFormat("xml")},
+			err:   "unknown input format 'xml'",
+		},
+	}
+
+	program, err := filter.Compile(filter.Path("name").Eq("John"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s, %d workers", tt.name, workers), func(t *testing.T) {
+				var out bytes.Buffer
+				opts := append([]filter.StreamOption{filter.WithWorkers(workers)}, tt.opts...)
+				stats, err := filter.Stream(context.Background(), strings.NewReader(tt.input), &out, program, opts...)
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+				} else {
+					assert.NoError(t, err)
+				}
+				assert.Equal(t, tt.expected, out.String())
+				assert.Equal(t, tt.stats, stats)
+			})
+		}
+	}
+}
+
+func TestRecordWriter(t *testing.T) {
+	var out bytes.Buffer
+	w, err := filter.NewRecordWriter(&out, filter.WithOutputFormat(filter.OutputJSONSeq))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.Write([]byte("{\"name\":\n\"John\"}")))
+	assert.NoError(t, w.Write([]byte("12")))
+	assert.Equal(t, "\x1e{\"name\":\n\"John\"}\n\x1e12\n", out.String())
+
+	// written sequence is read back
+	var records []string
+	for record, err := range filter.Records(context.Background(), &out, filter.WithInputFormat(filter.InputAuto)) {
+		assert.NoError(t, err)
+		records = append(records, string(record.Raw))
+	}
+	assert.Equal(t, []string{"{\"name\":\n\"John\"}", "12"}, records)
+
+	_, err = filter.NewRecordWriter(&out, filter.WithOutputFormat("xml"))
+	assert.EqualError(t, err, "unknown output format 'xml'")
+}
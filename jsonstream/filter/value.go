@@ -0,0 +1,761 @@
+package filter
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// MatchValue solves accordance of Go value to condition as if value was marshalled by encoding/json
+// and matched as element: paths go through maps, slices, arrays and exported fields of structs named
+// by json tags, fields of embedded structs are promoted, fields omitted by omitempty and omitzero are missing,
+// Marshaler and TextMarshaler implementations are used. Only values found by paths of condition are encoded,
+// so values which can't be marshalled (NaN, channels, functions) give errors only if condition reaches them
+func (p *Program) MatchValue(val interface{}) (bool, error) {
+	doc := &valueDoc{root: reflect.ValueOf(val)}
+	isOk, err := checkExpr(doc, p.expr)
+	if doc.err != nil {
+		return false, errors.Wrap(doc.err, "encode value error")
+	}
+
+	return isOk, errors.Wrap(err, "error check path")
+}
+
+// valueDoc is a Go value, values found by paths are converted to values which encoding/json decodes to interface{}
+type valueDoc struct {
+	root reflect.Value
+	// err is the first error of encoding of found values, values giving error are not found
+	err error
+}
+
+func (d *valueDoc) compare(e *CompareExpr) (bool, error) {
+	val, _ := d.lookup(e.Path)
+	return chechkValue(val, *e)
+}
+
+func (d *valueDoc) lookup(path string) (interface{}, bool) {
+	if path == selfPath {
+		return d.convert(d.root, false, 0)
+	}
+	return d.search(d.root, strings.Split(path, "."), 0)
+}
+
+func (d *valueDoc) exists(path string) bool {
+	_, found := d.lookup(path)
+	return found
+}
+
+func (d *valueDoc) anyElem(e *AnyExpr) (bool, error) {
+	val, _ := d.lookup(e.Path)
+	return anyDecoded(val, e)
+}
+
+func (d *valueDoc) decode() interface{} {
+	val, _ := d.convert(d.root, false, 0)
+	return val
+}
+
+// search finds value by keys like searchDecoded does in marshalled and decoded value
+func (d *valueDoc) search(v reflect.Value, keys []string, depth int) (interface{}, bool) {
+	quoted := false
+	for i, key := range keys {
+		if depth++; depth > maxNestingDepth {
+			return d.fail(errors.Errorf("exceeded max depth %d", maxNestingDepth))
+		}
+
+		v = indirect(v)
+		if !v.IsValid() {
+			return nil, false
+		}
+
+		info := cachedValueType(v.Type())
+		if info.marshals(v) {
+			data, err := marshalValue(v)
+			if err != nil {
+				return d.fail(err)
+			}
+			return searchDecoded(data, keys[i:])
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			f, ok := info.byName[key]
+			if !ok {
+				return nil, false
+			}
+			if v, ok = f.value(v); !ok {
+				return nil, false
+			}
+			quoted = f.quoted
+			continue
+		case reflect.Map:
+			var ok bool
+			if v, ok = d.mapValue(v, key); !ok {
+				return nil, false
+			}
+		case reflect.Slice, reflect.Array:
+			if info.bytes || (v.Kind() == reflect.Slice && v.IsNil()) {
+				return nil, false
+			}
+
+			res := []interface{}{}
+			for j := 0; j < v.Len(); j++ {
+				if val, found := d.search(v.Index(j), keys[i:], depth); found {
+					res = append(res, val)
+				}
+			}
+			if len(res) == 0 {
+				return nil, false
+			}
+			return res, true
+		default:
+			return nil, false
+		}
+		quoted = false
+	}
+
+	return d.convert(v, quoted, depth)
+}
+
+// mapValue finds value of map by key as it's written by encoding/json
+func (d *valueDoc) mapValue(v reflect.Value, key string) (reflect.Value, bool) {
+	if v.IsNil() {
+		return reflect.Value{}, false
+	}
+
+	keyType := v.Type().Key()
+	if keyType.Kind() == reflect.String && !strings.ContainsRune(key, utf8.RuneError) {
+		val := v.MapIndex(reflect.ValueOf(key).Convert(keyType))
+		return val, val.IsValid()
+	}
+
+	// keys are sorted by encoding/json, the last of keys equal after replacement of invalid UTF-8 is decoded
+	var found reflect.Value
+	var foundName string
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := mapKeyName(iter.Key())
+		if err != nil {
+			d.fail(err)
+			return reflect.Value{}, false
+		}
+		if replaceInvalidUTF8(name) == key && (!found.IsValid() || name > foundName) {
+			found, foundName = iter.Value(), name
+		}
+	}
+	return found, found.IsValid()
+}
+
+// convert converts value to maps, slices and primitive values the same way as marshalling and decoding does.
+// Quoted values are fields with ",string" option
+func (d *valueDoc) convert(v reflect.Value, quoted bool, depth int) (interface{}, bool) {
+	val, err := convertValue(v, quoted, depth)
+	if err != nil {
+		return d.fail(err)
+	}
+	return val, true
+}
+
+func (d *valueDoc) fail(err error) (interface{}, bool) {
+	if d.err == nil {
+		d.err = err
+	}
+	return nil, false
+}
+
+func convertValue(v reflect.Value, quoted bool, depth int) (interface{}, error) {
+	if depth++; depth > maxNestingDepth {
+		return nil, errors.Errorf("exceeded max depth %d", maxNestingDepth)
+	}
+
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	info := cachedValueType(v.Type())
+	if info.marshals(v) {
+		return marshalValue(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if quoted {
+			return strconv.FormatBool(v.Bool()), nil
+		}
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if quoted {
+			return strconv.FormatInt(v.Int(), 10), nil
+		}
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if quoted {
+			return strconv.FormatUint(v.Uint(), 10), nil
+		}
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return convertFloat(v, quoted)
+	case reflect.String:
+		return convertString(v, quoted)
+	case reflect.Struct:
+		res := make(map[string]interface{}, len(info.fields))
+		for _, f := range info.fields {
+			fv, ok := f.value(v)
+			if !ok {
+				continue
+			}
+			val, err := convertValue(fv, f.quoted, depth)
+			if err != nil {
+				return nil, err
+			}
+			res[f.name] = val
+		}
+		return res, nil
+	case reflect.Map:
+		return convertMap(v, depth)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if info.bytes {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+
+		res := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			val, err := convertValue(v.Index(i), false, depth)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, val)
+		}
+		return res, nil
+	default:
+		return nil, &json.UnsupportedTypeError{Type: v.Type()}
+	}
+}
+
+func convertFloat(v reflect.Value, quoted bool) (interface{}, error) {
+	bits := v.Type().Bits()
+	f := v.Float()
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, &json.UnsupportedValueError{Value: v, Str: strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+
+	if quoted {
+		return formatJSONFloat(f, bits), nil
+	}
+	if bits == 32 {
+		// float32 is written with precision of float32 and read as float64
+		return strconv.ParseFloat(strconv.FormatFloat(f, 'g', -1, 32), 64)
+	}
+	return f, nil
+}
+
+// formatJSONFloat formats number like encoding/json does
+func formatJSONFloat(f float64, bits int) string {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+	if format == 'e' {
+		// exponent is written without leading zero: e-9 instead of e-09
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return string(b)
+}
+
+var numberType = reflect.TypeOf(json.Number(""))
+
+func convertString(v reflect.Value, quoted bool) (interface{}, error) {
+	if v.Type() == numberType {
+		num := v.String()
+		if num == "" {
+			num = "0"
+		}
+		if end, ok := validNumber([]byte(num), 0); !ok || end != len(num) {
+			return nil, errors.Errorf("json: invalid number literal %q", num)
+		}
+		if quoted {
+			return num, nil
+		}
+		return strconv.ParseFloat(num, 64)
+	}
+
+	if quoted {
+		// string is written as json string inside of json string
+		raw, err := json.Marshal(v.String())
+		return string(raw), err
+	}
+	return replaceInvalidUTF8(v.String()), nil
+}
+
+// replaceInvalidUTF8 replaces every byte of invalid UTF-8 with replacement character like encoding/json does
+func replaceInvalidUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteRune(utf8.RuneError)
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+func convertMap(v reflect.Value, depth int) (interface{}, error) {
+	if v.IsNil() {
+		return nil, nil
+	}
+
+	// keys are sorted by encoding/json, the last of keys equal after replacement of invalid UTF-8 is decoded
+	names := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := mapKeyName(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		values[name] = iter.Value()
+	}
+	sort.Strings(names)
+
+	res := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		val, err := convertValue(values[name], false, depth)
+		if err != nil {
+			return nil, err
+		}
+		res[replaceInvalidUTF8(name)] = val
+	}
+	return res, nil
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// validMapKey checks that keys of type are supported by encoding of maps by reflection
+func validMapKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return t.Implements(textMarshalerType)
+	}
+}
+
+// mapKeyName returns key of map as it's written by encoding/json before replacement of invalid UTF-8
+func mapKeyName(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if k.Type().Implements(textMarshalerType) && k.CanInterface() {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", &json.UnsupportedTypeError{Type: k.Type()}
+	}
+}
+
+// indirect dereferences pointers and interfaces, nil gives invalid value
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// marshalValue encodes value by its Marshaler or TextMarshaler implementation and decodes result
+func marshalValue(v reflect.Value) (interface{}, error) {
+	m := v.Interface()
+	if v.CanAddr() {
+		m = v.Addr().Interface()
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+var (
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	isZeroerType  = reflect.TypeOf((*interface{ IsZero() bool })(nil)).Elem()
+)
+
+// valueType describes how encoding/json writes values of type
+type valueType struct {
+	// marshaler is set for types implementing Marshaler or TextMarshaler and types which are encoded
+	// by encoding/json itself, addrMarshaler is set if only pointers to type implement Marshaler or TextMarshaler
+	marshaler     bool
+	addrMarshaler bool
+	// bytes is set for slices of bytes written as base64 strings
+	bytes bool
+	// fields of struct in order of declaration and by name
+	fields []valueField
+	byName map[string]*valueField
+}
+
+// valueField is a field of struct written by encoding/json
+type valueField struct {
+	name      string
+	index     []int
+	tagged    bool
+	quoted    bool
+	omitEmpty bool
+	omitZero  bool
+	isZero    func(v reflect.Value) bool
+}
+
+// valueTypeCache keeps descriptions of types to avoid inspection of struct fields for every value
+var valueTypeCache sync.Map
+
+func cachedValueType(t reflect.Type) *valueType {
+	if cached, ok := valueTypeCache.Load(t); ok {
+		if info, ok := cached.(*valueType); ok {
+			return info
+		}
+	}
+
+	info := newValueType(t)
+	valueTypeCache.Store(t, info)
+	return info
+}
+
+func newValueType(t reflect.Type) *valueType {
+	info := &valueType{
+		marshaler: t.Implements(marshalerType) || t.Implements(textMarshalerType),
+	}
+	if t.Kind() != reflect.Ptr {
+		ptr := reflect.PtrTo(t)
+		info.addrMarshaler = ptr.Implements(marshalerType) || ptr.Implements(textMarshalerType)
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		info.marshaler = info.marshaler || !validMapKey(t.Key())
+	case reflect.Complex64, reflect.Complex128, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		info.marshaler = true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			ptr := reflect.PtrTo(t.Elem())
+			info.bytes = !ptr.Implements(marshalerType) && !ptr.Implements(textMarshalerType)
+		}
+	case reflect.Struct:
+		info.fields = structFields(t)
+		info.byName = make(map[string]*valueField, len(info.fields))
+		for i := range info.fields {
+			info.byName[info.fields[i].name] = &info.fields[i]
+		}
+	}
+
+	return info
+}
+
+// marshals checks that value is written by its Marshaler or TextMarshaler implementation
+func (info *valueType) marshals(v reflect.Value) bool {
+	if !v.CanInterface() {
+		return false
+	}
+	return info.marshaler || (info.addrMarshaler && v.CanAddr())
+}
+
+// value returns value of field in struct, it's not found if field is omitted or embedded pointer is nil
+func (f *valueField) value(v reflect.Value) (reflect.Value, bool) {
+	for _, i := range f.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+
+	if f.omitEmpty && isEmptyValue(v) {
+		return reflect.Value{}, false
+	}
+	if f.omitZero && ((f.isZero == nil && v.IsZero()) || (f.isZero != nil && f.isZero(v))) {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+// structFields lists fields of struct written by encoding/json: fields of embedded structs are promoted
+// by rules of Go modified by json tags, conflicting fields are skipped
+func structFields(t reflect.Type) []valueField {
+	var fields []valueField
+
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	next := []embedded{{typ: t}}
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count := map[reflect.Type]int{}
+		for _, e := range current {
+			count[e.typ]++
+		}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				if !fieldVisible(sf) {
+					continue
+				}
+
+				name, opts := parseTag(sf.Tag.Get("json"))
+				index := append(append([]int{}, e.index...), i)
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{typ: ft, index: index})
+					continue
+				}
+
+				f := valueField{
+					name:      name,
+					index:     index,
+					tagged:    name != "",
+					quoted:    hasTagOption(opts, "string") && quotable(ft),
+					omitEmpty: hasTagOption(opts, "omitempty"),
+					omitZero:  hasTagOption(opts, "omitzero"),
+				}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				if f.omitZero {
+					f.isZero = zeroChecker(sf.Type)
+				}
+
+				fields = append(fields, f)
+				if count[e.typ] > 1 {
+					// struct embedded several times at the same depth gives conflicting fields
+					fields = append(fields, f)
+				}
+			}
+		}
+	}
+
+	return dominantFields(fields)
+}
+
+// fieldVisible checks that field is written by encoding/json: exported fields and embedded structs
+// which may have exported fields
+func fieldVisible(sf reflect.StructField) bool {
+	if sf.Tag.Get("json") == "-" {
+		return false
+	}
+	if !sf.Anonymous {
+		return sf.PkgPath == ""
+	}
+
+	t := sf.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return sf.PkgPath == "" || t.Kind() == reflect.Struct
+}
+
+// dominantFields keeps the shallowest field of every name preferring tagged ones,
+// fields of the same name, depth and presence of tag hide each other
+func dominantFields(fields []valueField) []valueField {
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if len(a.index) != len(b.index) {
+			return len(a.index) < len(b.index)
+		}
+		if a.tagged != b.tagged {
+			return a.tagged
+		}
+		return lessIndex(a.index, b.index)
+	})
+
+	res := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if j-i == 1 || len(fields[i].index) != len(fields[i+1].index) || fields[i].tagged != fields[i+1].tagged {
+			res = append(res, fields[i])
+		}
+		i = j
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return lessIndex(res[i].index, res[j].index)
+	})
+	return res
+}
+
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// parseTag splits json tag into name and options, invalid names are ignored like encoding/json does
+func parseTag(tag string) (string, string) {
+	name, opts := tag, ""
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		name, opts = tag[:i], tag[i+1:]
+	}
+	if !validTagName(name) {
+		name = ""
+	}
+	return name, opts
+}
+
+func validTagName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c) && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTagOption(opts string, option string) bool {
+	for opts != "" {
+		var current string
+		if i := strings.IndexByte(opts, ','); i >= 0 {
+			current, opts = opts[:i], opts[i+1:]
+		} else {
+			current, opts = opts, ""
+		}
+		if current == option {
+			return true
+		}
+	}
+	return false
+}
+
+// quotable checks that ",string" option is applied to values of type
+func quotable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+// zeroChecker returns IsZero method of type for omitzero option, nil means reflect.Value.IsZero is used
+func zeroChecker(t reflect.Type) func(v reflect.Value) bool {
+	type isZeroer interface {
+		IsZero() bool
+	}
+
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.IsNil() || (v.Elem().Kind() == reflect.Ptr && v.Elem().IsNil()) || v.Interface().(isZeroer).IsZero()
+		}
+	case t.Kind() == reflect.Ptr && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.IsNil() || v.Interface().(isZeroer).IsZero()
+		}
+	case t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case reflect.PtrTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				addressable := reflect.New(v.Type()).Elem()
+				addressable.Set(v)
+				v = addressable
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	default:
+		return nil
+	}
+}
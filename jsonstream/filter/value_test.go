@@ -0,0 +1,212 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+type testJob struct {
+	Company string `json:"company"`
+	Salary  int    `json:"salary,omitempty"`
+}
+
+type testChild struct {
+	Name string  `json:"name"`
+	Age  float32 `json:"age"`
+}
+
+type testAudit struct {
+	Created string `json:"created"`
+	Name    string `json:"audit_name"`
+	ID      int
+}
+
+type testMeta struct {
+	ID   int    `json:"id"`
+	Tags string `json:"tags"`
+}
+
+type testLevel int
+
+func (l testLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int{"level": int(l)})
+}
+
+type testColor struct {
+	name string
+}
+
+func (c *testColor) MarshalText() ([]byte, error) {
+	return []byte("color:" + c.name), nil
+}
+
+type testKey int
+
+func (k testKey) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("k", int(k))), nil
+}
+
+type testFailing struct{}
+
+func (testFailing) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("failed")
+}
+
+type testPerson struct {
+	testAudit
+	*testMeta
+	Name     string                 `json:"name"`
+	Nick     string                 `json:"nick,omitempty"`
+	Email    *string                `json:"email,omitzero"`
+	Weight   float64                `json:"weight,string"`
+	Active   bool                   `json:",string"`
+	Code     json.Number            `json:"code"`
+	Children []testChild            `json:"children"`
+	Job      *testJob               `json:"job"`
+	Extra    map[string]interface{} `json:"extra"`
+	Scores   map[int]float64        `json:"scores"`
+	Keys     map[testKey]string     `json:"keys"`
+	Level    testLevel              `json:"level"`
+	Colors   []testColor            `json:"colors"`
+	Avatar   []byte                 `json:"avatar"`
+	Matrix   [2][2]uint8            `json:"matrix"`
+	Secret   string                 `json:"-"`
+	Any      interface{}            `json:"any"`
+	private  string
+}
+
+func TestProgram_MatchValue_SameAsMarshalled(t *testing.T) {
+	email := "john@gmail.com"
+	values := []interface{}{
+		testPerson{
+			testAudit: testAudit{Created: "2020", Name: "audit", ID: 3},
+			testMeta:  &testMeta{ID: 7, Tags: "a,b"},
+			Name:      "John",
+			Email:     &email,
+			Weight:    80.5,
+			Active:    true,
+			Code:      "1e3",
+			Children:  []testChild{{Name: "Alex", Age: 10.1}, {Name: "Pit", Age: 5}},
+			Job:       &testJob{Company: "Some firm", Salary: 100},
+			Extra:     map[string]interface{}{"flag": true, "list": []int{1, 2}, "n\xffme": "x", "n\xfeme": "y"},
+			Scores:    map[int]float64{1: 0.5, -2: 7},
+			Keys:      map[testKey]string{2: "two"},
+			Level:     3,
+			Colors:    []testColor{{name: "red"}},
+			Avatar:    []byte("img"),
+			Matrix:    [2][2]uint8{{1, 2}, {3, 4}},
+			Secret:    "secret",
+			Any:       []interface{}{"x", testJob{Company: "Other"}},
+			private:   "private",
+		},
+		&testPerson{Name: "J\xffhn", Nick: "Jo", Code: "", Children: []testChild{}},
+		map[string]interface{}{"name": "John", "id": uint64(1) << 60, "job": &testJob{}, "children": nil, "ratios": map[float64]string{2: "two"}},
+		[]interface{}{map[string]string{"name": "John"}, testChild{Name: "Alex"}, "x"},
+		"John",
+		int8(17),
+		nil,
+	}
+	exprs := []string{
+		"name = John",
+		"name ~ ^J",
+		"nick = Jo",
+		"exists(nick)",
+		"exists(email) and email ~ gmail",
+		"weight = '80.5'",
+		"Active = 'true'",
+		"code = 1000 or code = 0",
+		"children.age > 10 and children.age < 10.2",
+		"any(children, age < 6 or name = Alex)",
+		"len(children) = 2",
+		"job.company = 'Some firm' and job.salary = 100",
+		"exists(job.salary)",
+		"extra.flag = true and extra.list = 2",
+		"extra.n�me = y",
+		"scores.1 = 0.5 and scores.-2 > 6",
+		"keys.kk = two",
+		"level.level = 3",
+		"colors = 'color:red'",
+		"avatar = aW1n",
+		"matrix = 4",
+		"exists(Secret) or exists(private)",
+		"any.company = Other",
+		"created = 2020 and audit_name = audit and ID = 3",
+		"id = 7 and tags = 'a,b'",
+		"id > 1e17",
+		"ratios.2 = two",
+		"jsonpath('$.children[?@.age > 5]')",
+		"@ = John or @ > 10",
+		"exists(@)",
+		"len(@) = 3",
+	}
+
+	for _, expr := range exprs {
+		cond, err := filter.NewConditionFromStr(expr)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+		program, err := filter.Compile(*cond)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+
+		for _, val := range values {
+			elem, err := json.Marshal(val)
+			if !assert.NoError(t, err) {
+				continue
+			}
+
+			expected, expectedErr := program.Match(elem)
+			isOk, err := program.MatchValue(val)
+			assert.Equal(t, expected, isOk, "%s on %s", expr, elem)
+			if expectedErr != nil && assert.Error(t, err, "%s on %s", expr, elem) {
+				assert.Equal(t, expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err, "%s on %s", expr, elem)
+			}
+		}
+	}
+}
+
+func TestProgram_MatchValue_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cond filter.Condition
+		val  interface{}
+	}{
+		{"NaN", filter.Path("a").Eq(1), map[string]float64{"a": math.NaN()}},
+		{"channel", filter.Path("a").Exists(), map[string]interface{}{"a": make(chan int)}},
+		{"marshaler", filter.Path("a.b").Eq(1), map[string]interface{}{"a": testFailing{}}},
+		{"number", filter.Path("a").Eq(1), map[string]interface{}{"a": json.Number("x")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := filter.Compile(tt.cond)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = json.Marshal(tt.val)
+			assert.Error(t, err)
+
+			isOk, err := program.MatchValue(tt.val)
+			assert.Error(t, err)
+			assert.False(t, isOk)
+		})
+	}
+
+	// values which are not reached by paths of condition are not encoded
+	program, err := filter.Compile(filter.Path("b").Eq(1))
+	if assert.NoError(t, err) {
+		isOk, err := program.MatchValue(map[string]interface{}{"a": math.NaN(), "b": 1})
+		assert.NoError(t, err)
+		assert.True(t, isOk)
+	}
+}
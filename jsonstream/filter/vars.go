@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnboundVariable appears when condition refers to a variable that wasn't passed to parser
+var ErrUnboundVariable = errors.New("unbound variable")
+
+// ParseOption customizes parsing of condition
+type ParseOption func(opts *parseOptions)
+
+type parseOptions struct {
+	vars map[string]interface{}
+}
+
+// WithVars binds values to variables ($name) used in condition.
+// Allowed value types are strings, booleans and numbers, they are checked against operators and functions
+// of comparisons: booleans are compared only by = and !=, regular expressions and lower/upper take strings,
+// len takes numbers. Comparisons with variables are typed: they are satisfied only by values of the same type
+// (string '250' doesn't match number 250)
+func WithVars(vars map[string]interface{}) ParseOption {
+	return func(opts *parseOptions) {
+		opts.vars = vars
+	}
+}
+
+// bindVariable type-checks variable value against function and operator and casts it to condition value
+// of comparison type
+func bindVariable(name string, fn Function, op Operator, vars map[string]interface{}) (string, ValueType, error) {
+	val, ok := vars[name]
+	if !ok {
+		return "", TypeAny, errors.Wrapf(ErrUnboundVariable, "variable $%s", name)
+	}
+
+	res, typ, err := formatValue(val)
+	if err != nil {
+		return "", TypeAny, errors.Wrapf(err, "variable $%s", name)
+	}
+
+	if err := checkVariable(typ, fn, op); err != nil {
+		return "", TypeAny, errors.Wrapf(err, "variable $%s is %s", name, typ.String())
+	}
+
+	return res, typ, nil
+}
+
+// checkVariable checks that value of type may be compared by operator with result of function
+func checkVariable(typ ValueType, fn Function, op Operator) error {
+	switch {
+	case typ == TypeBool && op != OpEq && op != OpNotEq:
+		return errors.Wrapf(ErrUnsupportedOperator, "passed %s", op.String())
+	case typ != TypeString && isLikeOperator(op):
+		return errors.Wrapf(ErrUnsupportedOperator, "passed %s", op.String())
+	case fn == FuncLen && typ != TypeNumber:
+		return errors.Wrapf(ErrUnsupportedType, "%s() gives number", fn.String())
+	case (fn == FuncLower || fn == FuncUpper) && typ != TypeString:
+		return errors.Wrapf(ErrUnsupportedType, "%s() gives string", fn.String())
+	default:
+		return nil
+	}
+}
+
+// formatValue casts go value to string representation of condition value
+func formatValue(val interface{}) (string, ValueType, error) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), TypeString, nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), TypeBool, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), TypeNumber, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), TypeNumber, nil
+	case reflect.Float32, reflect.Float64:
+		return formatFloat(rv.Float(), rv.Type().Bits())
+	default:
+		return "", TypeAny, errors.Wrapf(ErrUnsupportedType, "passed %T", val)
+	}
+}
+
+func formatFloat(val float64, bitSize int) (string, ValueType, error) {
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return "", TypeAny, errors.Wrapf(ErrUnsupportedType, "passed non-finite number %v", val)
+	}
+
+	return strconv.FormatFloat(val, 'g', -1, bitSize), TypeNumber, nil
+}
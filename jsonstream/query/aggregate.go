@@ -0,0 +1,142 @@
+package query
+
+import (
+	"math"
+	"sort"
+)
+
+// Aggregate functions, percentiles are named p1..p100
+const (
+	fnCount = "count"
+	fnSum   = "sum"
+	fnAvg   = "avg"
+	fnMin   = "min"
+	fnMax   = "max"
+)
+
+var aggregateFunctions = []string{fnCount, fnSum, fnAvg, fnMin, fnMax}
+
+// aggregator accumulates values of column over group of elements
+type aggregator interface {
+	add(val interface{})
+	result() interface{}
+}
+
+func newAggregator(col column) aggregator {
+	switch {
+	case col.fn == fnCount && col.path == AllColumns:
+		return &countAggregator{countNull: true}
+	case col.fn == fnCount:
+		return &countAggregator{}
+	case col.fn == fnSum:
+		return &sumAggregator{}
+	case col.fn == fnAvg:
+		return &sumAggregator{isAvg: true}
+	case col.fn == fnMin:
+		return &extremumAggregator{isMax: false}
+	case col.fn == fnMax:
+		return &extremumAggregator{isMax: true}
+	default:
+		return &percentileAggregator{percent: col.percent}
+	}
+}
+
+// countAggregator counts elements having value (count(path)) or all elements (count(*))
+type countAggregator struct {
+	countNull bool
+	count     int
+}
+
+func (a *countAggregator) add(val interface{}) {
+	if val != nil || a.countNull {
+		a.count++
+	}
+}
+
+func (a *countAggregator) result() interface{} {
+	return a.count
+}
+
+type sumAggregator struct {
+	isAvg bool
+	sum   float64
+	count int
+}
+
+func (a *sumAggregator) add(val interface{}) {
+	forEachNumber(val, func(num float64) {
+		a.sum += num
+		a.count++
+	})
+}
+
+func (a *sumAggregator) result() interface{} {
+	switch {
+	case a.count == 0:
+		return nil
+	case a.isAvg:
+		return a.sum / float64(a.count)
+	default:
+		return a.sum
+	}
+}
+
+type extremumAggregator struct {
+	isMax bool
+	val   float64
+	count int
+}
+
+func (a *extremumAggregator) add(val interface{}) {
+	forEachNumber(val, func(num float64) {
+		if a.count == 0 || (a.isMax && num > a.val) || (!a.isMax && num < a.val) {
+			a.val = num
+		}
+		a.count++
+	})
+}
+
+func (a *extremumAggregator) result() interface{} {
+	if a.count == 0 {
+		return nil
+	}
+	return a.val
+}
+
+// percentileAggregator keeps all values to calculate exact percentile by nearest-rank method
+type percentileAggregator struct {
+	percent float64
+	values  []float64
+}
+
+func (a *percentileAggregator) add(val interface{}) {
+	forEachNumber(val, func(num float64) {
+		a.values = append(a.values, num)
+	})
+}
+
+func (a *percentileAggregator) result() interface{} {
+	if len(a.values) == 0 {
+		return nil
+	}
+
+	sort.Float64s(a.values)
+	rank := int(math.Ceil(a.percent / 100 * float64(len(a.values))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return a.values[rank-1]
+}
+
+// forEachNumber calls fn for number or every number of array (path may go through arrays)
+func forEachNumber(val interface{}, fn func(num float64)) {
+	switch v := val.(type) {
+	case float64:
+		fn(v)
+	case []interface{}:
+		for _, elem := range v {
+			forEachNumber(elem, fn)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package query
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+)
+
+// Row is a result of query, values are placed in order of columns.
+// Element selected by "*" is kept as json.RawMessage
+type Row []interface{}
+
+// Executor runs query over elements of stream. Projection query gives row for every satisfying element,
+// rows of aggregate query are given at the end of stream
+type Executor struct {
+	query  *Query
+	rows   int
+	groups map[string]*group
+	order  []*group
+}
+
+// group accumulates aggregates of elements having the same values of GROUP BY paths
+type group struct {
+	keys        map[string]interface{}
+	aggregators []aggregator
+}
+
+// NewExecutor constructs Executor of query
+func (q *Query) NewExecutor() *Executor {
+	return &Executor{
+		query:  q,
+		groups: map[string]*group{},
+	}
+}
+
+// Process handles element of stream. Row is returned for projection query if element satisfies WHERE clause
+func (e *Executor) Process(elem []byte) (Row, bool, error) {
+	var data interface{}
+	if err := json.Unmarshal(elem, &data); err != nil {
+		return nil, false, errors.Wrap(err, "parse json error")
+	}
+
+	if e.query.where != nil {
+		isOk, err := e.query.where.MatchDecoded(data)
+		if err != nil || !isOk {
+			return nil, false, err
+		}
+	}
+
+	if e.query.IsAggregate() {
+		return nil, false, e.aggregate(data)
+	}
+
+	row := make(Row, 0, len(e.query.columns))
+	for _, col := range e.query.columns {
+		if col.path == AllColumns {
+			row = append(row, json.RawMessage(elem))
+			continue
+		}
+		val, _ := filter.LookupDecoded(data, col.path)
+		row = append(row, val)
+	}
+	e.rows++
+
+	return row, true, nil
+}
+
+// Done reports that LIMIT of projection query is reached, so the rest of stream may be skipped
+func (e *Executor) Done() bool {
+	return e.query.limit > 0 && e.rows >= e.query.limit
+}
+
+// Finish returns rows of aggregate query in order of first appearance of groups
+func (e *Executor) Finish() []Row {
+	if !e.query.IsAggregate() {
+		return nil
+	}
+
+	// aggregate query without GROUP BY gives a row even for empty stream
+	if len(e.query.groupBy) == 0 && len(e.order) == 0 {
+		e.order = append(e.order, e.newGroup(nil))
+	}
+
+	var res []Row
+	for _, grp := range e.order {
+		if e.query.limit > 0 && len(res) >= e.query.limit {
+			break
+		}
+
+		row := make(Row, 0, len(e.query.columns))
+		for i, col := range e.query.columns {
+			if col.isAggregate() {
+				row = append(row, grp.aggregators[i].result())
+			} else {
+				row = append(row, grp.keys[col.path])
+			}
+		}
+		res = append(res, row)
+	}
+
+	return res
+}
+
+func (e *Executor) aggregate(data interface{}) error {
+	keys := make(map[string]interface{}, len(e.query.groupBy))
+	keyValues := make([]interface{}, 0, len(e.query.groupBy))
+	for _, path := range e.query.groupBy {
+		val, _ := filter.LookupDecoded(data, path)
+		keys[path] = val
+		keyValues = append(keyValues, val)
+	}
+
+	key, err := json.Marshal(keyValues)
+	if err != nil {
+		return errors.Wrap(err, "build group key error")
+	}
+
+	grp, ok := e.groups[string(key)]
+	if !ok {
+		grp = e.newGroup(keys)
+		e.groups[string(key)] = grp
+		e.order = append(e.order, grp)
+	}
+
+	for i, col := range e.query.columns {
+		if !col.isAggregate() {
+			continue
+		}
+		if col.path == AllColumns {
+			grp.aggregators[i].add(nil)
+			continue
+		}
+		val, _ := filter.LookupDecoded(data, col.path)
+		grp.aggregators[i].add(val)
+	}
+
+	return nil
+}
+
+func (e *Executor) newGroup(keys map[string]interface{}) *group {
+	grp := &group{keys: keys, aggregators: make([]aggregator, len(e.query.columns))}
+	for i, col := range e.query.columns {
+		if col.isAggregate() {
+			grp.aggregators[i] = newAggregator(col)
+		}
+	}
+	return grp
+}
@@ -0,0 +1,150 @@
+package query_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shnellpavel/json-stream/jsonstream/query"
+	"github.com/stretchr/testify/assert"
+)
+
+var testStream = []string{
+	`{"service": "api", "status": 500, "latency": 120, "tags": ["a", "b"]}`,
+	`{"service": "api", "status": 503, "latency": 300}`,
+	`{"service": "web", "status": 502, "latency": 80}`,
+	`{"service": "web", "status": 200, "latency": 10}`,
+	`{"service": "api", "status": 200, "latency": 5}`,
+}
+
+// runQuery runs query over test stream and writes result in NDJSON
+func runQuery(t *testing.T, input string) string {
+	parsed, err := query.Parse(input)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+
+	var out bytes.Buffer
+	writer := query.NewNDJSONWriter(&out, parsed.Columns())
+	executor := parsed.NewExecutor()
+	for _, elem := range testStream {
+		if executor.Done() {
+			break
+		}
+
+		row, isOk, err := executor.Process([]byte(elem))
+		assert.NoError(t, err)
+		if isOk {
+			assert.NoError(t, writer.WriteRow(row))
+		}
+	}
+
+	for _, row := range executor.Finish() {
+		assert.NoError(t, writer.WriteRow(row))
+	}
+	assert.NoError(t, writer.Flush())
+
+	return out.String()
+}
+
+func TestExecutor(t *testing.T) {
+	cases := []struct {
+		name           string
+		input          string
+		expectedOutput []string
+	}{
+		{
+			name:  "Projection",
+			input: "SELECT service, tags, absent AS missing WHERE status = 500",
+			expectedOutput: []string{
+				`{"service":"api","tags":["a","b"],"missing":null}`,
+			},
+		},
+		{
+			name:  "All columns with limit",
+			input: "SELECT * WHERE status >= 500 LIMIT 2",
+			expectedOutput: []string{
+				testStream[0],
+				testStream[1],
+			},
+		},
+		{
+			name:  "Group by",
+			input: "SELECT service, count(*), p95(latency), avg(latency) WHERE status >= 500 GROUP BY service",
+			expectedOutput: []string{
+				`{"service":"api","count(*)":2,"p95(latency)":300,"avg(latency)":210}`,
+				`{"service":"web","count(*)":1,"p95(latency)":80,"avg(latency)":80}`,
+			},
+		},
+		{
+			name:  "Aggregates of whole stream",
+			input: "SELECT count(*) AS total, count(tags), sum(latency), min(latency), max(latency), p50(latency)",
+			expectedOutput: []string{
+				`{"total":5,"count(tags)":1,"sum(latency)":515,"min(latency)":5,"max(latency)":300,"p50(latency)":80}`,
+			},
+		},
+		{
+			name:  "Aggregates of empty result",
+			input: "SELECT count(*), max(latency) WHERE status > 1000",
+			expectedOutput: []string{
+				`{"count(*)":0,"max(latency)":null}`,
+			},
+		},
+		{
+			name:           "Group by of empty result",
+			input:          "SELECT status, count(*) WHERE status > 1000 GROUP BY status",
+			expectedOutput: nil,
+		},
+		{
+			name:  "Limit of groups",
+			input: "SELECT status GROUP BY status LIMIT 2",
+			expectedOutput: []string{
+				`{"status":500}`,
+				`{"status":503}`,
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			expected := ""
+			if len(testCase.expectedOutput) > 0 {
+				expected = strings.Join(testCase.expectedOutput, "\n") + "\n"
+			}
+			assert.Equal(t, expected, runQuery(t, testCase.input))
+		})
+	}
+}
+
+func TestNDJSONWriter_MultilineElem(t *testing.T) {
+	var out bytes.Buffer
+	writer := query.NewNDJSONWriter(&out, []string{query.AllColumns})
+	assert.NoError(t, writer.WriteRow(query.Row{json.RawMessage("{\n  \"name\": \"John\",\n  \"tags\": [\"a\"]\n}")}))
+	assert.NoError(t, writer.WriteRow(query.Row{json.RawMessage(`{"name": "Alex"}`)}))
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, "{\"name\":\"John\",\"tags\":[\"a\"]}\n{\"name\": \"Alex\"}\n", out.String())
+}
+
+func TestExecutor_InvalidElem(t *testing.T) {
+	parsed, err := query.Parse("SELECT id")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, isOk, err := parsed.NewExecutor().Process([]byte(`{"id": 1`))
+	assert.Error(t, err)
+	assert.False(t, isOk)
+}
+
+func TestTableWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := query.NewTableWriter(&out, []string{"service", "count(*)", "tags"})
+	assert.NoError(t, writer.WriteRow(query.Row{"api", 2, []interface{}{"a", "b"}}))
+	assert.NoError(t, writer.WriteRow(query.Row{"web\tv2", 10, nil}))
+	assert.NoError(t, writer.Flush())
+
+	assert.Equal(t, "service  count(*)  tags\n"+
+		"api      2         [\"a\",\"b\"]\n"+
+		"web v2   10        null\n", out.String())
+}
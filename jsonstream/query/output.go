@@ -0,0 +1,134 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// RowWriter writes rows of query result
+type RowWriter interface {
+	WriteRow(row Row) error
+	// Flush writes buffered rows, must be called after the last row
+	Flush() error
+}
+
+// NDJSONWriter writes every row as json object on separate line. Element selected by "*" is written as is,
+// elements spanning several lines are compacted
+type NDJSONWriter struct {
+	w       io.Writer
+	columns []string
+	buf     bytes.Buffer
+}
+
+// NewNDJSONWriter constructs NDJSONWriter
+func NewNDJSONWriter(w io.Writer, columns []string) *NDJSONWriter {
+	return &NDJSONWriter{w: w, columns: columns}
+}
+
+// WriteRow implements RowWriter
+func (w *NDJSONWriter) WriteRow(row Row) error {
+	w.buf.Reset()
+	if len(w.columns) == 1 && w.columns[0] == AllColumns {
+		w.writeElem(row[0].(json.RawMessage))
+	} else if err := w.writeObject(row); err != nil {
+		return err
+	}
+	w.buf.WriteByte('\n')
+
+	_, err := w.w.Write(w.buf.Bytes())
+	return errors.Wrap(err, "write row error")
+}
+
+// writeElem writes element compacting it when it spans several lines
+func (w *NDJSONWriter) writeElem(elem json.RawMessage) {
+	if bytes.IndexByte(elem, '\n') < 0 || json.Compact(&w.buf, elem) != nil {
+		w.buf.Reset()
+		w.buf.Write(elem)
+	}
+}
+
+// writeObject writes row as json object keeping order of columns
+func (w *NDJSONWriter) writeObject(row Row) error {
+	w.buf.WriteByte('{')
+	for i, col := range w.columns {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(col)
+		if err != nil {
+			return errors.Wrap(err, "encode column name error")
+		}
+		val, err := json.Marshal(row[i])
+		if err != nil {
+			return errors.Wrapf(err, "encode value of column '%s' error", col)
+		}
+
+		w.buf.Write(key)
+		w.buf.WriteByte(':')
+		w.buf.Write(val)
+	}
+	w.buf.WriteByte('}')
+
+	return nil
+}
+
+// Flush implements RowWriter
+func (w *NDJSONWriter) Flush() error {
+	return nil
+}
+
+// TableWriter writes rows as table with aligned columns and header. Rows are buffered until Flush
+type TableWriter struct {
+	tw *tabwriter.Writer
+}
+
+// NewTableWriter constructs TableWriter and writes header of table
+func NewTableWriter(w io.Writer, columns []string) *TableWriter {
+	res := &TableWriter{tw: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)}
+	res.writeLine(columns)
+	return res
+}
+
+// WriteRow implements RowWriter
+func (w *TableWriter) WriteRow(row Row) error {
+	cells := make([]string, 0, len(row))
+	for _, val := range row {
+		cell, err := formatCell(val)
+		if err != nil {
+			return err
+		}
+		cells = append(cells, cell)
+	}
+
+	return w.writeLine(cells)
+}
+
+// Flush implements RowWriter
+func (w *TableWriter) Flush() error {
+	return errors.Wrap(w.tw.Flush(), "write table error")
+}
+
+func (w *TableWriter) writeLine(cells []string) error {
+	_, err := io.WriteString(w.tw, strings.Join(cells, "\t")+"\n")
+	return errors.Wrap(err, "write table error")
+}
+
+// formatCell writes strings as is and other values as json, cell can't contain separators of table
+func formatCell(val interface{}) (string, error) {
+	str, isStr := val.(string)
+	if !isStr {
+		data, err := json.Marshal(val)
+		if err != nil {
+			return "", errors.Wrap(err, "encode value error")
+		}
+		str = string(data)
+	}
+
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(str), nil
+}
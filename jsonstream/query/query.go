@@ -0,0 +1,353 @@
+// Package query runs SQL-like queries "SELECT ... WHERE ... GROUP BY ... LIMIT ..." over stream of json
+package query
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+)
+
+// ErrInvalidQuery appears when query can't be parsed
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Keywords of query clauses
+const (
+	kwSelect  = "select"
+	kwWhere   = "where"
+	kwGroupBy = "group by"
+	kwLimit   = "limit"
+)
+
+// AllColumns selects element as is
+const AllColumns = "*"
+
+var (
+	clauseOrder = []string{kwSelect, kwWhere, kwGroupBy, kwLimit}
+
+	// operatorChars are characters of comparison operators, words next to them are operands
+	operatorChars = "=!<>~"
+
+	aggregateRegexp  = regexp.MustCompile(`^(?i)([a-z_][a-z0-9_]*)\s*\((.*)\)$`)
+	percentileRegexp = regexp.MustCompile(`^p([1-9][0-9]?|100)$`)
+)
+
+// Query is parsed SQL-like query
+type Query struct {
+	columns []column
+	where   *filter.Program
+	groupBy []string
+	limit   int
+}
+
+// column is an item of SELECT clause: path of element, aggregate function of path or all element
+type column struct {
+	name    string
+	path    string
+	fn      string
+	percent float64
+}
+
+func (c column) isAggregate() bool {
+	return c.fn != ""
+}
+
+// Parse parses query "SELECT columns [WHERE condition] [GROUP BY paths] [LIMIT n]".
+// Columns are paths of element, aggregate functions count(*), count(path), sum, avg, min, max
+// and percentiles p1..p100 of path, or "*" to select element as is. Any column may be named by "AS name".
+// WHERE condition is parsed by filter package
+func Parse(text string) (*Query, error) {
+	clauses, err := splitClauses(text)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{}
+	if query.columns, err = parseColumns(clauses[kwSelect]); err != nil {
+		return nil, err
+	}
+
+	if where, ok := clauses[kwWhere]; ok {
+		cond, err := filter.NewConditionFromStr(where)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid WHERE clause")
+		}
+		// element is decoded once for condition and columns
+		if query.where, err = filter.Compile(*cond); err != nil {
+			return nil, errors.Wrap(err, "invalid WHERE clause")
+		}
+	}
+
+	if groupBy, ok := clauses[kwGroupBy]; ok {
+		for _, path := range strings.Split(groupBy, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				return nil, errors.Wrap(ErrInvalidQuery, "empty path in GROUP BY clause")
+			}
+			// paths are separated by commas, words after path are unsupported clauses or missing commas
+			if fields := strings.Fields(path); len(fields) > 1 {
+				return nil, errors.Wrapf(ErrInvalidQuery, "unexpected '%s' after path '%s' in GROUP BY clause",
+					strings.Join(fields[1:], " "), fields[0])
+			}
+			query.groupBy = append(query.groupBy, path)
+		}
+	}
+
+	if limit, ok := clauses[kwLimit]; ok {
+		query.limit, err = strconv.Atoi(strings.TrimSpace(limit))
+		if err != nil || query.limit <= 0 {
+			return nil, errors.Wrapf(ErrInvalidQuery, "LIMIT must be positive integer, passed '%s'", strings.TrimSpace(limit))
+		}
+	}
+
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+// Columns returns names of result columns
+func (q *Query) Columns() []string {
+	res := make([]string, 0, len(q.columns))
+	for _, col := range q.columns {
+		res = append(res, col.name)
+	}
+	return res
+}
+
+// IsAggregate reports that query groups elements, so its result is known only at the end of stream
+func (q *Query) IsAggregate() bool {
+	if len(q.groupBy) > 0 {
+		return true
+	}
+
+	for _, col := range q.columns {
+		if col.isAggregate() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q *Query) validate() error {
+	for _, col := range q.columns {
+		if col.isAggregate() || col.path != AllColumns {
+			continue
+		}
+		if len(q.columns) > 1 {
+			return errors.Wrap(ErrInvalidQuery, "* can't be selected with other columns")
+		}
+		if q.IsAggregate() {
+			return errors.Wrap(ErrInvalidQuery, "* can't be selected by aggregate query")
+		}
+	}
+
+	if !q.IsAggregate() {
+		return nil
+	}
+
+	for _, col := range q.columns {
+		if !col.isAggregate() && !q.isGroupedBy(col.path) {
+			return errors.Wrapf(ErrInvalidQuery, "column '%s' must be aggregated or listed in GROUP BY clause", col.path)
+		}
+	}
+
+	return nil
+}
+
+func (q *Query) isGroupedBy(path string) bool {
+	for _, groupPath := range q.groupBy {
+		if groupPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// splitClauses finds keywords of clauses outside of quotes and brackets and returns content of every clause
+func splitClauses(text string) (map[string]string, error) {
+	type found struct {
+		keyword      string
+		start, after int
+	}
+
+	var keywords []found
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && (i == 0 || isSpace(text[i-1])):
+			if keyword, length := keywordAt(text, i); keyword != "" && (len(keywords) == 0 || !isOperand(text, i, i+length)) {
+				keywords = append(keywords, found{keyword: keyword, start: i, after: i + length})
+				i += length - 1
+			}
+		}
+	}
+
+	if len(keywords) == 0 || keywords[0].keyword != kwSelect || strings.TrimSpace(text[:keywords[0].start]) != "" {
+		return nil, errors.Wrap(ErrInvalidQuery, "query must start with SELECT")
+	}
+
+	res := make(map[string]string, len(keywords))
+	lastOrder := -1
+	for i, kw := range keywords {
+		order := indexOf(clauseOrder, kw.keyword)
+		if order <= lastOrder {
+			return nil, errors.Wrapf(ErrInvalidQuery, "unexpected %s clause", strings.ToUpper(kw.keyword))
+		}
+		lastOrder = order
+
+		end := len(text)
+		if i+1 < len(keywords) {
+			end = keywords[i+1].start
+		}
+		res[kw.keyword] = text[kw.after:end]
+	}
+
+	return res, nil
+}
+
+// isOperand checks that word placed between start and end is a path or a bare value of comparison
+// rather than keyword, e.g. "limit" of "where note = limit" or "where limit > 5"
+func isOperand(text string, start, end int) bool {
+	before := strings.TrimRight(text[:start], " \t\n\r")
+	after := strings.TrimLeft(text[end:], " \t\n\r")
+	return (before != "" && strings.IndexByte(operatorChars, before[len(before)-1]) >= 0) ||
+		(after != "" && strings.IndexByte(operatorChars, after[0]) >= 0)
+}
+
+// keywordAt returns keyword of clause placed at position i and its length
+func keywordAt(text string, i int) (string, int) {
+	for _, keyword := range clauseOrder {
+		words := strings.Fields(keyword)
+		pos := i
+		for j, word := range words {
+			if j > 0 {
+				start := pos
+				for pos < len(text) && isSpace(text[pos]) {
+					pos++
+				}
+				if pos == start {
+					pos = -1
+					break
+				}
+			}
+			if pos+len(word) > len(text) || !strings.EqualFold(text[pos:pos+len(word)], word) {
+				pos = -1
+				break
+			}
+			pos += len(word)
+		}
+
+		if pos > i && (pos == len(text) || isSpace(text[pos])) {
+			return keyword, pos - i
+		}
+	}
+
+	return "", 0
+}
+
+func parseColumns(text string) ([]column, error) {
+	var res []column
+	for _, item := range splitTopLevel(text, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, errors.Wrap(ErrInvalidQuery, "empty column in SELECT clause")
+		}
+
+		col, err := parseColumn(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, col)
+	}
+
+	return res, nil
+}
+
+func parseColumn(item string) (column, error) {
+	col := column{name: item}
+	if fields := strings.Fields(item); len(fields) >= 3 && strings.EqualFold(fields[len(fields)-2], "as") {
+		col.name = fields[len(fields)-1]
+		rest := strings.TrimSpace(item[:strings.LastIndex(item, col.name)])
+		item = strings.TrimSpace(rest[:len(rest)-len("as")])
+	}
+
+	match := aggregateRegexp.FindStringSubmatch(item)
+	if match == nil {
+		col.path = item
+		return col, nil
+	}
+
+	col.fn = strings.ToLower(match[1])
+	col.path = strings.TrimSpace(match[2])
+	switch {
+	case col.path == "":
+		return col, errors.Wrapf(ErrInvalidQuery, "missing argument of function %s", col.fn)
+	case col.path == AllColumns && col.fn != fnCount:
+		return col, errors.Wrapf(ErrInvalidQuery, "function %s can't be applied to *", col.fn)
+	case percentileRegexp.MatchString(col.fn):
+		col.percent, _ = strconv.ParseFloat(col.fn[1:], 64)
+	case indexOf(aggregateFunctions, col.fn) < 0:
+		return col, errors.Wrapf(ErrInvalidQuery, "unknown aggregate function '%s'", col.fn)
+	}
+
+	return col, nil
+}
+
+// splitTopLevel splits text by separator placed outside of quotes and brackets
+func splitTopLevel(text string, sep byte) []string {
+	var res []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			res = append(res, text[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(res, text[start:])
+}
+
+func indexOf(list []string, val string) int {
+	for i, item := range list {
+		if item == val {
+			return i
+		}
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,101 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/shnellpavel/json-stream/jsonstream/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name                string
+		input               string
+		expectedColumns     []string
+		expectedIsAggregate bool
+	}{
+		{
+			name:                "Projection",
+			input:               "SELECT id, job.company",
+			expectedColumns:     []string{"id", "job.company"},
+			expectedIsAggregate: false,
+		},
+		{
+			name:                "All columns with condition and limit",
+			input:               "select * where name = 'Group by' and x = 'limit' limit 10",
+			expectedColumns:     []string{"*"},
+			expectedIsAggregate: false,
+		},
+		{
+			name:                "Keywords as bare values and paths",
+			input:               "SELECT id WHERE note = limit and where != group and limit >= 5 LIMIT 10",
+			expectedColumns:     []string{"id"},
+			expectedIsAggregate: false,
+		},
+		{
+			name:                "Aggregates with aliases",
+			input:               "SELECT service, count(*), P95( latency ) as slow, avg(latency) AS avg WHERE status >= 500 GROUP  BY service",
+			expectedColumns:     []string{"service", "count(*)", "slow", "avg"},
+			expectedIsAggregate: true,
+		},
+		{
+			name:                "Aggregates without group by",
+			input:               "SELECT count(emails), sum(a), min(a), max(a), p100(a)",
+			expectedColumns:     []string{"count(emails)", "sum(a)", "min(a)", "max(a)", "p100(a)"},
+			expectedIsAggregate: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			parsed, err := query.Parse(testCase.input)
+			if assert.NoError(t, err) {
+				assert.Equal(t, testCase.expectedColumns, parsed.Columns())
+				assert.Equal(t, testCase.expectedIsAggregate, parsed.IsAggregate())
+			}
+		})
+	}
+}
+
+func TestParse_Negative(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{name: "Missing select", input: "id, name WHERE id = 1"},
+		{name: "Wrong order of clauses", input: "SELECT id LIMIT 1 WHERE id = 1"},
+		{name: "Duplicate clause", input: "SELECT id WHERE id = 1 WHERE id = 2"},
+		{name: "Empty column", input: "SELECT id,, name"},
+		{name: "Unknown function", input: "SELECT median(latency)"},
+		{name: "Percentile out of range", input: "SELECT p101(latency)"},
+		{name: "Sum of all", input: "SELECT sum(*)"},
+		{name: "Not grouped column", input: "SELECT service, count(*) GROUP BY status"},
+		{name: "All with other columns", input: "SELECT *, id"},
+		{name: "All in aggregate query", input: "SELECT * GROUP BY id"},
+		{name: "Invalid limit", input: "SELECT id LIMIT -1"},
+		{name: "Group by paths without comma", input: "SELECT count(*) GROUP BY service foo"},
+		{name: "Unknown clause after group by", input: "SELECT service, count(*) GROUP BY service ORDER BY service"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			parsed, err := query.Parse(testCase.input)
+			assert.Nil(t, parsed)
+			assert.Equal(t, query.ErrInvalidQuery, errors.Cause(err))
+		})
+	}
+}
+
+func TestParse_GroupByTrailingWords(t *testing.T) {
+	_, err := query.Parse("SELECT service, count(*) GROUP BY service ORDER BY")
+	assert.EqualError(t, err, "unexpected 'ORDER BY' after path 'service' in GROUP BY clause: invalid query")
+}
+
+func TestParse_InvalidWhere(t *testing.T) {
+	_, err := query.Parse("SELECT id WHERE a == 1")
+	if assert.Error(t, err) {
+		assert.Equal(t, filter.ErrInvalidOperator, errors.Cause(err))
+	}
+}
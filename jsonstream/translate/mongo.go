@@ -0,0 +1,371 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+)
+
+var mongoOperators = map[filter.Operator]string{
+	filter.OpEq:    "$eq",
+	filter.OpNotEq: "$ne",
+	filter.OpLt:    "$lt",
+	filter.OpLte:   "$lte",
+	filter.OpGt:    "$gt",
+	filter.OpGte:   "$gte",
+}
+
+// Kinds of json values compared with literal differently
+const (
+	kindString = iota
+	kindNumber
+	kindBool
+	kindCount
+)
+
+var mongoTypes = [kindCount]string{kindString: "string", kindNumber: "number", kindBool: "bool"}
+
+// valueKinds are kinds of values accepted by typed comparisons
+var valueKinds = map[filter.ValueType]int{filter.TypeString: kindString, filter.TypeNumber: kindNumber, filter.TypeBool: kindBool}
+
+// ToMongo translates condition into MongoDB filter document.
+// Functions len, lower and upper and JSONPath queries can't be translated
+func ToMongo(cond filter.Condition) ([]byte, error) {
+	expr, err := rootExpr(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := mongoExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := json.Marshal(doc)
+	return res, errors.Wrap(err, "encode filter document error")
+}
+
+// mongoDoc is a json object keeping order of its fields
+type mongoDoc []mongoField
+
+type mongoField struct {
+	key   string
+	value interface{}
+}
+
+func field(key string, value interface{}) mongoDoc {
+	return mongoDoc{{key: key, value: value}}
+}
+
+// MarshalJSON implements json.Marshaler
+func (d mongoDoc) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range d {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func mongoExpr(expr filter.Expr) (mongoDoc, error) {
+	switch e := expr.(type) {
+	case *filter.CompareExpr:
+		return mongoCompare(e)
+	case *filter.ExistsExpr:
+		if e.Path == selfPath {
+			return nil, errors.Wrap(ErrUntranslatable, "path '@' can be checked only by comparison inside any()")
+		}
+		return field(e.Path, field("$exists", true)), nil
+	case *filter.AnyExpr:
+		return mongoAny(e)
+	case *filter.JSONPathExpr:
+		return nil, errors.Wrapf(ErrUntranslatable, "JSONPath query '%s' can't be translated to Mongo filter", e.Query)
+	case *filter.ConstExpr:
+		if e.Value {
+			return mongoDoc{}, nil
+		}
+		return field("$expr", false), nil
+	case *filter.LogicalExpr:
+		operands := make([]interface{}, 0, len(e.Operands))
+		for _, operand := range e.Operands {
+			doc, err := mongoExpr(operand)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, doc)
+		}
+		return field("$"+e.Operator.String(), operands), nil
+	case *filter.NotExpr:
+		operand, err := mongoExpr(e.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return field("$nor", []interface{}{operand}), nil
+	default:
+		return nil, errors.Errorf("unknown expression %T", expr)
+	}
+}
+
+func mongoCompare(e *filter.CompareExpr) (mongoDoc, error) {
+	if e.Path == selfPath {
+		return nil, errors.Wrap(ErrUntranslatable, "path '@' can be compared only inside any()")
+	}
+	if e.Type == filter.TypeNull {
+		// null of Mongo filter matches missing field too
+		if e.Operator == filter.OpEq {
+			return field(e.Path, field("$eq", nil)), nil
+		}
+		return field("$expr", false), nil
+	}
+
+	alts, err := mongoAlternatives(e)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Operator == filter.OpEq {
+		var values []interface{}
+		for _, ops := range alts {
+			if ops != nil {
+				values = append(values, ops[0].value)
+			}
+		}
+		if len(values) == 1 {
+			return field(e.Path, field("$eq", values[0])), nil
+		}
+		return field(e.Path, field("$in", values)), nil
+	}
+
+	var docs []interface{}
+	for _, ops := range alts {
+		if ops == nil {
+			continue
+		}
+		docs = append(docs, field(e.Path, ops))
+		// $ne and $not are satisfied by array only if none of elements matches, but filter package looks for any element
+		if ops.hasNegation() {
+			docs = append(docs, field(e.Path, field("$elemMatch", ops)))
+		}
+	}
+
+	return mongoOr(docs), nil
+}
+
+// mongoAlternatives returns operators checking value of every kind which may satisfy comparison
+func mongoAlternatives(e *filter.CompareExpr) ([kindCount]mongoDoc, error) {
+	var alts [kindCount]mongoDoc
+	if e.Func != filter.FuncNone {
+		return alts, errors.Wrapf(ErrUntranslatable, "function %s of path '%s' can't be translated to Mongo filter", e.Func.String(), e.Path)
+	}
+	if e.Type == filter.TypeNull {
+		return alts, errors.Wrapf(ErrUntranslatable, "comparison of path '%s' with null can't be translated inside any()", e.Path)
+	}
+
+	switch e.Operator {
+	case filter.OpLike:
+		alts[kindString] = field("$regex", e.Value)
+	case filter.OpNotLike:
+		alts[kindString] = mongoDoc{{key: "$type", value: "string"}, {key: "$not", value: field("$regex", e.Value)}}
+		alts[kindNumber] = field("$type", mongoTypes[kindNumber])
+		alts[kindBool] = field("$type", mongoTypes[kindBool])
+	default:
+		lit := newLiteral(e.Value)
+		op := mongoOperators[e.Operator]
+		add := func(kind int, value interface{}) {
+			alts[kind] = field(op, value)
+			// $ne is satisfied by values of other types, which filter package doesn't compare
+			if e.Operator == filter.OpNotEq {
+				alts[kind] = mongoDoc{{key: "$type", value: mongoTypes[kind]}, {key: op, value: value}}
+			}
+		}
+
+		add(kindString, lit.str)
+		if lit.hasNum {
+			add(kindNumber, json.Number(lit.num))
+		}
+		if lit.hasBool && !isOrdering(e.Operator) {
+			add(kindBool, lit.boolean)
+		}
+	}
+
+	// typed comparison checks values of its type only
+	if kind, ok := valueKinds[e.Type]; ok {
+		for i := range alts {
+			if i != kind {
+				alts[i] = nil
+			}
+		}
+	}
+
+	return alts, nil
+}
+
+func (d mongoDoc) hasNegation() bool {
+	for _, f := range d {
+		if f.key == "$ne" || f.key == "$not" {
+			return true
+		}
+	}
+	return false
+}
+
+// mongoAny uses query form of $elemMatch for elements of array, conditions on element itself (@)
+// are expressed by operator form of $elemMatch
+func mongoAny(e *filter.AnyExpr) (mongoDoc, error) {
+	if e.Path == selfPath {
+		return nil, errors.Wrap(ErrUntranslatable, "any() of path '@' can't be translated to Mongo filter")
+	}
+
+	if !usesSelf(e.Operand) {
+		operand, err := mongoExpr(e.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return field(e.Path, field("$elemMatch", operand)), nil
+	}
+
+	return mongoSelfAny(e.Path, e.Operand)
+}
+
+// mongoSelfAny splits "or" into several $elemMatch and builds $elemMatch for every kind of element,
+// because element of one kind has to satisfy all comparisons joined by "and"
+func mongoSelfAny(path string, operand filter.Expr) (mongoDoc, error) {
+	if logical, ok := operand.(*filter.LogicalExpr); ok && logical.Operator == filter.LogicalOr {
+		var docs []interface{}
+		for _, elemOperand := range logical.Operands {
+			doc, err := mongoSelfAny(path, elemOperand)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+		return mongoOr(docs), nil
+	}
+
+	alts, err := selfAlternatives(path, operand)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []interface{}
+	for _, ops := range alts {
+		if ops != nil {
+			docs = append(docs, field(path, field("$elemMatch", ops)))
+		}
+	}
+
+	return mongoOr(docs), nil
+}
+
+func selfAlternatives(path string, operand filter.Expr) ([kindCount]mongoDoc, error) {
+	switch e := operand.(type) {
+	case *filter.CompareExpr:
+		if e.Path == selfPath {
+			return mongoAlternatives(e)
+		}
+	case *filter.LogicalExpr:
+		if e.Operator != filter.LogicalAnd {
+			break
+		}
+
+		var res [kindCount]mongoDoc
+		for i, elemOperand := range e.Operands {
+			alts, err := selfAlternatives(path, elemOperand)
+			if err != nil {
+				return res, err
+			}
+
+			for kind := range res {
+				switch {
+				case i == 0:
+					res[kind] = alts[kind]
+				case res[kind] == nil || alts[kind] == nil:
+					res[kind] = nil
+				default:
+					if res[kind], err = mergeOperators(path, res[kind], alts[kind]); err != nil {
+						return res, err
+					}
+				}
+			}
+		}
+		return res, nil
+	}
+
+	return [kindCount]mongoDoc{}, errors.Wrapf(ErrUntranslatable,
+		"condition of any() on path '%s' combining @ with other paths or negations can't be translated to Mongo filter", path)
+}
+
+func mergeOperators(path string, doc, other mongoDoc) (mongoDoc, error) {
+	res := append(mongoDoc{}, doc...)
+	for _, f := range other {
+		if i := res.index(f.key); i >= 0 {
+			if f.key == "$type" && res[i].value == f.value {
+				continue
+			}
+			return nil, errors.Wrapf(ErrUntranslatable, "operator %s is used twice for @ inside any() on path '%s'", f.key, path)
+		}
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+func (d mongoDoc) index(key string) int {
+	for i, f := range d {
+		if f.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mongoOr joins documents by $or, no documents give filter which is never satisfied
+func mongoOr(docs []interface{}) mongoDoc {
+	switch len(docs) {
+	case 0:
+		return field("$expr", false)
+	case 1:
+		return docs[0].(mongoDoc)
+	default:
+		return field("$or", docs)
+	}
+}
+
+// usesSelf checks that expression refers to element itself, operands of nested any() refer to their own elements
+func usesSelf(expr filter.Expr) bool {
+	switch e := expr.(type) {
+	case *filter.CompareExpr:
+		return e.Path == selfPath
+	case *filter.ExistsExpr:
+		return e.Path == selfPath
+	case *filter.AnyExpr:
+		return e.Path == selfPath
+	case *filter.LogicalExpr:
+		for _, operand := range e.Operands {
+			if usesSelf(operand) {
+				return true
+			}
+		}
+	case *filter.NotExpr:
+		return usesSelf(e.Operand)
+	}
+	return false
+}
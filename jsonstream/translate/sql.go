@@ -0,0 +1,394 @@
+package translate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+)
+
+var sqlOperators = map[filter.Operator]string{
+	filter.OpEq:    "=",
+	filter.OpNotEq: "<>",
+	filter.OpLt:    "<",
+	filter.OpLte:   "<=",
+	filter.OpGt:    ">",
+	filter.OpGte:   ">=",
+}
+
+// ToPostgres translates condition into WHERE clause of PostgreSQL query over jsonb column.
+// Paths go through arrays like in filter package, regular expressions are matched as POSIX ones by PostgreSQL
+func ToPostgres(cond filter.Condition, opts ...Option) (string, error) {
+	return translateSQL(cond, postgresDialect{}, opts)
+}
+
+// ToSQLite translates condition into WHERE clause of SQLite query over json text column.
+// Paths go through arrays like in filter package, but arrays nested into arrays are looked through
+// only at the end of path. Regular expressions aren't supported by SQLite
+func ToSQLite(cond filter.Condition, opts ...Option) (string, error) {
+	return translateSQL(cond, sqliteDialect{}, opts)
+}
+
+func translateSQL(cond filter.Condition, dialect sqlDialect, opts []Option) (string, error) {
+	expr, err := rootExpr(cond)
+	if err != nil {
+		return "", err
+	}
+
+	t := &sqlTranslator{dialect: dialect}
+	return t.expr(sqlDoc{value: newOptions(opts).column}, expr)
+}
+
+// sqlDoc is a json document in SQL query: column of table or element of array inside any()
+type sqlDoc struct {
+	value string
+	// base is SQL expression of path to the document inside value, empty means the root of value
+	base string
+}
+
+// sqlDialect builds checks of json documents specific for database
+type sqlDialect interface {
+	// walk builds check of values found by path, check gets document and path relative to it
+	walk(t *sqlTranslator, doc sqlDoc, path string, check func(doc sqlDoc, path string) (string, error)) (string, error)
+	// compare checks values found by path, which is e.Path or its part
+	compare(t *sqlTranslator, doc sqlDoc, path string, e *filter.CompareExpr) (string, error)
+	exists(doc sqlDoc, path string) (string, error)
+	// elements returns FROM clause selecting elements of array by path, condition on it and element document
+	elements(t *sqlTranslator, doc sqlDoc, path string) (from string, guard string, elem sqlDoc, err error)
+}
+
+type sqlTranslator struct {
+	dialect sqlDialect
+	aliases int
+}
+
+func (t *sqlTranslator) expr(doc sqlDoc, expr filter.Expr) (string, error) {
+	switch e := expr.(type) {
+	case *filter.CompareExpr:
+		return t.dialect.walk(t, doc, e.Path, func(doc sqlDoc, path string) (string, error) {
+			return t.dialect.compare(t, doc, path, e)
+		})
+	case *filter.ExistsExpr:
+		return t.dialect.walk(t, doc, e.Path, t.dialect.exists)
+	case *filter.AnyExpr:
+		return t.any(doc, e)
+	case *filter.JSONPathExpr:
+		return "", errors.Wrapf(ErrUntranslatable, "JSONPath query '%s' can't be translated to SQL", e.Query)
+	case *filter.ConstExpr:
+		if e.Value {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case *filter.LogicalExpr:
+		operands := make([]string, 0, len(e.Operands))
+		for _, operand := range e.Operands {
+			res, err := t.expr(doc, operand)
+			if err != nil {
+				return "", err
+			}
+			operands = append(operands, res)
+		}
+		return "(" + strings.Join(operands, " "+strings.ToUpper(e.Operator.String())+" ") + ")", nil
+	case *filter.NotExpr:
+		operand, err := t.expr(doc, e.Operand)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + operand + ")", nil
+	default:
+		return "", errors.Errorf("unknown expression %T", expr)
+	}
+}
+
+func (t *sqlTranslator) any(doc sqlDoc, e *filter.AnyExpr) (string, error) {
+	return t.dialect.walk(t, doc, e.Path, func(doc sqlDoc, path string) (string, error) {
+		from, guard, elem, err := t.dialect.elements(t, doc, path)
+		if err != nil {
+			return "", err
+		}
+
+		operand, err := t.expr(elem, e.Operand)
+		if err != nil {
+			return "", err
+		}
+		if guard != "" {
+			operand = guard + " AND " + operand
+		}
+
+		return "EXISTS (SELECT 1 FROM " + from + " WHERE " + operand + ")", nil
+	})
+}
+
+// alias returns unique name of table in query
+func (t *sqlTranslator) alias() string {
+	t.aliases++
+	return "t" + strconv.Itoa(t.aliases)
+}
+
+// sqlValue describes json value in SQL query by conditions checking its type and expressions accessing it
+type sqlValue struct {
+	isString, isNumber, isBool, isArray, isObject string
+	str, num, boolean, arrayLen, objectLen        string
+	trueLit, falseLit                             string
+	// collate makes strings ordered by bytes like in filter package
+	collate string
+	likeOps map[filter.Operator]string
+}
+
+// sqlCase builds CASE expression comparing value of any json type the way filter package does
+func sqlCase(v sqlValue, e *filter.CompareExpr) (string, error) {
+	if e.Type == filter.TypeNull {
+		return "", errors.Wrapf(ErrUntranslatable, "comparison of path '%s' with null can't be translated to SQL", e.Path)
+	}
+
+	// typed comparison checks values of its type only
+	typeCond := map[filter.ValueType]string{filter.TypeString: v.isString, filter.TypeNumber: v.isNumber, filter.TypeBool: v.isBool}[e.Type]
+	lit := newLiteral(e.Value)
+	var branches []string
+	add := func(cond, check string) {
+		if e.Type == filter.TypeAny || e.Func == filter.FuncLen || cond == typeCond {
+			branches = append(branches, "WHEN "+cond+" THEN "+check)
+		}
+	}
+
+	switch {
+	case e.Func == filter.FuncLen:
+		if isLike(e.Operator) {
+			return "", errors.Wrapf(ErrUntranslatable, "len of path '%s' can't be matched by regular expression", e.Path)
+		}
+		if !lit.hasNum {
+			return "", errors.Wrapf(ErrUntranslatable, "len of path '%s' must be compared with number, passed '%s'", e.Path, e.Value)
+		}
+		op := sqlOperators[e.Operator]
+		add(v.isString, "length("+v.str+") "+op+" "+lit.num)
+		add(v.isArray, v.arrayLen+" "+op+" "+lit.num)
+		add(v.isObject, v.objectLen+" "+op+" "+lit.num)
+	case isLike(e.Operator):
+		add(v.isString, sqlFunction(e.Func, v.str)+" "+v.likeOps[e.Operator]+" "+quoteSQLString(e.Value))
+		if e.Operator == filter.OpNotLike {
+			add(v.isNumber, "TRUE")
+			add(v.isBool, "TRUE")
+		}
+	default:
+		op := sqlOperators[e.Operator]
+		str := sqlFunction(e.Func, v.str)
+		if isOrdering(e.Operator) && v.collate != "" {
+			str = "(" + str + ")" + v.collate
+		}
+		add(v.isString, str+" "+op+" "+quoteSQLString(lit.str))
+		if lit.hasNum {
+			add(v.isNumber, v.num+" "+op+" "+lit.num)
+		}
+		if lit.hasBool && !isOrdering(e.Operator) {
+			boolLit := v.falseLit
+			if lit.boolean {
+				boolLit = v.trueLit
+			}
+			add(v.isBool, v.boolean+" "+op+" "+boolLit)
+		}
+	}
+
+	return "CASE " + strings.Join(branches, " ") + " ELSE FALSE END", nil
+}
+
+// sqlFunction applies function transforming strings, len is handled by sqlCase
+func sqlFunction(fn filter.Function, str string) string {
+	switch fn {
+	case filter.FuncLower:
+		return "lower(" + str + ")"
+	case filter.FuncUpper:
+		return "upper(" + str + ")"
+	default:
+		return str
+	}
+}
+
+func quoteSQLString(str string) string {
+	return "'" + strings.Replace(str, "'", "''", -1) + "'"
+}
+
+// postgresDialect checks jsonb documents by SQL/JSON path functions. Lax mode of paths unwraps arrays
+// met in the middle of path like filter package does
+type postgresDialect struct{}
+
+// walk passes path as is, lax mode looks through arrays
+func (postgresDialect) walk(_ *sqlTranslator, doc sqlDoc, path string, check func(doc sqlDoc, path string) (string, error)) (string, error) {
+	return check(doc, path)
+}
+
+func (d postgresDialect) compare(t *sqlTranslator, doc sqlDoc, path string, e *filter.CompareExpr) (string, error) {
+	alias := t.alias()
+	check, err := sqlCase(d.value(alias+".v"), e)
+	if err != nil {
+		return "", err
+	}
+
+	// elements of array at the end of path are compared one by one, but len counts them
+	sqlPath := d.path(path, e.Func != filter.FuncLen)
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM jsonb_path_query(%s, %s) AS %s(v) WHERE %s)", doc.value, sqlPath, alias, check), nil
+}
+
+func (d postgresDialect) exists(doc sqlDoc, path string) (string, error) {
+	return fmt.Sprintf("jsonb_path_exists(%s, %s)", doc.value, d.path(path, false)), nil
+}
+
+func (d postgresDialect) elements(t *sqlTranslator, doc sqlDoc, path string) (string, string, sqlDoc, error) {
+	outer, inner := t.alias(), t.alias()
+	from := fmt.Sprintf("jsonb_path_query(%s, %s) AS %s(v) CROSS JOIN LATERAL jsonb_array_elements("+
+		"CASE jsonb_typeof(%s.v) WHEN 'array' THEN %s.v ELSE '[]'::jsonb END) AS %s(v)",
+		doc.value, d.path(path, false), outer, outer, outer, inner)
+	return from, "", sqlDoc{value: inner + ".v"}, nil
+}
+
+func (postgresDialect) value(item string) sqlValue {
+	return sqlValue{
+		isString:  "jsonb_typeof(" + item + ") = 'string'",
+		isNumber:  "jsonb_typeof(" + item + ") = 'number'",
+		isBool:    "jsonb_typeof(" + item + ") = 'boolean'",
+		isArray:   "jsonb_typeof(" + item + ") = 'array'",
+		isObject:  "jsonb_typeof(" + item + ") = 'object'",
+		str:       item + " #>> '{}'",
+		num:       "(" + item + ")::numeric",
+		boolean:   "(" + item + ")::boolean",
+		arrayLen:  "jsonb_array_length(" + item + ")",
+		objectLen: "(SELECT count(*) FROM jsonb_object_keys(" + item + "))",
+		trueLit:   "TRUE",
+		falseLit:  "FALSE",
+		collate:   ` COLLATE "C"`,
+		likeOps:   map[filter.Operator]string{filter.OpLike: "~", filter.OpNotLike: "!~"},
+	}
+}
+
+// path builds SQL/JSON path literal, unwrap makes it select elements of array found by path
+func (postgresDialect) path(path string, unwrap bool) string {
+	res := "lax $"
+	if path != selfPath {
+		escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		for _, key := range strings.Split(path, ".") {
+			res += `."` + escaper.Replace(key) + `"`
+		}
+	}
+	if unwrap {
+		res += "[*]"
+	}
+	return quoteSQLString(res)
+}
+
+// sqliteDialect checks json documents by JSON1 functions. Path of element inside any() is taken from json_each
+type sqliteDialect struct{}
+
+// walk looks through arrays met in the middle of path: values found by path are checked if value
+// of its prefix isn't array, otherwise values found by the rest of path in elements of array are checked
+func (d sqliteDialect) walk(t *sqlTranslator, doc sqlDoc, path string, check func(doc sqlDoc, path string) (string, error)) (string, error) {
+	return d.walkKeys(t, doc, strings.Split(path, "."), 1, check)
+}
+
+// walkKeys checks values found by keys, value of keys[:i] and values of shorter prefixes aren't arrays
+func (d sqliteDialect) walkKeys(t *sqlTranslator, doc sqlDoc, keys []string, i int, check func(doc sqlDoc, path string) (string, error)) (string, error) {
+	if i >= len(keys) {
+		return check(doc, strings.Join(keys, "."))
+	}
+
+	prefix, err := d.path(doc, strings.Join(keys[:i], "."))
+	if err != nil {
+		return "", err
+	}
+	alias := t.alias()
+	elems, err := d.walk(t, sqlDoc{value: doc.value, base: alias + ".fullkey"}, strings.Join(keys[i:], "."), check)
+	if err != nil {
+		return "", err
+	}
+	value, err := d.walkKeys(t, doc, keys, i+1, check)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("CASE WHEN json_type(%s, %s) = 'array' THEN EXISTS (SELECT 1 FROM json_each(%s, %s) AS %s WHERE %s) ELSE %s END",
+		doc.value, prefix, doc.value, prefix, alias, elems, value), nil
+}
+
+func (d sqliteDialect) compare(t *sqlTranslator, doc sqlDoc, path string, e *filter.CompareExpr) (string, error) {
+	if isLike(e.Operator) {
+		return "", errors.Wrapf(ErrUntranslatable, "regular expression of path '%s' can't be translated to SQLite", e.Path)
+	}
+
+	sqlPath, err := d.path(doc, path)
+	if err != nil {
+		return "", err
+	}
+
+	if e.Func == filter.FuncLen {
+		jsonType := "json_type(" + doc.value + ", " + sqlPath + ")"
+		return sqlCase(sqlValue{
+			isString:  jsonType + " = 'text'",
+			isArray:   jsonType + " = 'array'",
+			isObject:  jsonType + " = 'object'",
+			str:       "json_extract(" + doc.value + ", " + sqlPath + ")",
+			arrayLen:  "json_array_length(" + doc.value + ", " + sqlPath + ")",
+			objectLen: "(SELECT count(*) FROM json_each(" + doc.value + ", " + sqlPath + "))",
+		}, e)
+	}
+
+	// json_each gives the value itself or elements of array, so elements are compared one by one
+	alias := t.alias()
+	check, err := sqlCase(sqlValue{
+		isString: alias + ".type = 'text'",
+		isNumber: alias + ".type IN ('integer', 'real')",
+		isBool:   alias + ".type IN ('true', 'false')",
+		str:      alias + ".value",
+		num:      alias + ".value",
+		boolean:  alias + ".value",
+		trueLit:  "1",
+		falseLit: "0",
+	}, e)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s, %s) AS %s WHERE json_type(%s, %s) <> 'object' AND %s)",
+		doc.value, sqlPath, alias, doc.value, sqlPath, check), nil
+}
+
+func (d sqliteDialect) exists(doc sqlDoc, path string) (string, error) {
+	jsonPath, err := d.path(doc, path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("json_type(%s, %s) IS NOT NULL", doc.value, jsonPath), nil
+}
+
+func (d sqliteDialect) elements(t *sqlTranslator, doc sqlDoc, path string) (string, string, sqlDoc, error) {
+	jsonPath, err := d.path(doc, path)
+	if err != nil {
+		return "", "", sqlDoc{}, err
+	}
+
+	alias := t.alias()
+	from := fmt.Sprintf("json_each(%s, %s) AS %s", doc.value, jsonPath, alias)
+	guard := fmt.Sprintf("json_type(%s, %s) = 'array'", doc.value, jsonPath)
+	return from, guard, sqlDoc{value: doc.value, base: alias + ".fullkey"}, nil
+}
+
+// path builds SQL expression of json path relative to the document
+func (sqliteDialect) path(doc sqlDoc, path string) (string, error) {
+	suffix := ""
+	if path != selfPath {
+		for _, key := range strings.Split(path, ".") {
+			if strings.Contains(key, `"`) {
+				return "", errors.Wrapf(ErrUntranslatable, "key '%s' of path '%s' can't be used in SQLite json path", key, path)
+			}
+			suffix += `."` + key + `"`
+		}
+	}
+
+	switch {
+	case doc.base == "":
+		return quoteSQLString("$" + suffix), nil
+	case suffix == "":
+		return doc.base, nil
+	default:
+		return doc.base + " || " + quoteSQLString(suffix), nil
+	}
+}
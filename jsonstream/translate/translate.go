@@ -0,0 +1,108 @@
+// Package translate converts conditions of filter package into queries of databases,
+// so filtering may be pushed down to the storage of elements
+package translate
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+)
+
+// ErrUntranslatable appears when condition uses construct having no equivalent in target query language
+var ErrUntranslatable = errors.New("untranslatable condition")
+
+// Targets of translation
+const (
+	TargetPostgres = "postgres"
+	TargetSQLite   = "sqlite"
+	TargetMongo    = "mongo"
+)
+
+// Targets lists all supported targets of translation
+var Targets = []string{TargetPostgres, TargetSQLite, TargetMongo}
+
+// DefaultColumn is a column storing elements which is used by SQL translations by default
+const DefaultColumn = "doc"
+
+// selfPath refers to the element itself inside any()
+const selfPath = "@"
+
+// Option customizes translation of condition
+type Option func(opts *options)
+
+type options struct {
+	column string
+}
+
+// WithColumn sets SQL expression of json column storing elements (jsonb for postgres, text for sqlite)
+func WithColumn(column string) Option {
+	return func(opts *options) {
+		opts.column = column
+	}
+}
+
+// Translate translates condition into query of target: WHERE clause for SQL targets and filter document for mongo
+func Translate(cond filter.Condition, target string, opts ...Option) (string, error) {
+	switch target {
+	case TargetPostgres:
+		return ToPostgres(cond, opts...)
+	case TargetSQLite:
+		return ToSQLite(cond, opts...)
+	case TargetMongo:
+		doc, err := ToMongo(cond)
+		return string(doc), err
+	default:
+		return "", errors.Errorf("unknown target '%s'", target)
+	}
+}
+
+func newOptions(opts []Option) options {
+	res := options{column: DefaultColumn}
+	for _, opt := range opts {
+		opt(&res)
+	}
+	return res
+}
+
+// rootExpr returns expression tree of valid condition
+func rootExpr(cond filter.Condition) (filter.Expr, error) {
+	if err := cond.Err(); err != nil {
+		return nil, errors.Wrap(err, "invalid condition")
+	}
+	if cond.Expr() == nil {
+		return nil, errors.New("empty condition")
+	}
+	return cond.Expr(), nil
+}
+
+// literal is a value of comparison in forms matching json types. Like filter package,
+// strings are compared with value as is, numbers and booleans with value parsed as number or boolean
+type literal struct {
+	str     string
+	num     string
+	hasNum  bool
+	boolean bool
+	hasBool bool
+}
+
+func newLiteral(value string) literal {
+	lit := literal{str: value}
+	if num, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(num, 0) && !math.IsNaN(num) {
+		lit.num, lit.hasNum = strconv.FormatFloat(num, 'g', -1, 64), true
+	}
+	if boolean, err := strconv.ParseBool(value); err == nil {
+		lit.boolean, lit.hasBool = boolean, true
+	}
+	return lit
+}
+
+// isOrdering checks that operator compares values by order, booleans can't be compared so
+func isOrdering(op filter.Operator) bool {
+	return op == filter.OpLt || op == filter.OpLte || op == filter.OpGt || op == filter.OpGte
+}
+
+func isLike(op filter.Operator) bool {
+	return op == filter.OpLike || op == filter.OpNotLike
+}
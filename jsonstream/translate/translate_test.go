@@ -0,0 +1,268 @@
+package translate_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shnellpavel/json-stream/jsonstream/filter"
+	"github.com/shnellpavel/json-stream/jsonstream/translate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToMongo(t *testing.T) {
+	cases := []struct {
+		name      string
+		inputExpr string
+		expected  string
+	}{
+		{
+			name:      "Equality with string",
+			inputExpr: "name = John",
+			expected:  `{"name":{"$eq":"John"}}`,
+		},
+		{
+			name:      "Equality with value of several types",
+			inputExpr: "flag = 1",
+			expected:  `{"flag":{"$in":["1",1,true]}}`,
+		},
+		{
+			name:      "Ordering",
+			inputExpr: "age >= 5.50",
+			expected:  `{"$or":[{"age":{"$gte":"5.50"}},{"age":{"$gte":5.5}}]}`,
+		},
+		{
+			name:      "Not equal checks type and elements of arrays",
+			inputExpr: "name != John",
+			expected:  `{"$or":[{"name":{"$type":"string","$ne":"John"}},{"name":{"$elemMatch":{"$type":"string","$ne":"John"}}}]}`,
+		},
+		{
+			name:      "Regular expressions",
+			inputExpr: `email ~ "gmail\\.com$" and name !~ "^J"`,
+			expected: `{"$and":[{"email":{"$regex":"gmail\\.com$"}},{"$or":[{"name":{"$type":"string","$not":{"$regex":"^J"}}},` +
+				`{"name":{"$elemMatch":{"$type":"string","$not":{"$regex":"^J"}}}},{"name":{"$type":"number"}},{"name":{"$type":"bool"}}]}]}`,
+		},
+		{
+			name:      "Logical operators",
+			inputExpr: "not exists(error) or true or false",
+			expected:  `{"$or":[{"$nor":[{"error":{"$exists":true}}]},{},{"$expr":false}]}`,
+		},
+		{
+			name:      "Any of documents",
+			inputExpr: "any(children, name = Pit and exists(toys))",
+			expected:  `{"children":{"$elemMatch":{"$and":[{"name":{"$eq":"Pit"}},{"toys":{"$exists":true}}]}}}`,
+		},
+		{
+			name:      "Any of values is split by kinds",
+			inputExpr: "any(scores, @ >= 80 and @ < 85 or @ = top)",
+			expected: `{"$or":[{"$or":[{"scores":{"$elemMatch":{"$gte":"80","$lt":"85"}}},{"scores":{"$elemMatch":{"$gte":80,"$lt":85}}}]},` +
+				`{"scores":{"$elemMatch":{"$eq":"top"}}}]}`,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cond, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			actual, err := translate.ToMongo(*cond)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, string(actual))
+		})
+	}
+}
+
+func TestToPostgres(t *testing.T) {
+	cases := []struct {
+		name      string
+		inputExpr string
+		expected  string
+	}{
+		{
+			name:      "Comparison",
+			inputExpr: "job.company = 'Some firm'",
+			expected: `EXISTS (SELECT 1 FROM jsonb_path_query(doc, 'lax $."job"."company"[*]') AS t1(v) ` +
+				`WHERE CASE WHEN jsonb_typeof(t1.v) = 'string' THEN t1.v #>> '{}' = 'Some firm' ELSE FALSE END)`,
+		},
+		{
+			name:      "Ordering of strings by bytes",
+			inputExpr: "lower(name) < b",
+			expected: `EXISTS (SELECT 1 FROM jsonb_path_query(doc, 'lax $."name"[*]') AS t1(v) ` +
+				`WHERE CASE WHEN jsonb_typeof(t1.v) = 'string' THEN (lower(t1.v #>> '{}')) COLLATE "C" < 'b' ELSE FALSE END)`,
+		},
+		{
+			name:      "Length",
+			inputExpr: "len(tags) > 2",
+			expected: `EXISTS (SELECT 1 FROM jsonb_path_query(doc, 'lax $."tags"') AS t1(v) WHERE CASE ` +
+				`WHEN jsonb_typeof(t1.v) = 'string' THEN length(t1.v #>> '{}') > 2 ` +
+				`WHEN jsonb_typeof(t1.v) = 'array' THEN jsonb_array_length(t1.v) > 2 ` +
+				`WHEN jsonb_typeof(t1.v) = 'object' THEN (SELECT count(*) FROM jsonb_object_keys(t1.v)) > 2 ELSE FALSE END)`,
+		},
+		{
+			name:      "Any and exists",
+			inputExpr: `not any(children, exists(toys)) and name ~ "^J"`,
+			expected: `(NOT (EXISTS (SELECT 1 FROM jsonb_path_query(doc, 'lax $."children"') AS t1(v) ` +
+				`CROSS JOIN LATERAL jsonb_array_elements(CASE jsonb_typeof(t1.v) WHEN 'array' THEN t1.v ELSE '[]'::jsonb END) AS t2(v) ` +
+				`WHERE jsonb_path_exists(t2.v, 'lax $."toys"'))) AND ` +
+				`EXISTS (SELECT 1 FROM jsonb_path_query(doc, 'lax $."name"[*]') AS t3(v) ` +
+				`WHERE CASE WHEN jsonb_typeof(t3.v) = 'string' THEN t3.v #>> '{}' ~ '^J' ELSE FALSE END))`,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cond, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			actual, err := translate.ToPostgres(*cond)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, actual)
+		})
+	}
+}
+
+func TestToSQLite(t *testing.T) {
+	cases := []struct {
+		name      string
+		inputExpr string
+		expected  string
+	}{
+		{
+			name:      "Comparison with value of several types",
+			inputExpr: "flag != 0",
+			expected: `EXISTS (SELECT 1 FROM json_each(data, '$."flag"') AS t1 WHERE json_type(data, '$."flag"') <> 'object' AND CASE ` +
+				`WHEN t1.type = 'text' THEN t1.value <> '0' WHEN t1.type IN ('integer', 'real') THEN t1.value <> 0 ` +
+				`WHEN t1.type IN ('true', 'false') THEN t1.value <> 0 ELSE FALSE END)`,
+		},
+		{
+			name:      "Quotes are escaped",
+			inputExpr: `name = "O'Neil" or exists(job)`,
+			expected: `(EXISTS (SELECT 1 FROM json_each(data, '$."name"') AS t1 WHERE json_type(data, '$."name"') <> 'object' AND CASE ` +
+				`WHEN t1.type = 'text' THEN t1.value = 'O''Neil' ELSE FALSE END) OR json_type(data, '$."job"') IS NOT NULL)`,
+		},
+		{
+			name:      "Arrays in the middle of path are looked through",
+			inputExpr: "exists(children.toys)",
+			expected: `CASE WHEN json_type(data, '$."children"') = 'array' THEN EXISTS (SELECT 1 FROM json_each(data, '$."children"') AS t1 ` +
+				`WHERE json_type(data, t1.fullkey || '."toys"') IS NOT NULL) ELSE json_type(data, '$."children"."toys"') IS NOT NULL END`,
+		},
+		{
+			name:      "Paths of any are relative to element",
+			inputExpr: "any(children, any(toys, @ = car))",
+			expected: `EXISTS (SELECT 1 FROM json_each(data, '$."children"') AS t1 WHERE json_type(data, '$."children"') = 'array' AND ` +
+				`EXISTS (SELECT 1 FROM json_each(data, t1.fullkey || '."toys"') AS t2 WHERE json_type(data, t1.fullkey || '."toys"') = 'array' AND ` +
+				`EXISTS (SELECT 1 FROM json_each(data, t2.fullkey) AS t3 WHERE json_type(data, t2.fullkey) <> 'object' AND ` +
+				`CASE WHEN t3.type = 'text' THEN t3.value = 'car' ELSE FALSE END)))`,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cond, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			actual, err := translate.ToSQLite(*cond, translate.WithColumn("data"))
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, actual)
+		})
+	}
+}
+
+func TestTranslate_Negative(t *testing.T) {
+	cases := []struct {
+		name        string
+		inputExpr   string
+		target      string
+		expectedMsg string
+	}{
+		{
+			name:        "JSONPath",
+			inputExpr:   "jsonpath('$.a')",
+			target:      translate.TargetPostgres,
+			expectedMsg: "JSONPath query '$.a' can't be translated to SQL: untranslatable condition",
+		},
+		{
+			name:        "Regular expression in SQLite",
+			inputExpr:   "name ~ J",
+			target:      translate.TargetSQLite,
+			expectedMsg: "regular expression of path 'name' can't be translated to SQLite: untranslatable condition",
+		},
+		{
+			name:        "Length compared with string",
+			inputExpr:   "len(name) > a",
+			target:      translate.TargetSQLite,
+			expectedMsg: "len of path 'name' must be compared with number, passed 'a': untranslatable condition",
+		},
+		{
+			name:        "Function in Mongo",
+			inputExpr:   "upper(name) = JOHN",
+			target:      translate.TargetMongo,
+			expectedMsg: "function upper of path 'name' can't be translated to Mongo filter: untranslatable condition",
+		},
+		{
+			name:        "Negation of element in Mongo",
+			inputExpr:   "any(tags, not @ = a)",
+			target:      translate.TargetMongo,
+			expectedMsg: "condition of any() on path 'tags' combining @ with other paths or negations can't be translated to Mongo filter: untranslatable condition",
+		},
+		{
+			name:        "Operator used twice for element in Mongo",
+			inputExpr:   "any(tags, @ > 1 and @ > 2)",
+			target:      translate.TargetMongo,
+			expectedMsg: "operator $gt is used twice for @ inside any() on path 'tags': untranslatable condition",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cond, err := filter.NewConditionFromStr(testCase.inputExpr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = translate.Translate(*cond, testCase.target)
+			assert.Equal(t, translate.ErrUntranslatable, errors.Cause(err))
+			assert.EqualError(t, err, testCase.expectedMsg)
+		})
+	}
+}
+
+func TestTranslate_TypedComparisons(t *testing.T) {
+	cond, err := filter.NewConditionFromMongo([]byte(`{"age": {"$gte": 5}, "flag": {"$ne": true}, "parent": null}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	actual, err := translate.ToMongo(*cond)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"$and":[{"age":{"$gte":5}},{"$nor":[{"flag":{"$eq":true}}]},{"parent":{"$eq":null}}]}`, string(actual))
+
+	_, err = translate.ToPostgres(*cond)
+	assert.EqualError(t, err, "comparison of path 'parent' with null can't be translated to SQL: untranslatable condition")
+
+	cond, err = filter.NewConditionFromMongo([]byte(`{"age": {"$gte": 5}}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sql, err := translate.ToPostgres(*cond)
+	assert.NoError(t, err)
+	assert.Equal(t, `EXISTS (SELECT 1 FROM jsonb_path_query(doc, 'lax $."age"[*]') AS t1(v) `+
+		`WHERE CASE WHEN jsonb_typeof(t1.v) = 'number' THEN (t1.v)::numeric >= 5 ELSE FALSE END)`, sql)
+}
+
+func TestTranslate_InvalidCondition(t *testing.T) {
+	cond := filter.Path("age").Gt([]int{1})
+	for _, target := range translate.Targets {
+		_, err := translate.Translate(cond, target)
+		assert.Error(t, err, target)
+	}
+
+	_, err := translate.Translate(filter.Const(true), "oracle")
+	assert.EqualError(t, err, "unknown target 'oracle'")
+}